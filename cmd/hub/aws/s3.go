@@ -8,24 +8,44 @@ package aws
 
 import (
 	"bytes"
+	"crypto/x509"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	awsaws "github.com/aws/aws-sdk-go/aws"
+	awssession "github.com/aws/aws-sdk-go/aws/session"
 	awss3 "github.com/aws/aws-sdk-go/service/s3"
 
 	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/util"
 )
 
+// AnyETag makes write unconditional
+const AnyETag = "*"
+
 var (
-	bucketRegion = make(map[string]string)
-	regionS3     = make(map[string]*awss3.S3)
+	bucketRegion     = make(map[string]string)
+	regionS3         = make(map[string]*awss3.S3)
+	bucketEndpointS3 = make(map[string]*awss3.S3)
+
+	s3Timeout = time.Duration(30 * time.Second)
 )
 
+type s3Object struct {
+	s3     *awss3.S3
+	bucket string
+	key    string
+	// URL query with S3-compatible endpoint settings, preserved in listed paths
+	query string
+}
+
 func awsBucketS3(bucket string) (*awss3.S3, error) {
 	region, err := awsBucketRegion(bucket)
 	if err != nil {
@@ -61,6 +81,9 @@ func awsBucketRegion(bucket string) (string, error) {
 }
 
 func awsS3(region string) (*awss3.S3, error) {
+	if s3, exist := regionS3[region]; exist {
+		return s3, nil
+	}
 	session, err := Session(region, "S3")
 	if err != nil {
 		return nil, err
@@ -70,71 +93,230 @@ func awsS3(region string) (*awss3.S3, error) {
 	return s3, nil
 }
 
-func StatS3(s3path string) (int64, time.Time, error) {
+// s3Endpoint returns S3-compatible endpoint settings of the bucket from config file
+// overridden by URL query parameters, or nil for AWS S3
+func s3Endpoint(bucket string, query url.Values) (*config.S3Bucket, error) {
+	endpoint := config.S3Buckets[bucket]
+	for name, values := range query {
+		value := values[len(values)-1]
+		switch name {
+		case "endpoint":
+			endpoint.Endpoint = value
+		case "region":
+			endpoint.Region = value
+		case "caBundle":
+			endpoint.CaBundle = value
+		case "pathStyle", "insecure":
+			flag, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("Bad S3 `%s=%s` parameter: %v", name, value, err)
+			}
+			if name == "pathStyle" {
+				endpoint.PathStyle = flag
+			} else {
+				endpoint.Insecure = flag
+			}
+		default:
+			return nil, fmt.Errorf("Unknown S3 `%s` parameter; supported are: endpoint, region, pathStyle, insecure, caBundle", name)
+		}
+	}
+	if endpoint.Endpoint == "" {
+		return nil, nil
+	}
+	return &endpoint, nil
+}
+
+func endpointS3(bucket string, query url.Values) (*awss3.S3, error) {
+	endpoint, err := s3Endpoint(bucket, query)
+	if err != nil || endpoint == nil {
+		return nil, err
+	}
+	cacheKey := bucket + "?" + query.Encode()
+	if s3, exist := bucketEndpointS3[cacheKey]; exist {
+		return s3, nil
+	}
+
+	region := endpoint.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	client := util.RobustHttpClient(s3Timeout, endpoint.Insecure)
+	if endpoint.CaBundle != "" {
+		pem, err := os.ReadFile(endpoint.CaBundle)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read S3 endpoint CA bundle: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in S3 endpoint CA bundle `%s`", endpoint.CaBundle)
+		}
+		client.Transport.(*http.Transport).TLSClientConfig.RootCAs = pool
+	}
+	var session *awssession.Session
+	accessKey := os.ExpandEnv(endpoint.AccessKey)
+	if accessKey != "" {
+		session, err = SessionWithStaticCredentials(region, "S3", accessKey, os.ExpandEnv(endpoint.SecretKey), "")
+	} else {
+		session, err = Session(region, "S3")
+	}
+	if err != nil {
+		return nil, err
+	}
+	s3 := awss3.New(session, awsaws.NewConfig().
+		WithEndpoint(endpoint.Endpoint).
+		WithS3ForcePathStyle(endpoint.PathStyle).
+		WithHTTPClient(client))
+	if config.Debug {
+		log.Printf("S3 bucket `%s` endpoint is %s", bucket, endpoint.Endpoint)
+	}
+	bucketEndpointS3[cacheKey] = s3
+	return s3, nil
+}
+
+func parseS3Path(s3path string) (*s3Object, error) {
 	location, err := url.Parse(s3path)
 	if err != nil {
-		return 0, time.Time{}, err
+		return nil, err
+	}
+	s3, err := endpointS3(location.Host, location.Query())
+	if err != nil {
+		return nil, err
+	}
+	if s3 == nil {
+		s3, err = awsBucketS3(location.Host)
+		if err != nil {
+			return nil, err
+		}
 	}
-	s3, err := awsBucketS3(location.Host)
+	return &s3Object{s3: s3, bucket: location.Host, key: location.Path, query: location.RawQuery}, nil
+}
+
+// StatS3 returns object size, modification time, and ETag, or os.ErrNotExist
+func StatS3(s3path string) (int64, time.Time, string, error) {
+	object, err := parseS3Path(s3path)
 	if err != nil {
-		return 0, time.Time{}, err
+		return 0, time.Time{}, "", err
 	}
-	head, err := s3.HeadObject(
+	head, err := object.s3.HeadObject(
 		&awss3.HeadObjectInput{
-			Bucket: &location.Host,
-			Key:    &location.Path,
+			Bucket: &object.bucket,
+			Key:    &object.key,
 		})
 	if err != nil {
 		if IsNotFound(err) {
-			return 0, time.Time{}, os.ErrNotExist
+			return 0, time.Time{}, "", os.ErrNotExist
 		}
-		return 0, time.Time{}, fmt.Errorf("Failed to HEAD S3 object `%s`: %v\n\t%s", s3path, err, optionsHelp)
+		return 0, time.Time{}, "", fmt.Errorf("Failed to HEAD S3 object `%s`: %v\n\t%s", s3path, err, optionsHelp)
 	}
-	return *head.ContentLength, *head.LastModified, nil
+	return *head.ContentLength, *head.LastModified, awsaws.StringValue(head.ETag), nil
 }
 
-func ReadS3(s3path string) ([]byte, error) {
-	location, err := url.Parse(s3path)
+// ReadS3 returns object body and ETag, or os.ErrNotExist
+func ReadS3(s3path string) ([]byte, string, error) {
+	object, err := parseS3Path(s3path)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	s3, err := awsBucketS3(location.Host)
-	if err != nil {
-		return nil, err
-	}
-	obj, err := s3.GetObject(
+	obj, err := object.s3.GetObject(
 		&awss3.GetObjectInput{
-			Bucket: &location.Host,
-			Key:    &location.Path,
+			Bucket: &object.bucket,
+			Key:    &object.key,
 		})
 	if err != nil {
-		return nil, fmt.Errorf("Failed to GET S3 object `%s`: %v\n\t%s", s3path, err, optionsHelp)
+		if IsNotFound(err) || IsNoSuchKey(err) {
+			return nil, "", os.ErrNotExist
+		}
+		return nil, "", fmt.Errorf("Failed to GET S3 object `%s`: %v\n\t%s", s3path, err, optionsHelp)
 	}
+	defer obj.Body.Close()
 	data, err := ioutil.ReadAll(obj.Body)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to read S3 object `%s`: %v", s3path, err)
+		return nil, "", fmt.Errorf("Failed to read S3 object `%s`: %v", s3path, err)
 	}
-	obj.Body.Close()
-	return data, nil
+	return data, awsaws.StringValue(obj.ETag), nil
 }
 
-func WriteS3(s3path string, body []byte) error {
-	location, err := url.Parse(s3path)
+// WriteS3 creates the object if etag is empty, replaces the object with matching ETag,
+// or writes unconditionally if etag is AnyETag; returns new ETag, or os.ErrExist if the object
+// exists or was modified
+func WriteS3(s3path string, body []byte, etag string) (string, error) {
+	object, err := parseS3Path(s3path)
 	if err != nil {
-		return err
-	}
-	s3, err := awsBucketS3(location.Host)
-	if err != nil {
-		return err
+		return "", err
 	}
-	_, err = s3.PutObject(
+	req, out := object.s3.PutObjectRequest(
 		&awss3.PutObjectInput{
 			Body:   awsaws.ReadSeekCloser(bytes.NewReader(body)),
-			Bucket: &location.Host,
-			Key:    &location.Path,
+			Bucket: &object.bucket,
+			Key:    &object.key,
 		})
+	// SDK version in use has no conditional writes parameters
+	switch etag {
+	case AnyETag:
+	case "":
+		req.HTTPRequest.Header.Set("If-None-Match", "*")
+	default:
+		req.HTTPRequest.Header.Set("If-Match", etag)
+	}
+	if err := req.Send(); err != nil {
+		if IsPreconditionFailed(err) {
+			return "", os.ErrExist
+		}
+		return "", fmt.Errorf("Failed to PUT S3 object `%s`: %v\n\t%s", s3path, err, optionsHelp)
+	}
+	return awsaws.StringValue(out.ETag), nil
+}
+
+// DeleteS3 deletes the object with matching ETag, if set, or returns os.ErrExist if the object
+// was modified. Endpoints without conditional delete support ignore If-Match and delete the
+// object unconditionally - then a lock taken over between Stat and Delete is deleted too.
+func DeleteS3(s3path string, etag string) error {
+	object, err := parseS3Path(s3path)
 	if err != nil {
-		return fmt.Errorf("Failed to PUT S3 object `%s`: %v\n\t%s", s3path, err, optionsHelp)
+		return err
+	}
+	req, _ := object.s3.DeleteObjectRequest(
+		&awss3.DeleteObjectInput{
+			Bucket: &object.bucket,
+			Key:    &object.key,
+		})
+	if etag != "" && etag != AnyETag {
+		req.HTTPRequest.Header.Set("If-Match", etag)
+	}
+	if err := req.Send(); err != nil {
+		if IsPreconditionFailed(err) {
+			return os.ErrExist
+		}
+		return fmt.Errorf("Failed to DELETE S3 object `%s`: %v\n\t%s", s3path, err, optionsHelp)
 	}
 	return nil
 }
+
+// ListS3 returns paths of objects under the prefix
+func ListS3(s3prefix string) ([]string, error) {
+	object, err := parseS3Path(s3prefix)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimLeft(object.key, "/")
+	query := ""
+	if object.query != "" {
+		query = "?" + object.query
+	}
+	var paths []string
+	err = object.s3.ListObjectsV2Pages(
+		&awss3.ListObjectsV2Input{
+			Bucket: &object.bucket,
+			Prefix: &prefix,
+		},
+		func(page *awss3.ListObjectsV2Output, last bool) bool {
+			for _, obj := range page.Contents {
+				paths = append(paths, fmt.Sprintf("s3://%s/%s%s", object.bucket, awsaws.StringValue(obj.Key), query))
+			}
+			return true
+		})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list S3 objects `%s`: %v\n\t%s", s3prefix, err, optionsHelp)
+	}
+	return paths, nil
+}
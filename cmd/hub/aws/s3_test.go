@@ -0,0 +1,78 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package aws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+)
+
+func TestS3Endpoint(t *testing.T) {
+	defer func() { config.S3Buckets = nil }()
+	config.S3Buckets = map[string]config.S3Bucket{
+		"state": {Endpoint: "https://minio.local:9000", PathStyle: true, AccessKey: "minio", SecretKey: "$MINIO_SECRET"},
+	}
+
+	endpoint, err := s3Endpoint("aws", url.Values{})
+	assert.Nil(t, err)
+	assert.Nil(t, endpoint)
+
+	endpoint, err = s3Endpoint("state", url.Values{})
+	if assert.Nil(t, err) && assert.NotNil(t, endpoint) {
+		assert.Equal(t, config.S3Buckets["state"], *endpoint)
+	}
+
+	query, _ := url.ParseQuery("endpoint=http://ceph:7480&pathStyle=false&insecure=true&region=eu")
+	endpoint, err = s3Endpoint("state", query)
+	if assert.Nil(t, err) && assert.NotNil(t, endpoint) {
+		assert.Equal(t, "http://ceph:7480", endpoint.Endpoint)
+		assert.Equal(t, "eu", endpoint.Region)
+		assert.False(t, endpoint.PathStyle)
+		assert.True(t, endpoint.Insecure)
+		assert.Equal(t, "minio", endpoint.AccessKey)
+	}
+	assert.Equal(t, "https://minio.local:9000", config.S3Buckets["state"].Endpoint)
+
+	_, err = s3Endpoint("state", url.Values{"pathStyle": {"maybe"}})
+	assert.NotNil(t, err)
+	_, err = s3Endpoint("state", url.Values{"accessKey": {"minio"}})
+	assert.NotNil(t, err)
+}
+
+func TestDeleteS3(t *testing.T) {
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if match := r.Header.Get("If-Match"); match != "" && match != `"current"` {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>PreconditionFailed</Code></Error>`))
+			return
+		}
+		deleted = append(deleted, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	defer func() {
+		config.S3Buckets = nil
+		delete(bucketEndpointS3, "state?")
+	}()
+	config.S3Buckets = map[string]config.S3Bucket{
+		"state": {Endpoint: server.URL, PathStyle: true, Region: "us-east-1", AccessKey: "minio", SecretKey: "secret"},
+	}
+
+	assert.Equal(t, os.ErrExist, DeleteS3("s3://state/hub.yaml.state.lock", `"stale"`))
+	assert.Empty(t, deleted)
+	assert.Nil(t, DeleteS3("s3://state/hub.yaml.state.lock", `"current"`))
+	assert.Nil(t, DeleteS3("s3://state/hub.yaml.state.lock", ""))
+	assert.Equal(t, []string{"DELETE /state/hub.yaml.state.lock", "DELETE /state/hub.yaml.state.lock"}, deleted)
+}
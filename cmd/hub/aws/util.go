@@ -18,6 +18,18 @@ func IsNotFound(err error) bool {
 		strings.Contains(str, "status code: 404,")
 }
 
+func IsNoSuchKey(err error) bool {
+	return strings.HasPrefix(err.Error(), "NoSuchKey:")
+}
+
+// IsPreconditionFailed is true when conditional write failed due to If-Match / If-None-Match
+func IsPreconditionFailed(err error) bool {
+	str := err.Error()
+	return strings.HasPrefix(str, "PreconditionFailed:") ||
+		strings.HasPrefix(str, "ConditionalRequestConflict:") ||
+		strings.Contains(str, "status code: 412,")
+}
+
 func IsSlowDown(err error) bool {
 	str := err.Error()
 	return strings.Contains(str, "SlowDown: Please reduce your request rate.")
@@ -7,15 +7,19 @@
 package azure
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/storage"
+	"github.com/google/uuid"
 
 	"github.com/epam/hubctl/cmd/hub/util"
 )
@@ -40,9 +44,63 @@ func storageClient(account string) (*storage.BlobStorageClient, error) {
 	}
 	storageClient, _ := storage.NewClient(account, key, env.StorageEndpointSuffix, storage.DefaultAPIVersion, true)
 	storageClient.HTTPClient = util.RobustHttpClient(storageTimeout, false)
+	blobClient := newBlobClient(storageClient)
+	blobClients[account] = blobClient
+	return blobClient, nil
+}
+
+func newBlobClient(storageClient storage.Client) *storage.BlobStorageClient {
+	transport := storageClient.HTTPClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	httpClient := *storageClient.HTTPClient
+	httpClient.Transport = &etagRecorder{transport: transport}
+	storageClient.HTTPClient = &httpClient
 	blobClient := storageClient.GetBlobService()
-	blobClients[account] = &blobClient
-	return &blobClient, nil
+	return &blobClient
+}
+
+const clientRequestIdHeader = "x-ms-client-request-id"
+
+var (
+	writeETags      = make(map[string]string)
+	writeETagsMutex sync.Mutex
+)
+
+// etagRecorder keeps ETag of Put Blob response by client request id, as the SDK does not return
+// ETag of created blob, and reading it with another request is racy
+type etagRecorder struct {
+	transport http.RoundTripper
+}
+
+func (recorder *etagRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := recorder.transport.RoundTrip(req)
+	if err == nil {
+		// SDK sets lower case header bypassing canonicalization
+		if id := req.Header[clientRequestIdHeader]; len(id) > 0 {
+			writeETagsMutex.Lock()
+			if _, pending := writeETags[id[0]]; pending {
+				writeETags[id[0]] = resp.Header.Get("Etag")
+			}
+			writeETagsMutex.Unlock()
+		}
+	}
+	return resp, err
+}
+
+func recordWriteETag(id string) {
+	writeETagsMutex.Lock()
+	writeETags[id] = ""
+	writeETagsMutex.Unlock()
+}
+
+func takeWriteETag(id string) string {
+	writeETagsMutex.Lock()
+	defer writeETagsMutex.Unlock()
+	etag := writeETags[id]
+	delete(writeETags, id)
+	return etag
 }
 
 func splitPath(path string) (string, string, string, error) {
@@ -57,52 +115,115 @@ func splitPath(path string) (string, string, string, error) {
 	return location.Host, parts[1], parts[2], nil
 }
 
-func StatStorageBlob(path string) (int64, time.Time, error) {
+// AnyETag makes write unconditional
+const AnyETag = "*"
+
+// StatStorageBlob returns blob size, modification time, and ETag, or os.ErrNotExist
+func StatStorageBlob(path string) (int64, time.Time, string, error) {
 	account, container, name, err := splitPath(path)
 	if err != nil {
-		return 0, time.Time{}, err
+		return 0, time.Time{}, "", err
 	}
 	blobClient, err := storageClient(account)
 	if err != nil {
-		return 0, time.Time{}, err
+		return 0, time.Time{}, "", err
 	}
 	containerRef := blobClient.GetContainerReference(container)
 	blobRef := containerRef.GetBlobReference(name)
 	err = blobRef.GetProperties(nil)
 	if err != nil {
 		if IsNotFound(err) {
-			return 0, time.Time{}, os.ErrNotExist
+			return 0, time.Time{}, "", os.ErrNotExist
 		}
-		return 0, time.Time{}, err
+		return 0, time.Time{}, "", err
 	}
 	props := blobRef.Properties
-	return props.ContentLength, time.Time(props.LastModified), nil
+	return props.ContentLength, time.Time(props.LastModified), props.Etag, nil
 }
 
-func ReadStorageBlob(path string) ([]byte, error) {
+// ReadStorageBlob returns blob body and ETag, or os.ErrNotExist
+func ReadStorageBlob(path string) ([]byte, string, error) {
 	account, container, name, err := splitPath(path)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	blobClient, err := storageClient(account)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	containerRef := blobClient.GetContainerReference(container)
 	blobRef := containerRef.GetBlobReference(name)
 	reader, err := blobRef.Get(&storage.GetBlobOptions{Timeout: storageTimeoutSec})
 	if err != nil {
-		return nil, err
+		if IsNotFound(err) {
+			return nil, "", os.ErrNotExist
+		}
+		return nil, "", err
 	}
 	defer reader.Close()
 	data, err := ioutil.ReadAll(reader)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to read Azure storage blob `%s`: %v", path, err)
+		return nil, "", fmt.Errorf("Failed to read Azure storage blob `%s`: %v", path, err)
 	}
-	return data, nil
+	return data, blobRef.Properties.Etag, nil
 }
 
-func WriteStorageBlob(path string, body []byte) error {
+// WriteStorageBlob creates block blob if etag is empty, replaces the blob with matching ETag,
+// or writes unconditionally if etag is AnyETag; returns new ETag, or os.ErrExist if the blob
+// exists or was modified. Append blob written by previous versions is replaced by block blob.
+func WriteStorageBlob(path string, body []byte, etag string) (string, error) {
+	account, container, name, err := splitPath(path)
+	if err != nil {
+		return "", err
+	}
+	blobClient, err := storageClient(account)
+	if err != nil {
+		return "", err
+	}
+	blobRef := blobClient.GetContainerReference(container).GetBlobReference(name)
+	newETag, err := putBlockBlob(blobRef, body, etag)
+	if etag != "" && isInvalidBlobType(err) {
+		// blob type cannot be changed by Put Blob: the append blob of the version is deleted
+		// and the block blob is created unless another operation created the blob meanwhile
+		util.Warn("Replacing Azure storage append blob `%s` with block blob", path)
+		deleteETag := etag
+		if deleteETag == AnyETag {
+			deleteETag = ""
+		}
+		err = blobRef.Delete(&storage.DeleteBlobOptions{Timeout: storageTimeoutSec, IfMatch: deleteETag})
+		if err == nil {
+			newETag, err = putBlockBlob(blobRef, body, "")
+		}
+	}
+	if err != nil {
+		if IsPreconditionFailed(err) {
+			return "", os.ErrExist
+		}
+		return "", fmt.Errorf("Failed to write Azure storage blob `%s`: %v", path, err)
+	}
+	if newETag == "" {
+		return "", fmt.Errorf("No ETag in Azure storage blob `%s` write response", path)
+	}
+	return newETag, nil
+}
+
+func putBlockBlob(blobRef *storage.Blob, body []byte, etag string) (string, error) {
+	id := uuid.NewString()
+	options := &storage.PutBlobOptions{Timeout: storageTimeoutSec, RequestID: id}
+	switch etag {
+	case AnyETag:
+	case "":
+		options.IfNoneMatch = "*"
+	default:
+		options.IfMatch = etag
+	}
+	recordWriteETag(id)
+	err := blobRef.CreateBlockBlobFromReader(bytes.NewReader(body), options)
+	return takeWriteETag(id), err
+}
+
+// DeleteStorageBlob deletes the blob with matching ETag, if set
+func DeleteStorageBlob(path string, etag string) error {
 	account, container, name, err := splitPath(path)
 	if err != nil {
 		return err
@@ -113,13 +234,42 @@ func WriteStorageBlob(path string, body []byte) error {
 	}
 	containerRef := blobClient.GetContainerReference(container)
 	blobRef := containerRef.GetBlobReference(name)
-	err = blobRef.PutAppendBlob(&storage.PutBlobOptions{Timeout: storageTimeoutSec})
+	err = blobRef.Delete(&storage.DeleteBlobOptions{Timeout: storageTimeoutSec, IfMatch: etag})
 	if err != nil {
-		return err
+		if IsPreconditionFailed(err) {
+			return os.ErrExist
+		}
+		return fmt.Errorf("Failed to delete Azure storage blob `%s`: %v", path, err)
 	}
-	err = blobRef.AppendBlock(body, &storage.AppendBlockOptions{Timeout: storageTimeoutSec})
+	return nil
+}
+
+// ListStorageBlobs returns paths of blobs under the prefix
+func ListStorageBlobs(prefix string) ([]string, error) {
+	account, container, name, err := splitPath(prefix)
 	if err != nil {
-		return fmt.Errorf("Failed to write Azure storage blob `%s`: %v", path, err)
+		return nil, err
 	}
-	return nil
+	blobClient, err := storageClient(account)
+	if err != nil {
+		return nil, err
+	}
+	containerRef := blobClient.GetContainerReference(container)
+	var paths []string
+	marker := ""
+	for {
+		list, err := containerRef.ListBlobs(storage.ListBlobsParameters{
+			Prefix: name, Marker: marker, Timeout: storageTimeoutSec})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list Azure storage blobs `%s`: %v", prefix, err)
+		}
+		for _, blob := range list.Blobs {
+			paths = append(paths, fmt.Sprintf("az://%s/%s/%s", account, container, blob.Name))
+		}
+		if list.NextMarker == "" {
+			break
+		}
+		marker = list.NextMarker
+	}
+	return paths, nil
 }
@@ -0,0 +1,177 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package azure
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeBlob struct {
+	blobType string
+	etag     string
+	data     []byte
+}
+
+// fakeBlobService implements Put Blob, Get Blob, Get Blob Properties, and Delete Blob
+// with If-Match / If-None-Match conditions
+type fakeBlobService struct {
+	mutex    sync.Mutex
+	blobs    map[string]*fakeBlob
+	etag     int
+	requests []string
+}
+
+func (service *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.requests = append(service.requests, r.Method)
+
+	fail := func(status int, code string) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(status)
+		if r.Method != http.MethodHead {
+			fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+		}
+	}
+	blob, exist := service.blobs[r.URL.Path]
+	if match := r.Header.Get("If-Match"); match != "" && (!exist || match != blob.etag) {
+		fail(http.StatusPreconditionFailed, "ConditionNotMet")
+		return
+	}
+	if r.Header.Get("If-None-Match") == "*" && exist {
+		fail(http.StatusConflict, "BlobAlreadyExists")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		blobType := r.Header.Get("x-ms-blob-type")
+		if exist && blob.blobType != blobType {
+			fail(http.StatusConflict, "InvalidBlobType")
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		service.etag++
+		blob = &fakeBlob{blobType: blobType, etag: fmt.Sprintf("\"0x%d\"", service.etag), data: data}
+		service.blobs[r.URL.Path] = blob
+		w.Header().Set("Etag", blob.etag)
+		w.WriteHeader(http.StatusCreated)
+
+	case http.MethodGet, http.MethodHead:
+		if !exist {
+			fail(http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		w.Header().Set("Etag", blob.etag)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("x-ms-blob-type", blob.blobType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(blob.data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(blob.data)
+		}
+
+	case http.MethodDelete:
+		if !exist {
+			fail(http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		delete(service.blobs, r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (service *fakeBlobService) takeRequests() []string {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	requests := service.requests
+	service.requests = nil
+	return requests
+}
+
+// redirectTransport sends requests to storage account endpoint to the test server
+type redirectTransport struct {
+	server *url.URL
+}
+
+func (transport *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = transport.server.Scheme
+	req.URL.Host = transport.server.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestStorageBlob(t *testing.T) {
+	service := &fakeBlobService{blobs: map[string]*fakeBlob{
+		"/hub/dev/hub.yaml.state": {blobType: string(storage.BlobTypeAppend), etag: "\"0xa\"", data: []byte("append")},
+	}}
+	server := httptest.NewServer(service)
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	client, err := storage.NewClient("test", base64.StdEncoding.EncodeToString([]byte("key")),
+		storage.DefaultBaseURL, storage.DefaultAPIVersion, true)
+	if !assert.Nil(t, err) {
+		return
+	}
+	client.HTTPClient = &http.Client{Transport: &redirectTransport{serverURL}}
+	blobClients["test"] = newBlobClient(client)
+	defer delete(blobClients, "test")
+	path := "az://test/hub/dev/hub.yaml.state"
+
+	_, _, etag, err := StatStorageBlob(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "\"0xa\"", etag)
+	_, err = WriteStorageBlob(path, []byte("block"), "\"0x0\"")
+	assert.Equal(t, os.ErrExist, err)
+	_, err = WriteStorageBlob(path, []byte("block"), "")
+	assert.Equal(t, os.ErrExist, err, "create must not replace append blob")
+	service.takeRequests()
+
+	// append blob of the version is replaced by block blob
+	etag1, err := WriteStorageBlob(path, []byte("block 1"), etag)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"PUT", "DELETE", "PUT"}, service.takeRequests())
+		assert.Equal(t, string(storage.BlobTypeBlock), service.blobs["/hub/dev/hub.yaml.state"].blobType)
+		data, readETag, err := ReadStorageBlob(path)
+		assert.Nil(t, err)
+		assert.Equal(t, "block 1", string(data))
+		assert.Equal(t, etag1, readETag)
+	}
+
+	// ETag is taken from write response
+	service.takeRequests()
+	etag2, err := WriteStorageBlob(path, []byte("block 2"), etag1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"PUT"}, service.takeRequests())
+	assert.NotEqual(t, etag1, etag2)
+	_, err = WriteStorageBlob(path, []byte("stale"), etag1)
+	assert.Equal(t, os.ErrExist, err)
+	etag3, err := WriteStorageBlob(path, []byte("block 3"), AnyETag)
+	assert.Nil(t, err)
+	_, _, etag, _ = StatStorageBlob(path)
+	assert.Equal(t, etag3, etag)
+
+	assert.Equal(t, os.ErrExist, DeleteStorageBlob(path, etag2))
+	assert.Nil(t, DeleteStorageBlob(path, etag3))
+	_, _, _, err = StatStorageBlob(path)
+	assert.Equal(t, os.ErrNotExist, err)
+	assert.Empty(t, writeETags)
+	assert.Empty(t, service.blobs)
+}
@@ -13,3 +13,17 @@ func IsNotFound(err error) bool {
 	return strings.HasPrefix(str, "storage:") &&
 		strings.Contains(str, "StatusCode=404")
 }
+
+// IsPreconditionFailed is true when conditional write failed due to If-Match / If-None-Match
+func IsPreconditionFailed(err error) bool {
+	str := err.Error()
+	return strings.HasPrefix(str, "storage:") &&
+		(strings.Contains(str, "StatusCode=412") || strings.Contains(str, "StatusCode=409")) &&
+		!isInvalidBlobType(err)
+}
+
+// isInvalidBlobType is true when blob of another type, ie. append blob, is written as block blob
+func isInvalidBlobType(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "storage:") &&
+		strings.Contains(err.Error(), "ErrorCode=InvalidBlobType")
+}
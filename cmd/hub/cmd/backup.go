@@ -67,6 +67,9 @@ func backupCreate(args []string, pipe io.WriteCloser) error {
 	if len(args) != 1 {
 		return errors.New("Backup Create command has only one argument - path to Stack Elaborate file")
 	}
+	if err := checkLockFlags(); err != nil {
+		return err
+	}
 
 	manifests := util.SplitPaths(args[0])
 	stateManifests := util.SplitPaths(stateManifestExplicit)
@@ -86,6 +89,8 @@ func backupCreate(args []string, pipe io.WriteCloser) error {
 		Environment:       hubEnvironment,
 		StackInstance:     hubStackInstance,
 		Application:       hubApplication,
+		LockWaitSeconds:   lockWait,
+		LockLeaseSeconds:  lockLease,
 	}
 
 	lifecycle.BackupCreate(request, bundleFiles, backupBundleInJson, backupAllowPartial, pipe)
@@ -125,6 +130,7 @@ func init() {
 	backupCreateCmd.Flags().BoolVarP(&backupAllowPartial, "allow-partial", "", false,
 		"Allow partial backups to succeed")
 	initCommonLifecycleFlags(backupCreateCmd, "backup")
+	initLockFlags(backupCreateCmd)
 
 	backupUnbundleCmd.Flags().StringVarP(&outputFiles, "output", "o", "",
 		"Parameters output file(s), optionally write to S3 (default to stdout)")
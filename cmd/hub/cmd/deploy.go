@@ -16,7 +16,9 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/events"
 	"github.com/epam/hubctl/cmd/hub/lifecycle"
+	"github.com/epam/hubctl/cmd/hub/storage"
 	"github.com/epam/hubctl/cmd/hub/util"
 )
 
@@ -36,6 +38,18 @@ var (
 	hubSyncStackInstance          bool
 	hubSyncSkipParametersAndOplog bool
 	writeOplogToStateOnError      bool
+	parallelism                   int
+	changedOnly                   bool
+	rollbackOnFailure             bool
+	stackTimeout                  int
+	resumeOperation               bool
+	eventsFormat                  string
+	eventsOutput                  string
+	logsDir                       string
+	logsGzip                      bool
+	logsRetention                 int
+	lockWait                      int
+	lockLease                     int
 )
 
 var deployCmd = &cobra.Command{
@@ -102,12 +116,39 @@ func lifecycleRequest(args []string, verb string) (*lifecycle.Request, error) {
 		stateManifest = ""
 	}
 
+	if parallelism < 1 {
+		return nil, errors.New("--parallelism must be at least 1")
+	}
+	if stackTimeout < 0 {
+		return nil, errors.New("--timeout must not be negative")
+	}
+	if logsRetention < 0 {
+		return nil, errors.New("--logs-retention must not be negative")
+	}
+	if err := checkLockFlags(); err != nil {
+		return nil, err
+	}
 	if componentName != "" && offsetComponent != "" {
 		return nil, errors.New("At most one of -c / --components or -o / --offset must be specified")
 	}
+	if resumeOperation && stateManifest == "" {
+		return nil, errors.New("State file (-s) must be specified for --resume")
+	}
+	if resumeOperation && (componentName != "" || offsetComponent != "" || limitComponent != "") {
+		return nil, errors.New("--resume reuses components of the original operation, -c / -o / -l cannot be specified")
+	}
+	if rollbackOnFailure && stateManifest == "" {
+		return nil, errors.New("State file (-s) must be specified for --rollback-on-failure")
+	}
+	if rollbackOnFailure && config.Force {
+		return nil, errors.New("--rollback-on-failure cannot be used with --force")
+	}
 	if (componentName != "" || offsetComponent != "") && stateManifest == "" && !config.Force {
 		return nil, errors.New("State file (-s) must be specified when component (-c or -o) is specified")
 	}
+	if eventsFormat != "" && (eventsOutput == "" || eventsOutput == "-") && config.LogDestination == "stdout" {
+		return nil, errors.New("--events to stdout cannot be used with --log-destination stdout, use --events-output")
+	}
 
 	manifests := util.SplitPaths(args[0])
 	stateManifests := util.SplitPaths(stateManifest)
@@ -118,6 +159,10 @@ func lifecycleRequest(args []string, verb string) (*lifecycle.Request, error) {
 			strings.Join(clouds, ", "), strings.Join(supportedClouds, ", "))
 	}
 
+	if err := events.Init(eventsFormat, eventsOutput); err != nil {
+		return nil, err
+	}
+
 	setOsEnvForNestedCli(manifests, stateManifests, componentsBaseDir)
 
 	// TODO remove compat
@@ -148,6 +193,16 @@ func lifecycleRequest(args []string, verb string) (*lifecycle.Request, error) {
 		SyncStackInstance:          hubSyncStackInstance,
 		SyncSkipParametersAndOplog: hubSyncSkipParametersAndOplog,
 		WriteOplogToStateOnError:   writeOplogToStateOnError,
+		Parallelism:                parallelism,
+		ChangedOnly:                changedOnly,
+		RollbackOnFailure:          rollbackOnFailure,
+		TimeoutSeconds:             stackTimeout,
+		Resume:                     resumeOperation,
+		LogsDir:                    logsDir,
+		LogsGzip:                   logsGzip,
+		LogsRetention:              logsRetention,
+		LockWaitSeconds:            lockWait,
+		LockLeaseSeconds:           lockLease,
 	}
 
 	return request, nil
@@ -183,8 +238,42 @@ func initDeployUndeployFlags(cmd *cobra.Command, verb string) {
 		"Sync skip syncing Stack Instance parameters and operation log")
 	cmd.Flags().BoolVar(&writeOplogToStateOnError, "write-oplog-to-state-on-error", false,
 		"Write operations log to state files on error")
+	cmd.Flags().IntVarP(&parallelism, "parallelism", "", 1,
+		fmt.Sprintf("Number of components to %s in parallel following components dependency graph", verb))
+	cmd.Flags().BoolVarP(&resumeOperation, "resume", "", false,
+		fmt.Sprintf("Resume last failed or interrupted %[1]s operation: %[1]s components that did not succeed, with the same components selection and options", verb))
+	cmd.Flags().IntVarP(&stackTimeout, "timeout", "", 0,
+		fmt.Sprintf("Stack %s timeout in seconds, see also component lifecycle.timeoutSeconds (0 = no timeout)", verb))
+	cmd.Flags().StringVarP(&logsDir, "logs-dir", "", "",
+		"Write each component verb and hook output to a separate log file under DIR/<timestamp>-<operation id>/")
+	cmd.Flags().BoolVarP(&logsGzip, "logs-gzip", "", false,
+		"Gzip log files written to --logs-dir")
+	cmd.Flags().IntVarP(&logsRetention, "logs-retention", "", 0,
+		"Number of most recent operations logs to keep in --logs-dir (0 = keep all)")
+	cmd.Flags().StringVarP(&eventsFormat, "events", "", "",
+		"Emit machine-readable lifecycle events, one of: json (see events.schema.json)")
+	cmd.Flags().StringVarP(&eventsOutput, "events-output", "", "-",
+		"Events destination: - for stdout (other output goes to stderr), a file path, or unix:/path/to/socket")
 	initCommonLifecycleFlags(cmd, verb)
 	initCommonApiFlags(cmd)
+	initLockFlags(cmd)
+}
+
+func initLockFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&lockWait, "lock-wait", "", 0,
+		"Seconds to wait for state lock held by another operation (0 = fail immediately)")
+	cmd.Flags().IntVarP(&lockLease, "lock-lease", "", int(storage.DefaultLockLease.Seconds()),
+		"State lock lease in seconds, renewed while the operation is running; expired lock is taken over by other operations")
+}
+
+func checkLockFlags() error {
+	if lockWait < 0 {
+		return errors.New("--lock-wait must not be negative")
+	}
+	if lockLease < 15 {
+		return errors.New("--lock-lease must be at least 15 seconds")
+	}
+	return nil
 }
 
 func initCommonLifecycleFlags(cmd *cobra.Command, verb string) {
@@ -215,6 +304,10 @@ func init() {
 		"Produce hub.components.<component-name>.git.* outputs")
 	deployCmd.Flags().BoolVarP(&gitOutputsStatus, "git-outputs-status", "", false,
 		"Produce hub.components.<component-name>.git.clean = {clean, dirty} which is expensive to calculate")
+	deployCmd.Flags().BoolVarP(&changedOnly, "changed-only", "", false,
+		"Skip components which parameters are unchanged since last deploy, see `hubctl plan` (state file must exist)")
+	deployCmd.Flags().BoolVarP(&rollbackOnFailure, "rollback-on-failure", "", false,
+		"On mandatory component failure undeploy components deployed by this operation and redeploy pre-existing components with previous parameters")
 	deployCmd.Flags().BoolVarP(&hubSaveStackInstanceOutputs, "hub-save-stack-instance-outputs", "", false,
 		"(deprecated) Send Stack Instance outputs and provides to HubCTL (--hub-stack-instance must be set)")
 	RootCmd.AddCommand(deployCmd)
@@ -0,0 +1,80 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/epam/hubctl/cmd/hub/lifecycle"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+var driftInJson bool
+
+var driftCmd = &cobra.Command{
+	Use:   "drift hub.yaml.elaborate",
+	Short: "Detect drift of deployed components",
+	Long: `Execute optional check verb of each deployed component to find out whether deployed resources
+have drifted from the state, for example with terraform plan -detailed-exitcode or helm diff.
+
+The check implementation is found the same way as for other verbs. Exit code 0 means in-sync,
+exit code 2 means drifted, any other failure is reported as error. On exit code 0 or 2 the
+implementation may print drift = in-sync | drifted in Outputs: section instead.
+
+Drift status of each component is recorded in state under the state lock. Exit code is 2 if drift
+is found, otherwise 3 if the check of any component failed or is not implemented.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return drift(args)
+	},
+}
+
+func drift(args []string) error {
+	if len(args) != 1 {
+		return errors.New("Drift command has one argument - path to Stack Elaborate file")
+	}
+
+	if err := checkLockFlags(); err != nil {
+		return err
+	}
+
+	clouds := util.SplitPaths(strings.ToLower(enabledClouds))
+	if !util.ContainsAll(supportedClouds, clouds) {
+		return fmt.Errorf("Unsupported cloud specified (--clouds): %s; supported clouds are: %s",
+			strings.Join(clouds, ", "), strings.Join(supportedClouds, ", "))
+	}
+
+	request := &lifecycle.Request{
+		Verb:              "check",
+		DryRun:            dryRun,
+		ManifestFilenames: util.SplitPaths(args[0]),
+		StateFilenames:    util.SplitPaths(stateManifest),
+		EnabledClouds:     clouds,
+		Components:        util.SplitPaths(componentName),
+		OsEnvironmentMode: osEnvironmentMode,
+		ComponentsBaseDir: componentsBaseDir,
+		LockWaitSeconds:   lockWait,
+		LockLeaseSeconds:  lockLease,
+	}
+	lifecycle.Drift(request, driftInJson)
+	return nil
+}
+
+func init() {
+	driftCmd.Flags().StringVarP(&stateManifest, "state", "s", "hub.yaml.state",
+		"Path to state file(s), for example hub.yaml.state,s3://bucket/hub.yaml.state")
+	driftCmd.Flags().StringVarP(&componentName, "components", "c", "",
+		"A list of components to check (separated by comma)")
+	driftCmd.Flags().BoolVarP(&driftInJson, "json", "", false,
+		"JSON output")
+	initCommonLifecycleFlags(driftCmd, "check")
+	initLockFlags(driftCmd)
+	RootCmd.AddCommand(driftCmd)
+}
@@ -21,6 +21,8 @@ var (
 	explainGlobal bool
 	explainRaw    bool
 	explainOpLog  bool
+	explainLogs   bool
+	explainTail   int
 	explainInKv   bool
 	explainInSh   bool
 	explainInJson bool
@@ -62,7 +64,18 @@ func explain(args []string) error {
 		format = "yaml"
 	}
 
-	state.Explain(elaborateManifests, stateManifests, explainOpLog, explainGlobal, componentName, explainRaw, format, explainColor)
+	if (explainLogs || explainTail > 0) && !explainOpLog {
+		return errors.New("--logs and --tail require --op-log")
+	}
+	opLogLines := 0
+	if explainTail > 0 {
+		opLogLines = explainTail
+	} else if explainLogs {
+		opLogLines = -1
+	}
+
+	state.Explain(elaborateManifests, stateManifests, explainOpLog, opLogLines,
+		explainGlobal, componentName, explainRaw, format, explainColor)
 
 	return nil
 }
@@ -76,6 +89,10 @@ func init() {
 		"Display raw component outputs")
 	explainCmd.Flags().BoolVarP(&explainOpLog, "op-log", "l", false,
 		"Display operations log (only)")
+	explainCmd.Flags().BoolVarP(&explainLogs, "logs", "", false,
+		"Print operations log files written by deploy / undeploy --logs-dir (with --op-log)")
+	explainCmd.Flags().IntVarP(&explainTail, "tail", "", 0,
+		"Print last N lines of each operations log file (with --op-log)")
 	explainCmd.Flags().BoolVarP(&explainInKv, "kv", "", false,
 		"key=value output")
 	explainCmd.Flags().BoolVarP(&explainInSh, "sh", "", false,
@@ -30,6 +30,9 @@ func invoke(args []string) error {
 	if len(args) != 2 {
 		return errors.New("Invoke command has two mandatory argument - component name and verb")
 	}
+	if err := checkLockFlags(); err != nil {
+		return err
+	}
 
 	component := args[0]
 	verb := args[1]
@@ -62,6 +65,8 @@ func invoke(args []string) error {
 		OsEnvironmentMode:    osEnvironmentMode,
 		EnvironmentOverrides: environmentOverrides,
 		ComponentsBaseDir:    componentsBaseDir,
+		LockWaitSeconds:      lockWait,
+		LockLeaseSeconds:     lockLease,
 	}
 	lifecycle.Invoke(request)
 
@@ -79,5 +84,6 @@ func init() {
 		"OS environment mode for child process, one of: everything, no-tfvars, strict")
 	invokeCmd.Flags().StringVarP(&environmentOverrides, "environment", "e", "",
 		"Set additional environment variables: -e 'PORT=5000,...'")
+	initLockFlags(invokeCmd)
 	RootCmd.AddCommand(invokeCmd)
 }
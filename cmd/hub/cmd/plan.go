@@ -0,0 +1,86 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/epam/hubctl/cmd/hub/lifecycle"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+var (
+	planShowSecrets bool
+	planInJson      bool
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan hub.yaml.elaborate",
+	Short: "Show components that deploy would change",
+	Long: `Expand stack and components parameters the same way deploy does, without executing the components,
+and compare them with parameters recorded in state file.
+Each component is reported as new, changed, or unchanged.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return plan(args)
+	},
+}
+
+func plan(args []string) error {
+	if len(args) != 1 {
+		return errors.New("Plan command has one argument - path to Stack Elaborate file")
+	}
+	if componentName != "" && offsetComponent != "" {
+		return errors.New("At most one of -c / --components or -o / --offset must be specified")
+	}
+
+	clouds := util.SplitPaths(strings.ToLower(enabledClouds))
+	if !util.ContainsAll(supportedClouds, clouds) {
+		return fmt.Errorf("Unsupported cloud specified (--clouds): %s; supported clouds are: %s",
+			strings.Join(clouds, ", "), strings.Join(supportedClouds, ", "))
+	}
+
+	request := &lifecycle.Request{
+		Verb:                 "plan",
+		ManifestFilenames:    util.SplitPaths(args[0]),
+		StateFilenames:       util.SplitPaths(stateManifest),
+		EnabledClouds:        clouds,
+		Components:           util.SplitPaths(componentName),
+		OffsetComponent:      offsetComponent,
+		LimitComponent:       limitComponent,
+		EnvironmentOverrides: environmentOverrides,
+		Environment:          hubEnvironment,
+		StackInstance:        hubStackInstance,
+		Application:          hubApplication,
+	}
+	lifecycle.Plan(request, planShowSecrets, planInJson)
+	return nil
+}
+
+func init() {
+	planCmd.Flags().StringVarP(&stateManifest, "state", "s", "hub.yaml.state",
+		"Path to state file(s), for example hub.yaml.state,s3://bucket/hub.yaml.state")
+	planCmd.Flags().StringVarP(&componentName, "components", "c", "",
+		"A list of components to plan (separated by comma)")
+	planCmd.Flags().StringVarP(&offsetComponent, "offset", "o", "",
+		"Component to start plan with")
+	planCmd.Flags().StringVarP(&limitComponent, "limit", "l", "",
+		"Component to stop plan at")
+	planCmd.Flags().StringVarP(&environmentOverrides, "environment", "e", "",
+		"Set environment overrides: -e 'NAME=demo,INSTANCE=r4.large,...'")
+	planCmd.Flags().StringVarP(&enabledClouds, "clouds", "", "",
+		"A list of enabled clouds: \"aws,azure,gcp\" (default to autodetect from environment)")
+	planCmd.Flags().BoolVarP(&planShowSecrets, "show-secrets", "", false,
+		"Do not mask secret values")
+	planCmd.Flags().BoolVarP(&planInJson, "json", "", false,
+		"JSON output")
+	initCommonApiFlags(planCmd)
+	RootCmd.AddCommand(planCmd)
+}
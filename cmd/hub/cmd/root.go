@@ -12,6 +12,7 @@ import (
 	"log"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -20,6 +21,8 @@ import (
 
 	"github.com/epam/hubctl/cmd/hub/config"
 	"github.com/epam/hubctl/cmd/hub/util"
+	// vault:// storage backend
+	_ "github.com/epam/hubctl/cmd/hub/vault"
 )
 
 var initializers []func()
@@ -86,7 +89,9 @@ func init() {
 
 	RootCmd.PersistentFlags().BoolVar(&config.Compressed, "compressed", true, "Write gzip compressed files")
 	RootCmd.PersistentFlags().StringVar(&config.EncryptionMode, "encrypted", "if-key-set",
-		"Write encrypted files if HUB_CRYPTO_PASSWORD, HUB_CRYPTO_AWS_KMS_KEY_ARN, HUB_CRYPTO_AZURE_KEYVAULT_KEY_ID, HUB_CRYPTO_GCP_KMS_KEY_NAME is set. true / false")
+		"Write encrypted files if HUB_CRYPTO_PASSWORD, HUB_CRYPTO_AWS_KMS_KEY_ARN, HUB_CRYPTO_AZURE_KEYVAULT_KEY_ID, HUB_CRYPTO_GCP_KMS_KEY_NAME, HUB_CRYPTO_AGE_RECIPIENTS is set. true / false")
+	RootCmd.PersistentFlags().IntVar(&config.StateHistory, "state-history", 10,
+		"Number of state versions to keep in <state>.history/, 0 to disable. Or set HUB_STATE_HISTORY")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -143,6 +148,35 @@ func initConfig() {
 	if key := viper.GetString("crypto-gcp-kms-key-name"); key != "" {
 		config.CryptoGcpKmsKeyName = key
 	}
+	if recipients := viper.GetString("crypto-age-recipients"); recipients != "" {
+		config.CryptoAgeRecipients = recipients
+	}
+	if identity := viper.GetString("crypto-age-identity"); identity != "" {
+		config.CryptoAgeIdentity = identity
+	}
+	if history := viper.GetString("state-history"); history != "" && !RootCmd.PersistentFlags().Changed("state-history") {
+		if keep, err := strconv.Atoi(history); err == nil && keep >= 0 {
+			config.StateHistory = keep
+		} else {
+			util.Warn("Bad HUB_STATE_HISTORY=%s: must be a non-negative number", history)
+		}
+	}
+	if err := viper.UnmarshalKey("s3.buckets", &config.S3Buckets); err != nil {
+		util.Warn("Unable to parse `s3.buckets` in config file: %v", err)
+	}
+	config.HttpStorage = config.HttpStorageSettings{
+		Username:   viper.GetString("http.username"),
+		Password:   viper.GetString("http.password"),
+		Token:      viper.GetString("http.token"),
+		ClientCert: viper.GetString("http.client-cert"),
+		ClientKey:  viper.GetString("http.client-key"),
+		CaBundle:   viper.GetString("http.ca-bundle"),
+		Insecure:   viper.GetBool("http.insecure"),
+	}
+	config.GitStorage = config.GitStorageSettings{
+		SshKey:           viper.GetString("git.ssh-key"),
+		SshKeyPassphrase: viper.GetString("git.ssh-key-passphrase"),
+	}
 
 	for _, initializer := range initializers {
 		initializer()
@@ -0,0 +1,419 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/state"
+	"github.com/epam/hubctl/cmd/hub/storage"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+var (
+	stateStatusMessage string
+	statePruneKeep     int
+	stateVersion       string
+	stateDiffInJson    bool
+	stateDiffUnified   bool
+	stateShowSecrets   bool
+)
+
+var stateCmd = &cobra.Command{
+	Use: "state <unlock | rm-component | mv-component | set-output | set-status | prune-oplog |" +
+		" history | show | rollback | diff> ...",
+	Short: "Manage state lock, edit state, and state history",
+	Long: `Break stale state lock; edit state file(s) in place; show and restore state versions.
+Every edit takes the state lock and writes a backup copy of the state to <file>.backup-<timestamp>
+before the state is overwritten.
+Last --state-history versions of the state are kept in <file>.history/<timestamp>-<operation id>.`,
+}
+
+var stateUnlockCmd = &cobra.Command{
+	Use:   "unlock -s hub.yaml.state[,s3://bucket/hub.yaml.state]",
+	Short: "Delete stale state lock",
+	Long: `Delete state lock left by an operation that is gone.
+Lock that is not expired yet is deleted only with --force.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return stateUnlock(args)
+	},
+}
+
+var stateRmComponentCmd = &cobra.Command{
+	Use:   "rm-component <component> -s hub.yaml.state[,s3://bucket/hub.yaml.state]",
+	Short: "Remove component from state",
+	Long:  `Remove component state, outputs, and capabilities provided by the component.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return stateRmComponent(args)
+	},
+}
+
+var stateMvComponentCmd = &cobra.Command{
+	Use:   "mv-component <component> <new-name> -s hub.yaml.state[,s3://bucket/hub.yaml.state]",
+	Short: "Rename component in state",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return stateMvComponent(args)
+	},
+}
+
+var stateSetOutputCmd = &cobra.Command{
+	Use:   "set-output <component> <name> <value> -s hub.yaml.state[,s3://bucket/hub.yaml.state]",
+	Short: "Set component output in state",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return stateSetOutput(args)
+	},
+}
+
+var stateSetStatusCmd = &cobra.Command{
+	Use:   "set-status <status> [-c component] [-m message] -s hub.yaml.state[,s3://bucket/hub.yaml.state]",
+	Short: "Set stack or component status in state",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return stateSetStatus(args)
+	},
+}
+
+var statePruneOpLogCmd = &cobra.Command{
+	Use:   "prune-oplog --keep N -s hub.yaml.state[,s3://bucket/hub.yaml.state]",
+	Short: "Remove old entries from state operations log",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return statePruneOpLog(args)
+	},
+}
+
+var stateHistoryCmd = &cobra.Command{
+	Use:   "history -s hub.yaml.state[,s3://bucket/hub.yaml.state]",
+	Short: "List state versions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return stateHistory(args)
+	},
+}
+
+var stateShowCmd = &cobra.Command{
+	Use:   "show [--version <version>] -s hub.yaml.state[,s3://bucket/hub.yaml.state]",
+	Short: "Print state or state version",
+	Long: `Print state file as YAML.
+Version is the name listed by 'state history', an unique prefix of the name, or an operation id.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return stateShow(args)
+	},
+}
+
+var stateRollbackCmd = &cobra.Command{
+	Use:   "rollback --version <version> -s hub.yaml.state[,s3://bucket/hub.yaml.state]",
+	Short: "Restore state version",
+	Long: `Overwrite state with a version from state history.
+Version is the name listed by 'state history', an unique prefix of the name, or an operation id.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return stateRollback(args)
+	},
+}
+
+var stateDiffCmd = &cobra.Command{
+	Use:   "diff <state | version> [state | version] [-s hub.yaml.state[,s3://bucket/hub.yaml.state]]",
+	Short: "Show changes between two states",
+	Long: `Show changes of component parameters, outputs, raw outputs, and statuses, provides, and stack outputs
+from the first state to the second.
+A state is file path(s), or a version listed by 'state history' of the state given by -s.
+The second state defaults to the current state given by -s.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return stateDiff(args)
+	},
+}
+
+func checkStateFiles() (*storage.Files, error) {
+	stateManifests := util.SplitPaths(stateManifestExplicit)
+	if len(stateManifests) == 0 {
+		return nil, errors.New("State file(s) must be specified by -s / --state")
+	}
+	stateFiles, errs := storage.Check(stateManifests, "state")
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("Unable to check state files: %s", util.Errors2(errs...))
+	}
+	return stateFiles, nil
+}
+
+func editState(operation string, edit func(*state.StateManifest) error) error {
+	if err := checkLockFlags(); err != nil {
+		return err
+	}
+	stateFiles, err := checkStateFiles()
+	if err != nil {
+		return err
+	}
+	return state.EditState(stateFiles, operation,
+		time.Duration(lockLease)*time.Second, time.Duration(lockWait)*time.Second, edit)
+}
+
+func stateUnlock(args []string) error {
+	if len(args) != 0 {
+		return errors.New("State Unlock command has no arguments")
+	}
+	stateFiles, err := checkStateFiles()
+	if err != nil {
+		return err
+	}
+	broken, err := storage.BreakLock(stateFiles, config.Force)
+	if len(broken) > 0 {
+		log.Printf("Deleted %s", strings.Join(broken, ", "))
+	} else if err == nil {
+		log.Print("State is not locked")
+	}
+	return err
+}
+
+func stateRmComponent(args []string) error {
+	if len(args) != 1 {
+		return errors.New("State Rm Component command has one argument - component name")
+	}
+	return editState("state rm-component", func(manifest *state.StateManifest) error {
+		return state.RemoveComponent(manifest, args[0])
+	})
+}
+
+func stateMvComponent(args []string) error {
+	if len(args) != 2 {
+		return errors.New("State Mv Component command has two arguments - component name and new name")
+	}
+	return editState("state mv-component", func(manifest *state.StateManifest) error {
+		return state.RenameComponent(manifest, args[0], args[1])
+	})
+}
+
+func stateSetOutput(args []string) error {
+	if len(args) != 3 {
+		return errors.New("State Set Output command has three arguments - component name, output name, and value")
+	}
+	return editState("state set-output", func(manifest *state.StateManifest) error {
+		return state.SetComponentOutput(manifest, args[0], args[1], args[2])
+	})
+}
+
+func stateSetStatus(args []string) error {
+	if len(args) != 1 {
+		return errors.New("State Set Status command has one argument - status")
+	}
+	return editState("state set-status", func(manifest *state.StateManifest) error {
+		return state.SetStatus(manifest, componentName, args[0], stateStatusMessage)
+	})
+}
+
+func statePruneOpLog(args []string) error {
+	if len(args) != 0 {
+		return errors.New("State Prune Op Log command has no arguments")
+	}
+	if statePruneKeep < 0 {
+		return errors.New("--keep must not be negative")
+	}
+	return editState("state prune-oplog", func(manifest *state.StateManifest) error {
+		pruned := state.PruneOperations(manifest, statePruneKeep)
+		if config.Verbose {
+			log.Printf("Removed %d %s from operations log", pruned, util.Plural(pruned, "operation"))
+		}
+		return nil
+	})
+}
+
+func stateHistory(args []string) error {
+	if len(args) != 0 {
+		return errors.New("State History command has no arguments")
+	}
+	stateFiles, err := checkStateFiles()
+	if err != nil {
+		return err
+	}
+	versions, err := state.ListStateVersions(stateFiles)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		log.Print("No state versions found")
+		return nil
+	}
+	for _, version := range versions {
+		operation := version.Operation
+		if operation == "" {
+			operation = "(unknown operation)"
+		} else if version.OperationStatus != "" {
+			operation = fmt.Sprintf("%s - %s", operation, version.OperationStatus)
+		}
+		status := ""
+		if version.Status != "" {
+			status = fmt.Sprintf("; stack %s", version.Status)
+		}
+		fmt.Printf("%s\t%v\t%s%s\n", version.Name, version.Timestamp.Local(), operation, status)
+	}
+	return nil
+}
+
+func stateShow(args []string) error {
+	if len(args) != 0 {
+		return errors.New("State Show command has no arguments")
+	}
+	stateFiles, err := checkStateFiles()
+	if err != nil {
+		return err
+	}
+	var manifest *state.StateManifest
+	if stateVersion != "" {
+		manifest, _, err = state.ParseStateVersion(stateFiles, stateVersion)
+	} else {
+		manifest, err = state.ParseState(stateFiles)
+	}
+	if err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("Unable to marshal state into YAML: %v", err)
+	}
+	os.Stdout.Write(out)
+	return nil
+}
+
+func stateRollback(args []string) error {
+	if len(args) != 0 {
+		return errors.New("State Rollback command has no arguments")
+	}
+	if stateVersion == "" {
+		return errors.New("State version must be specified by --version")
+	}
+	if err := checkLockFlags(); err != nil {
+		return err
+	}
+	stateFiles, err := checkStateFiles()
+	if err != nil {
+		return err
+	}
+	return state.RollbackState(stateFiles, stateVersion,
+		time.Duration(lockLease)*time.Second, time.Duration(lockWait)*time.Second)
+}
+
+// parseStateRef parses state version of the state files, if set, or state file(s)
+func parseStateRef(stateFiles *storage.Files, ref string) (*state.StateManifest, error) {
+	var versionErr error
+	if stateFiles != nil {
+		manifest, _, err := state.ParseStateVersion(stateFiles, ref)
+		if err == nil {
+			return manifest, nil
+		}
+		versionErr = err
+	}
+	files, errs := storage.Check(util.SplitPaths(ref), "state")
+	if len(errs) > 0 {
+		errs = append([]error{versionErr}, errs...)
+		return nil, fmt.Errorf("Unable to check state files: %s", util.Errors2(errs...))
+	}
+	manifest, err := state.ParseState(files)
+	if err != nil && versionErr != nil {
+		return nil, errors.New(util.Errors2(versionErr, err))
+	}
+	return manifest, err
+}
+
+func stateDiff(args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errors.New("State Diff command has one or two arguments - states or state versions to compare")
+	}
+	if stateDiffInJson && stateDiffUnified {
+		return errors.New("Only one of --json, --unified could be specified")
+	}
+	var stateFiles *storage.Files
+	if stateManifestExplicit != "" {
+		var err error
+		stateFiles, err = checkStateFiles()
+		if err != nil {
+			return err
+		}
+	} else if len(args) == 1 {
+		return errors.New("State file(s) must be specified by -s / --state to compare with")
+	}
+
+	prev, err := parseStateRef(stateFiles, args[0])
+	if err != nil {
+		return err
+	}
+	var curr *state.StateManifest
+	currLabel := stateManifestExplicit
+	if len(args) == 2 {
+		curr, err = parseStateRef(stateFiles, args[1])
+		currLabel = args[1]
+	} else {
+		curr, err = state.ParseState(stateFiles)
+	}
+	if err != nil {
+		return err
+	}
+
+	if stateDiffUnified {
+		diff, err := state.UnifiedStateDiff(curr, prev, currLabel, args[0], stateShowSecrets)
+		if err != nil {
+			return err
+		}
+		fmt.Print(diff)
+		return nil
+	}
+	diff := state.DiffState(curr, prev, stateShowSecrets)
+	if stateDiffInJson {
+		out, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Unable to marshal state diff into JSON: %v", err)
+		}
+		os.Stdout.Write(out)
+		os.Stdout.Write([]byte("\n"))
+		return nil
+	}
+	state.PrintStateDiff(diff)
+	return nil
+}
+
+func init() {
+	stateCmd.PersistentFlags().StringVarP(&stateManifestExplicit, "state", "s", "",
+		"Path to state file(s), for example hub.yaml.state,s3://bucket/hub.yaml.state")
+
+	for _, cmd := range []*cobra.Command{stateRmComponentCmd, stateMvComponentCmd,
+		stateSetOutputCmd, stateSetStatusCmd, statePruneOpLogCmd, stateRollbackCmd} {
+		initLockFlags(cmd)
+	}
+	stateSetStatusCmd.Flags().StringVarP(&componentName, "component", "c", "",
+		"Component to set status of (default to stack status)")
+	stateSetStatusCmd.Flags().StringVarP(&stateStatusMessage, "message", "m", "",
+		"Status message")
+	statePruneOpLogCmd.Flags().IntVarP(&statePruneKeep, "keep", "", 10,
+		"Number of most recent operations to keep")
+	stateShowCmd.Flags().StringVarP(&stateVersion, "version", "", "",
+		"State version to print (default to current state)")
+	stateRollbackCmd.Flags().StringVarP(&stateVersion, "version", "", "",
+		"State version to restore")
+	stateDiffCmd.Flags().BoolVarP(&stateDiffInJson, "json", "", false,
+		"JSON output")
+	stateDiffCmd.Flags().BoolVarP(&stateDiffUnified, "unified", "u", false,
+		"Unified diff output")
+	stateDiffCmd.Flags().BoolVarP(&stateShowSecrets, "show-secrets", "", false,
+		"Show secret values")
+
+	stateCmd.AddCommand(stateUnlockCmd)
+	stateCmd.AddCommand(stateRmComponentCmd)
+	stateCmd.AddCommand(stateMvComponentCmd)
+	stateCmd.AddCommand(stateSetOutputCmd)
+	stateCmd.AddCommand(stateSetStatusCmd)
+	stateCmd.AddCommand(statePruneOpLogCmd)
+	stateCmd.AddCommand(stateHistoryCmd)
+	stateCmd.AddCommand(stateShowCmd)
+	stateCmd.AddCommand(stateRollbackCmd)
+	stateCmd.AddCommand(stateDiffCmd)
+	RootCmd.AddCommand(stateCmd)
+}
@@ -236,12 +236,14 @@ func elaborate(manifestFilename string, parametersFilenames []string, overrides
 		elaborated.Components = mergeComponentsRefs(parentBaseDir, parentComponentsBaseDir,
 			fromStackManifest.Components, stackManifest.Components)
 		elaborated.Lifecycle = mergeLifecycle(fromStackManifest.Lifecycle, stackManifest.Lifecycle)
+		elaborated.Hooks = append(append([]manifest.Hook{}, fromStackManifest.Hooks...), stackManifest.Hooks...)
 		elaborated.Outputs = mergeOutputs(fromStackManifest.Outputs, stackManifest.Outputs)
 		componentsManifests = mergeComponentsManifests(fromStackComponentsManifests, componentsManifests)
 		elaborated.Platform.Provides = util.MergeUnique(fromStackManifest.Platform.Provides, stackManifest.Platform.Provides)
 	} else {
 		elaborated.Components = stackManifest.Components
 		elaborated.Lifecycle = stackManifest.Lifecycle
+		elaborated.Hooks = stackManifest.Hooks
 		elaborated.Outputs = stackManifest.Outputs
 		elaborated.Platform.Provides = stackManifest.Platform.Provides
 	}
@@ -39,15 +39,50 @@ var (
 	Compressed              bool
 	Encrypted               bool
 	EncryptionMode          string
+	StateHistory            int
 
 	CryptoPassword           string
 	CryptoAwsKmsKeyArn       string
 	CryptoAzureKeyVaultKeyId string
 	CryptoGcpKmsKeyName      string
+	CryptoAgeRecipients      string
+	CryptoAgeIdentity        string
+
+	// S3-compatible storage settings per bucket from config file `s3.buckets`
+	S3Buckets map[string]S3Bucket
+	// HTTP(S) storage authentication from config file `http` or HUB_HTTP_* environment variables
+	HttpStorage HttpStorageSettings
+	// Git storage SSH key from config file `git` or HUB_GIT_* environment variables, ssh-agent otherwise
+	GitStorage GitStorageSettings
 
 	GitBinDefault = "/usr/bin/git"
 )
 
+type S3Bucket struct {
+	Endpoint  string
+	Region    string
+	PathStyle bool
+	Insecure  bool
+	CaBundle  string
+	AccessKey string
+	SecretKey string
+}
+
+type HttpStorageSettings struct {
+	Username   string
+	Password   string
+	Token      string
+	ClientCert string
+	ClientKey  string
+	CaBundle   string
+	Insecure   bool
+}
+
+type GitStorageSettings struct {
+	SshKey           string
+	SshKeyPassphrase string
+}
+
 func Update() {
 	if LogDestination == "stdout" {
 		log.SetOutput(os.Stdout)
@@ -67,14 +102,15 @@ func Update() {
 
 	switch EncryptionMode {
 	case "true":
-		if CryptoPassword == "" && CryptoAwsKmsKeyArn == "" && CryptoAzureKeyVaultKeyId == "" && CryptoGcpKmsKeyName == "" {
-			log.Fatal("For --encrypted=true, set HUB_CRYPTO_PASSWORD='random password' or\n\tHUB_CRYPTO_AWS_KMS_KEY_ARN='arn:aws:kms:...' or\n\tHUB_CRYPTO_AZURE_KEYVAULT_KEY_ID='https://*.vault.azure.net/keys/...' or\n\tHUB_CRYPTO_GCP_KMS_KEY_NAME='projects/*/locations/*/keyRings/my-key-ring/cryptoKeys/my-key'")
+		if CryptoPassword == "" && CryptoAwsKmsKeyArn == "" && CryptoAzureKeyVaultKeyId == "" && CryptoGcpKmsKeyName == "" && CryptoAgeRecipients == "" {
+			log.Fatal("For --encrypted=true, set HUB_CRYPTO_PASSWORD='random password' or\n\tHUB_CRYPTO_AWS_KMS_KEY_ARN='arn:aws:kms:...' or\n\tHUB_CRYPTO_AZURE_KEYVAULT_KEY_ID='https://*.vault.azure.net/keys/...' or\n\tHUB_CRYPTO_GCP_KMS_KEY_NAME='projects/*/locations/*/keyRings/my-key-ring/cryptoKeys/my-key' or\n\tHUB_CRYPTO_AGE_RECIPIENTS='age1...,age1...'")
 		}
 		Encrypted = true
 	case "false":
 		Encrypted = false
 	case "if-key-set":
-		Encrypted = CryptoPassword != "" || CryptoAwsKmsKeyArn != "" || CryptoAzureKeyVaultKeyId != "" || CryptoGcpKmsKeyName != ""
+		Encrypted = CryptoPassword != "" || CryptoAwsKmsKeyArn != "" || CryptoAzureKeyVaultKeyId != "" || CryptoGcpKmsKeyName != "" ||
+			CryptoAgeRecipients != ""
 	default:
 		log.Fatalf("Unknown --encrypted `%s`", EncryptionMode)
 	}
@@ -0,0 +1,96 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// ageKey returns new data key encrypted to age X25519 recipients, or decrypts the key with the identity;
+// the encrypted key is prefixed by its length
+func ageKey(recipientsList, identity string, blob []byte) ([]byte, []byte, error) {
+	if len(blob) > 0 {
+		key, err := ageDecryptKey(identity, blob[2:])
+		return key, blob, err
+	}
+
+	var recipients []age.Recipient
+	for _, recipient := range strings.FieldsFunc(recipientsList, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		parsed, err := age.ParseX25519Recipient(recipient)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Bad age recipient `%s`: %v", recipient, err)
+		}
+		recipients = append(recipients, parsed)
+	}
+	if len(recipients) == 0 {
+		return nil, nil, errors.New("No age recipients")
+	}
+	key := make([]byte, aes256KeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	var encrypted bytes.Buffer
+	encrypted.Write([]byte{0, 0})
+	writer, err := age.Encrypt(&encrypted, recipients...)
+	if err != nil {
+		return nil, nil, err
+	}
+	writer.Write(key)
+	if err := writer.Close(); err != nil {
+		return nil, nil, err
+	}
+	blob = encrypted.Bytes()
+	if len(blob)-2 > 0xffff {
+		return nil, nil, fmt.Errorf("Too many age recipients: encrypted key size %d", len(blob)-2)
+	}
+	binary.BigEndian.PutUint16(blob, uint16(len(blob)-2))
+	return key, blob, nil
+}
+
+// ageDecryptKey decrypts the key with identity that is an AGE-SECRET-KEY-1... or a file
+func ageDecryptKey(identity string, encryptedKey []byte) ([]byte, error) {
+	var identities []age.Identity
+	if strings.HasPrefix(identity, "AGE-SECRET-KEY-") {
+		parsed, err := age.ParseX25519Identity(identity)
+		if err != nil {
+			return nil, fmt.Errorf("Bad age identity: %v", err)
+		}
+		identities = append(identities, parsed)
+	} else {
+		file, err := os.Open(identity)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to open age identity file: %v", err)
+		}
+		defer file.Close()
+		identities, err = age.ParseIdentities(file)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse age identity file `%s`: %v", identity, err)
+		}
+	}
+	reader, err := age.Decrypt(bytes.NewReader(encryptedKey), identities...)
+	if err != nil {
+		return nil, err
+	}
+	key, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != aes256KeySize {
+		return nil, fmt.Errorf("Bad age encrypted key size %d", len(key))
+	}
+	return key, nil
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package crypto
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+)
+
+func resetEncryptionKey() {
+	encryptionVer, encryptionBlob, encryptionKey = 0, nil, nil
+}
+
+func TestAgeEncryption(t *testing.T) {
+	alice, _ := age.GenerateX25519Identity()
+	bob, _ := age.GenerateX25519Identity()
+	eve, _ := age.GenerateX25519Identity()
+	config.CryptoAgeRecipients = alice.Recipient().String() + ", " + bob.Recipient().String()
+	defer func() {
+		config.CryptoAgeRecipients = ""
+		config.CryptoAgeIdentity = ""
+		resetEncryptionKey()
+	}()
+	resetEncryptionKey()
+
+	data := []byte("kind: state\nstatus: deployed\n")
+	encrypted, err := Encrypt(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, IsEncryptedData(encrypted))
+	assert.Equal(t, byte(encryptionV5MarkerByte1), encrypted[1])
+
+	resetEncryptionKey()
+	config.CryptoAgeRecipients = ""
+	_, err = Decrypt(encrypted)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "HUB_CRYPTO_AGE_IDENTITY")
+	}
+
+	config.CryptoAgeIdentity = alice.String()
+	decrypted, err := Decrypt(encrypted)
+	if assert.NoError(t, err) {
+		assert.Equal(t, data, decrypted)
+	}
+
+	identityFile := filepath.Join(t.TempDir(), "keys.txt")
+	os.WriteFile(identityFile, []byte("# bob\n"+bob.String()+"\n"), 0600)
+	config.CryptoAgeIdentity = identityFile
+	decrypted, err = Decrypt(encrypted)
+	if assert.NoError(t, err) {
+		assert.Equal(t, data, decrypted)
+	}
+
+	config.CryptoAgeIdentity = eve.String()
+	_, err = Decrypt(encrypted)
+	assert.Error(t, err)
+
+	// tampered key is authenticated
+	config.CryptoAgeIdentity = alice.String()
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(tampered)/2] ^= 1
+	_, err = Decrypt(tampered)
+	assert.Error(t, err)
+}
+
+func TestAgeOverhead(t *testing.T) {
+	identity, _ := age.GenerateX25519Identity()
+	config.CryptoAgeRecipients = identity.Recipient().String()
+	defer func() {
+		config.CryptoAgeRecipients = ""
+		resetEncryptionKey()
+	}()
+	resetEncryptionKey()
+
+	data := []byte(strings.Repeat("state", 100))
+	encrypted, err := Encrypt(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, len(data)+EncryptionV5Overhead, len(encrypted))
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha1"
+	"encoding/binary"
 	"errors"
 	"fmt"
 
@@ -31,27 +32,33 @@ const (
 	// V2 is AWS KMS
 	// V3 is Azure KeyVault
 	// V4 is GCP KMS
+	// V5 is age X25519 recipients, encrypted key size varies with the number of recipients
 	encryptionMarkerByte0        = '\x26'
 	encryptionV1MarkerByte1      = '\x01'
 	encryptionV2MarkerByte1      = '\x02'
 	encryptionV3MarkerByte1      = '\x03'
 	encryptionV4MarkerByte1      = '\x04'
+	encryptionV5MarkerByte1      = '\x05'
 	encryptionV1SaltLen          = 8
 	encryptionNonceLen           = 12
 	encryptionV2EncryptedBlobLen = 184 // encrypted AES256 key and 152 bytes of fixed-size AWS KMS meta
 	encryptionV3EncryptedBlobLen = 256 // RSA-OAEP-256
 	encryptionV4EncryptedBlobLen = 113 // encrypted AES256 key and 81 bytes of fixed-size GCP KMS meta
+	encryptionV5EncryptedBlobLen = 234 // length-prefixed age header and payload for a single recipient
 	encryptionMacLen             = 16
 
 	EncryptionV1Overhead = 2 + encryptionV1SaltLen + encryptionNonceLen + encryptionMacLen
 	EncryptionV2Overhead = 2 + encryptionV2EncryptedBlobLen + encryptionNonceLen + encryptionMacLen
 	EncryptionV3Overhead = 2 + encryptionV3EncryptedBlobLen + encryptionNonceLen + encryptionMacLen
 	EncryptionV4Overhead = 2 + encryptionV4EncryptedBlobLen + encryptionNonceLen + encryptionMacLen
+	EncryptionV5Overhead = 2 + encryptionV5EncryptedBlobLen + encryptionNonceLen + encryptionMacLen
 
 	helpPassword      = "HUB_CRYPTO_PASSWORD='random password'"
 	helpAwsKms        = "HUB_CRYPTO_AWS_KMS_KEY_ARN='arn:aws:kms:...'"
 	helpAzukeKeyvault = "HUB_CRYPTO_AZURE_KEYVAULT_KEY_ID='https://*.vault.azure.net/keys/...'"
 	helpGcpKms        = "HUB_CRYPTO_GCP_KMS_KEY_NAME='projects/*/locations/*/keyRings/my-key-ring/cryptoKeys/my-key'"
+	helpAgeRecipients = "HUB_CRYPTO_AGE_RECIPIENTS='age1...,age1...'"
+	helpAgeIdentity   = "HUB_CRYPTO_AGE_IDENTITY='AGE-SECRET-KEY-1...' or path to age identity file"
 )
 
 var (
@@ -61,13 +68,16 @@ var (
 )
 
 func IsEncryptedData(data []byte) bool {
-	return (len(data) > EncryptionV1Overhead || len(data) > EncryptionV2Overhead || len(data) > EncryptionV3Overhead || len(data) > EncryptionV4Overhead) &&
+	return (len(data) > EncryptionV1Overhead || len(data) > EncryptionV2Overhead || len(data) > EncryptionV3Overhead || len(data) > EncryptionV4Overhead ||
+		len(data) > EncryptionV5Overhead) &&
 		data[0] == encryptionMarkerByte0 &&
-		(data[1] == encryptionV1MarkerByte1 || data[1] == encryptionV2MarkerByte1 || data[1] == encryptionV3MarkerByte1 || data[1] == encryptionV4MarkerByte1)
+		(data[1] == encryptionV1MarkerByte1 || data[1] == encryptionV2MarkerByte1 || data[1] == encryptionV3MarkerByte1 || data[1] == encryptionV4MarkerByte1 ||
+			data[1] == encryptionV5MarkerByte1)
 }
 
 // for password based key the blob is salt
 // for AWS KMS, Azure Key Vault, GCP KMS the blob is encrypted data key
+// for age the blob is length-prefixed data key encrypted to recipients
 // if no blob is supplied then a new key is requested
 // if ver is supplied then it must match envionment setup
 func encryptionKeyInit(ver byte, blob []byte) (byte, []byte, []byte, error) {
@@ -87,6 +97,10 @@ func encryptionKeyInit(ver byte, blob []byte) (byte, []byte, []byte, error) {
 		return 0, nil, nil,
 			fmt.Errorf("Set %s", helpGcpKms)
 	}
+	if ver == encryptionV5MarkerByte1 && config.CryptoAgeIdentity == "" {
+		return 0, nil, nil,
+			fmt.Errorf("Set %s", helpAgeIdentity)
+	}
 	if config.CryptoPassword != "" && (ver == 0 || ver == encryptionV1MarkerByte1) {
 		salt := blob
 		if len(salt) == 0 {
@@ -120,8 +134,15 @@ func encryptionKeyInit(ver byte, blob []byte) (byte, []byte, []byte, error) {
 		}
 		return encryptionV4MarkerByte1, encryptedKey, clearKey, nil
 	}
+	if (config.CryptoAgeRecipients != "" && ver == 0) || ver == encryptionV5MarkerByte1 {
+		clearKey, encryptedKey, err := ageKey(config.CryptoAgeRecipients, config.CryptoAgeIdentity, blob)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		return encryptionV5MarkerByte1, encryptedKey, clearKey, nil
+	}
 	return 0, nil, nil,
-		fmt.Errorf("Set %s or %s or %s", helpPassword, helpAwsKms, helpAzukeKeyvault)
+		fmt.Errorf("Set %s or %s or %s or %s or %s", helpPassword, helpAwsKms, helpAzukeKeyvault, helpGcpKms, helpAgeRecipients)
 }
 
 func maybeEncryptionKeyInit() (byte, []byte, []byte, error) {
@@ -199,6 +220,9 @@ func Decrypt(encrypted []byte) ([]byte, error) {
 	} else if ver == encryptionV4MarkerByte1 {
 		overhead = EncryptionV4Overhead
 		blobLen = encryptionV4EncryptedBlobLen
+	} else if ver == encryptionV5MarkerByte1 {
+		blobLen = 2 + int(binary.BigEndian.Uint16(encrypted[2:4]))
+		overhead = 2 + blobLen + encryptionNonceLen + encryptionMacLen
 	}
 	if len(encrypted) < overhead+aes.BlockSize {
 		return nil, errors.New("Insufficient ciphertext length")
@@ -0,0 +1,100 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+const unixSocketPrefix = "unix:"
+
+var emitter struct {
+	mutex   sync.Mutex
+	out     io.Writer
+	stdout  bool
+	encoder *json.Encoder
+}
+
+// Init sets up events `format` to be written to `destination`: `-` for stdout,
+// `unix:/path/to/socket` for Unix socket, or a file path
+func Init(format, destination string) error {
+	if format == "" {
+		return nil
+	}
+	if format != "json" {
+		return fmt.Errorf("Unsupported events format `%s`; supported formats are: json", format)
+	}
+	var out io.Writer
+	stdout := false
+	switch {
+	case destination == "" || destination == "-":
+		out = os.Stdout
+		stdout = true
+	case strings.HasPrefix(destination, unixSocketPrefix):
+		path := strings.TrimPrefix(strings.TrimPrefix(destination, unixSocketPrefix), "//")
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			return fmt.Errorf("Unable to connect events socket `%s`: %v", path, err)
+		}
+		out = conn
+	default:
+		file, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return fmt.Errorf("Unable to open events file `%s`: %v", destination, err)
+		}
+		out = file
+	}
+	emitter.mutex.Lock()
+	defer emitter.mutex.Unlock()
+	emitter.out = out
+	emitter.stdout = stdout
+	emitter.encoder = json.NewEncoder(out)
+	return nil
+}
+
+func Enabled() bool {
+	emitter.mutex.Lock()
+	defer emitter.mutex.Unlock()
+	return emitter.encoder != nil
+}
+
+// ToStdout returns true if events are written to stdout, thus other output
+// must be sent elsewhere
+func ToStdout() bool {
+	emitter.mutex.Lock()
+	defer emitter.mutex.Unlock()
+	return emitter.stdout
+}
+
+func Emit(event Event) {
+	emitter.mutex.Lock()
+	defer emitter.mutex.Unlock()
+	if emitter.encoder == nil {
+		return
+	}
+	event.Version = SchemaVersion
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	if err := emitter.encoder.Encode(&event); err != nil {
+		util.Warn("Unable to emit `%s` event: %v; events are disabled", event.Type, err)
+		emitter.encoder = nil
+	}
+}
+
+func Seconds(duration time.Duration) float64 {
+	return duration.Truncate(time.Millisecond).Seconds()
+}
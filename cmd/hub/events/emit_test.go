@@ -0,0 +1,58 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package events
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestEmitConformsToSchema(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "events.json")
+	if !assert.Nil(t, Init("json", filename)) {
+		return
+	}
+	defer func() { emitter.encoder = nil }()
+
+	assert.True(t, Enabled())
+	assert.False(t, ToStdout())
+
+	Emit(Event{Type: OperationStart, Verb: "deploy", Operation: "op-1", Stack: "stack", Components: []string{"a", "b"}})
+	Emit(Event{Type: ComponentStart, Verb: "deploy", Operation: "op-1", Component: "a", Index: 1, Total: 2})
+	Emit(Event{Type: Outputs, Component: "a", Outputs: map[string]string{"password": "(masked)"}})
+	Emit(Event{Type: ComponentEnd, Verb: "deploy", Operation: "op-1", Component: "a", Status: "deployed", DurationSeconds: 1.5})
+	Emit(Event{Type: StateWritten, Files: []string{"hub.yaml.state"}, Status: "success"})
+	Emit(Event{Type: OperationEnd, Verb: "deploy", Operation: "op-1", Status: "success"})
+
+	file, err := os.Open(filename)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer file.Close()
+
+	schema := gojsonschema.NewBytesLoader(Schema)
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+		result, err := gojsonschema.Validate(schema, gojsonschema.NewStringLoader(scanner.Text()))
+		if assert.Nil(t, err) {
+			assert.True(t, result.Valid(), "%s: %v", scanner.Text(), result.Errors())
+		}
+	}
+	assert.Equal(t, 6, lines)
+}
+
+func TestInitUnsupportedFormat(t *testing.T) {
+	assert.NotNil(t, Init("yaml", "-"))
+	assert.False(t, Enabled())
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package events emits machine-readable lifecycle events with `--events json`.
+//
+// Each event is a single line JSON object, see events.schema.json. The schema is
+// versioned by `version` field: fields may be added within the same version, but not
+// removed or changed in meaning.
+package events
+
+import (
+	_ "embed"
+	"time"
+)
+
+const SchemaVersion = 1
+
+// Schema is JSON Schema of the events
+//
+//go:embed events.schema.json
+var Schema []byte
+
+const (
+	OperationStart = "operation-start" // verb, operation, stack, components
+	OperationEnd   = "operation-end"   // verb, operation, stack, status, message, durationSeconds
+	ComponentStart = "component-start" // verb, operation, component, index, total
+	ComponentEnd   = "component-end"   // verb, operation, component, status, message, durationSeconds
+	HookStart      = "hook-start"      // component (absent for stack hooks), hook, file
+	HookEnd        = "hook-end"        // component, hook, file, status, message, durationSeconds
+	ReadyCondition = "ready-condition" // component, condition, status: waiting, ready, failed; message
+	Outputs        = "outputs"         // component, outputs - secrets are masked
+	StateWritten   = "state-written"   // files, status, message
+)
+
+type Event struct {
+	Version         int               `json:"version"`
+	Timestamp       time.Time         `json:"timestamp"`
+	Type            string            `json:"type"`
+	Verb            string            `json:"verb,omitempty"`
+	Operation       string            `json:"operation,omitempty"`
+	Stack           string            `json:"stack,omitempty"`
+	Component       string            `json:"component,omitempty"`
+	Index           int               `json:"index,omitempty"`
+	Total           int               `json:"total,omitempty"`
+	Components      []string          `json:"components,omitempty"`
+	Hook            string            `json:"hook,omitempty"`
+	File            string            `json:"file,omitempty"`
+	Condition       string            `json:"condition,omitempty"`
+	Status          string            `json:"status,omitempty"`
+	Message         string            `json:"message,omitempty"`
+	DurationSeconds float64           `json:"durationSeconds,omitempty"`
+	Outputs         map[string]string `json:"outputs,omitempty"`
+	Files           []string          `json:"files,omitempty"`
+}
@@ -12,10 +12,12 @@ import (
 	"io/ioutil"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 
 	"github.com/epam/hubctl/cmd/hub/config"
@@ -58,67 +60,153 @@ func noRoot(path string) string {
 	return strings.TrimLeft(path, "/")
 }
 
-func StatGCS(path string) (int64, time.Time, error) {
+// AnyGeneration makes write unconditional
+const AnyGeneration = "*"
+
+// StatGCS returns object size, modification time, and generation, or os.ErrNotExist
+func StatGCS(path string) (int64, time.Time, string, error) {
 	location, err := url.Parse(path)
 	if err != nil {
-		return 0, time.Time{}, err
+		return 0, time.Time{}, "", err
 	}
 	bucket, err := gcsBucket(location.Host)
 	if err != nil {
-		return 0, time.Time{}, err
+		return 0, time.Time{}, "", err
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), gcsTimeout)
 	defer cancel()
 	attrs, err := bucket.Object(noRoot(location.Path)).Attrs(ctx)
 	if err != nil {
 		if IsNotFound(err) {
-			return 0, time.Time{}, os.ErrNotExist
+			return 0, time.Time{}, "", os.ErrNotExist
 		}
-		return 0, time.Time{}, err
+		return 0, time.Time{}, "", err
 	}
-	return attrs.Size, attrs.Updated, nil
+	return attrs.Size, attrs.Updated, strconv.FormatInt(attrs.Generation, 10), nil
 }
 
-func ReadGCS(path string) ([]byte, error) {
+// ReadGCS returns object body and generation, or os.ErrNotExist
+func ReadGCS(path string) ([]byte, string, error) {
 	location, err := url.Parse(path)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	bucket, err := gcsBucket(location.Host)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), gcsTimeout)
 	defer cancel()
 	reader, err := bucket.Object(noRoot(location.Path)).NewReader(ctx)
 	if err != nil {
-		return nil, err
+		if IsNotFound(err) {
+			return nil, "", os.ErrNotExist
+		}
+		return nil, "", err
 	}
 	defer reader.Close()
 	data, err := ioutil.ReadAll(reader)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to read GCS object `%s`: %v", path, err)
+		return nil, "", fmt.Errorf("Failed to read GCS object `%s`: %v", path, err)
 	}
-	return data, nil
+	return data, strconv.FormatInt(reader.Attrs.Generation, 10), nil
 }
 
-func WriteGCS(path string, body []byte) error {
+// WriteGCS creates the object if generation is empty, replaces the object of the generation,
+// or writes unconditionally if generation is AnyGeneration; returns new generation,
+// or os.ErrExist if the object exists or was modified
+func WriteGCS(path string, body []byte, generation string) (string, error) {
 	location, err := url.Parse(path)
 	if err != nil {
-		return err
+		return "", err
 	}
 	bucket, err := gcsBucket(location.Host)
 	if err != nil {
-		return err
+		return "", err
+	}
+	object := bucket.Object(noRoot(location.Path))
+	switch generation {
+	case AnyGeneration:
+	case "":
+		object = object.If(storage.Conditions{DoesNotExist: true})
+	default:
+		match, err := strconv.ParseInt(generation, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("Bad GCS object `%s` generation `%s`: %v", path, generation, err)
+		}
+		object = object.If(storage.Conditions{GenerationMatch: match})
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), gcsTimeout)
 	defer cancel()
-	writer := bucket.Object(noRoot(location.Path)).NewWriter(ctx)
-	defer writer.Close()
+	writer := object.NewWriter(ctx)
 	written, err := writer.Write(body)
 	if err != nil || written != len(body) {
-		return fmt.Errorf("Failed to write GCS object `%s` (wrote %d of %d bytes): %v",
+		writer.Close()
+		return "", fmt.Errorf("Failed to write GCS object `%s` (wrote %d of %d bytes): %v",
 			path, written, len(body), err)
 	}
+	// preconditions are checked when upload is finalized
+	if err := writer.Close(); err != nil {
+		if IsPreconditionFailed(err) {
+			return "", os.ErrExist
+		}
+		return "", fmt.Errorf("Failed to write GCS object `%s`: %v", path, err)
+	}
+	return strconv.FormatInt(writer.Attrs().Generation, 10), nil
+}
+
+// DeleteGCS deletes the object of the generation, if set
+func DeleteGCS(path string, generation string) error {
+	location, err := url.Parse(path)
+	if err != nil {
+		return err
+	}
+	bucket, err := gcsBucket(location.Host)
+	if err != nil {
+		return err
+	}
+	object := bucket.Object(noRoot(location.Path))
+	if generation != "" {
+		match, err := strconv.ParseInt(generation, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Bad GCS object `%s` generation `%s`: %v", path, generation, err)
+		}
+		object = object.If(storage.Conditions{GenerationMatch: match})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gcsTimeout)
+	defer cancel()
+	if err := object.Delete(ctx); err != nil {
+		if IsPreconditionFailed(err) {
+			return os.ErrExist
+		}
+		return fmt.Errorf("Failed to delete GCS object `%s`: %v", path, err)
+	}
 	return nil
 }
+
+// ListGCS returns paths of objects under the prefix
+func ListGCS(prefix string) ([]string, error) {
+	location, err := url.Parse(prefix)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := gcsBucket(location.Host)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gcsTimeout)
+	defer cancel()
+	var paths []string
+	objects := bucket.Objects(ctx, &storage.Query{Prefix: noRoot(location.Path)})
+	for {
+		attrs, err := objects.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list GCS objects `%s`: %v", prefix, err)
+		}
+		paths = append(paths, fmt.Sprintf("gs://%s/%s", location.Host, attrs.Name))
+	}
+	return paths, nil
+}
@@ -6,8 +6,19 @@
 
 package gcp
 
-import "cloud.google.com/go/storage"
+import (
+	"errors"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
 
 func IsNotFound(err error) bool {
 	return err == storage.ErrObjectNotExist
 }
+
+func IsPreconditionFailed(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed
+}
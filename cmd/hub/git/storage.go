@@ -0,0 +1,443 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	billyUtil "github.com/go-git/go-billy/v5/util"
+	goGit "github.com/go-git/go-git/v5"
+	goGitConfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/storage"
+)
+
+// repoBackend keeps files in a Git repository branch: git+ssh://git@host/org/repo.git//path/to/file
+// or git+file:///srv/repo.git//path/to/file, with optional ?ref=branch, remote HEAD by default.
+// Every write is a commit that records the operation; push conflicts are resolved by re-applying
+// the change on top of the fetched branch. Version is the file blob hash.
+type repoBackend struct {
+	mutex sync.Mutex
+	repos map[string]*stateRepo
+}
+
+type stateRepo struct {
+	mutex  sync.Mutex
+	remote string
+	branch string
+	repo   *goGit.Repository
+}
+
+type repoPath struct {
+	remote string
+	file   string
+	ref    string
+	// base and query rebuild storage path of another file in the repo
+	base  string
+	query string
+}
+
+const (
+	defaultBranch   = "master"
+	pushRetries     = 10
+	pushRetryPeriod = 500 * time.Millisecond
+)
+
+func init() {
+	backend := &repoBackend{repos: make(map[string]*stateRepo)}
+	storage.RegisterBackend("git+ssh", backend)
+	storage.RegisterBackend("git+file", backend)
+}
+
+func parseRepoPath(path string) (*repoPath, error) {
+	location, query := path, ""
+	if i := strings.Index(path, "?"); i >= 0 {
+		location, query = path[:i], path[i:]
+	}
+	parsed := &repoPath{query: query}
+	if query != "" {
+		for _, param := range strings.Split(query[1:], "&") {
+			kv := strings.SplitN(param, "=", 2)
+			if len(kv) != 2 || kv[0] != "ref" {
+				return nil, fmt.Errorf("Git storage path `%s` supports `ref=` parameter only", path)
+			}
+			parsed.ref = kv[1]
+		}
+	}
+	remote := strings.TrimPrefix(location, "git+")
+	scheme := strings.Index(remote, "://")
+	sep := -1
+	if scheme > 0 {
+		sep = strings.Index(remote[scheme+3:], "//")
+	}
+	if sep < 0 || !strings.HasPrefix(location, "git+") {
+		return nil, fmt.Errorf("Git storage path `%s` must be git+ssh://host/repo.git//path or git+file:///dir/repo.git//path", path)
+	}
+	sep += scheme + 3
+	parsed.remote = remote[:sep]
+	parsed.file = remote[sep+2:]
+	parsed.base = "git+" + remote[:sep+2]
+	if parsed.remote == "file://" || strings.HasSuffix(parsed.remote, "/") {
+		return nil, fmt.Errorf("Git storage path `%s` has no repository", path)
+	}
+	return parsed, nil
+}
+
+func (p *repoPath) pathOf(file string) string {
+	return p.base + file + p.query
+}
+
+func storageAuth(remote string) (transport.AuthMethod, error) {
+	if !strings.HasPrefix(remote, "ssh://") || config.GitStorage.SshKey == "" {
+		return nil, nil
+	}
+	endpoint, err := transport.NewEndpoint(remote)
+	if err != nil {
+		return nil, err
+	}
+	user := endpoint.User
+	if user == "" {
+		user = "git"
+	}
+	auth, err := ssh.NewPublicKeysFromFile(user, config.GitStorage.SshKey, config.GitStorage.SshKeyPassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load Git storage SSH key: %v", err)
+	}
+	return auth, nil
+}
+
+// open returns in-memory clone of the repository, cloned once per process
+func (b *repoBackend) open(path *repoPath) (*stateRepo, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	key := path.remote + "?ref=" + path.ref
+	if repo, exist := b.repos[key]; exist {
+		return repo, nil
+	}
+	auth, err := storageAuth(path.remote)
+	if err != nil {
+		return nil, err
+	}
+	options := &goGit.CloneOptions{
+		URL:          path.remote,
+		Auth:         auth,
+		SingleBranch: true,
+		Tags:         goGit.NoTags,
+	}
+	if path.ref != "" {
+		options.ReferenceName = plumbing.NewBranchReferenceName(path.ref)
+	}
+	if config.Debug {
+		log.Printf("Cloning Git storage repo `%s`", path.remote)
+	}
+	repo, err := goGit.Clone(memory.NewStorage(), memfs.New(), options)
+	var branch string
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		branch = path.ref
+		if branch == "" {
+			branch = defaultBranch
+		}
+		repo, err = initRepo(path.remote, branch)
+	} else if err == nil {
+		var head *plumbing.Reference
+		head, err = repo.Head()
+		if err == nil {
+			branch = head.Name().Short()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to clone Git storage repo `%s`: %v", path.remote, err)
+	}
+	stateRepo := &stateRepo{remote: path.remote, branch: branch, repo: repo}
+	b.repos[key] = stateRepo
+	return stateRepo, nil
+}
+
+func initRepo(remote, branch string) (*goGit.Repository, error) {
+	repo, err := goGit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		return nil, err
+	}
+	_, err = repo.CreateRemote(&goGitConfig.RemoteConfig{Name: remoteName, URLs: []string{remote}})
+	if err != nil {
+		return nil, err
+	}
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(branch))
+	return repo, repo.Storer.SetReference(head)
+}
+
+// fetch updates remote branch and returns its head commit, or nil if the branch is empty
+func (r *stateRepo) fetch() (*object.Commit, error) {
+	auth, err := storageAuth(r.remote)
+	if err != nil {
+		return nil, err
+	}
+	remoteRef := plumbing.NewRemoteReferenceName(remoteName, r.branch)
+	err = r.repo.Fetch(&goGit.FetchOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []goGitConfig.RefSpec{goGitConfig.RefSpec(fmt.Sprintf("+%s:%s", plumbing.NewBranchReferenceName(r.branch), remoteRef))},
+		Auth:       auth,
+		Tags:       goGit.NoTags,
+	})
+	if err != nil && err != goGit.NoErrAlreadyUpToDate &&
+		!errors.Is(err, transport.ErrEmptyRemoteRepository) && !errors.Is(err, goGit.NoMatchingRefSpecError{}) {
+		return nil, fmt.Errorf("Unable to fetch Git storage repo `%s`: %v", r.remote, err)
+	}
+	ref, err := r.repo.Reference(remoteRef, true)
+	if err != nil {
+		if err == plumbing.ErrReferenceNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return r.repo.CommitObject(ref.Hash())
+}
+
+func fileAt(head *object.Commit, name string) (*object.File, error) {
+	if head == nil {
+		return nil, os.ErrNotExist
+	}
+	file, err := head.File(name)
+	if err == object.ErrFileNotFound {
+		return nil, os.ErrNotExist
+	}
+	return file, err
+}
+
+func (b *repoBackend) file(path string) (*object.File, *object.Commit, error) {
+	parsed, err := parseRepoPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	repo, err := b.open(parsed)
+	if err != nil {
+		return nil, nil, err
+	}
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	head, err := repo.fetch()
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := fileAt(head, parsed.file)
+	return file, head, err
+}
+
+func (b *repoBackend) Stat(path string) (int64, time.Time, string, error) {
+	file, head, err := b.file(path)
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+	return file.Size, head.Committer.When, file.Hash.String(), nil
+}
+
+func (b *repoBackend) Read(path string) ([]byte, string, error) {
+	file, _, err := b.file(path)
+	if err != nil {
+		return nil, "", err
+	}
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, "", err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("Unable to read `%s`: %v", path, err)
+	}
+	return data, file.Hash.String(), nil
+}
+
+func (b *repoBackend) Write(path string, data []byte, version string) (string, error) {
+	err := b.commit(path, func(current string) error {
+		switch {
+		case version == storage.AnyVersion:
+		case version == "" && current != "":
+			return os.ErrExist
+		case version != "" && version != current:
+			return os.ErrExist
+		}
+		return nil
+	}, data)
+	if err != nil {
+		return "", err
+	}
+	return plumbing.ComputeHash(plumbing.BlobObject, data).String(), nil
+}
+
+func (b *repoBackend) Delete(path, version string) error {
+	return b.commit(path, func(current string) error {
+		switch {
+		case current == "":
+			return os.ErrNotExist
+		case version != "" && version != current:
+			return os.ErrExist
+		}
+		return nil
+	}, nil)
+}
+
+func (b *repoBackend) List(prefix string) ([]string, error) {
+	parsed, err := parseRepoPath(prefix)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := b.open(parsed)
+	if err != nil {
+		return nil, err
+	}
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	head, err := repo.fetch()
+	if err != nil || head == nil {
+		return nil, err
+	}
+	files, err := head.Files()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	err = files.ForEach(func(file *object.File) error {
+		if strings.HasPrefix(file.Name, parsed.file) {
+			paths = append(paths, parsed.pathOf(file.Name))
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// commit writes the file, or deletes it if data is nil, on top of the remote branch and pushes
+// the commit; on push conflict the branch is fetched again and the change is re-applied
+func (b *repoBackend) commit(path string, check func(current string) error, data []byte) error {
+	parsed, err := parseRepoPath(path)
+	if err != nil {
+		return err
+	}
+	repo, err := b.open(parsed)
+	if err != nil {
+		return err
+	}
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	auth, err := storageAuth(repo.remote)
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	branch := plumbing.NewBranchReferenceName(repo.branch)
+
+	for attempt := 1; ; attempt++ {
+		head, err := repo.fetch()
+		if err != nil {
+			return err
+		}
+		current := ""
+		if file, err := fileAt(head, parsed.file); err == nil {
+			current = file.Hash.String()
+		} else if err != os.ErrNotExist {
+			return err
+		}
+		if err := check(current); err != nil {
+			return err
+		}
+		if head != nil {
+			err = worktree.Reset(&goGit.ResetOptions{Commit: head.Hash, Mode: goGit.HardReset})
+			if err != nil {
+				return fmt.Errorf("Unable to checkout Git storage repo `%s`: %v", repo.remote, err)
+			}
+		}
+
+		verb := "update"
+		if data == nil {
+			verb = "delete"
+			_, err = worktree.Remove(parsed.file)
+		} else {
+			if current == "" {
+				verb = "add"
+			}
+			err = billyUtil.WriteFile(worktree.Filesystem, parsed.file, data, 0644)
+			if err == nil {
+				_, err = worktree.Add(parsed.file)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to %s `%s`: %v", verb, path, err)
+		}
+		message, author := commitMessage(verb, parsed.file)
+		// deleting the last file leaves the index empty, which go-git refuses to commit by default
+		_, err = worktree.Commit(message, &goGit.CommitOptions{Author: author, AllowEmptyCommits: true})
+		if err != nil {
+			return fmt.Errorf("Unable to commit `%s`: %v", path, err)
+		}
+
+		err = repo.repo.Push(&goGit.PushOptions{
+			RemoteName: remoteName,
+			RefSpecs:   []goGitConfig.RefSpec{goGitConfig.RefSpec(fmt.Sprintf("%s:%s", branch, branch))},
+			Auth:       auth,
+		})
+		if err == nil {
+			if config.Debug {
+				log.Printf("Pushed `%s` to Git storage repo `%s` branch `%s`", parsed.file, repo.remote, repo.branch)
+			}
+			return nil
+		}
+		if !isPushConflict(err) || attempt >= pushRetries {
+			return fmt.Errorf("Unable to push `%s` to Git storage repo `%s`: %v", path, repo.remote, err)
+		}
+		if config.Verbose {
+			log.Printf("Git storage repo `%s` branch `%s` was updated concurrently; retrying", repo.remote, repo.branch)
+		}
+		time.Sleep(pushRetryPeriod + time.Duration(rand.Int63n(int64(pushRetryPeriod))))
+	}
+}
+
+func isPushConflict(err error) bool {
+	if err == goGit.ErrForceNeeded {
+		return true
+	}
+	msg := err.Error()
+	for _, reason := range []string{"non-fast-forward", "fetch first", "cannot lock ref", "failed to update ref"} {
+		if strings.Contains(msg, reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// commitMessage records operation id, verb, and initiator with Git trailers
+func commitMessage(verb, file string) (string, *object.Signature) {
+	author := &object.Signature{Name: "hubctl", Email: "hubctl@localhost", When: time.Now()}
+	operation := storage.CurrentOperation()
+	if operation == nil {
+		return fmt.Sprintf("hubctl: %s %s\n", verb, file), author
+	}
+	if operation.Holder != "" {
+		author.Name = operation.Holder
+		author.Email = fmt.Sprintf("%s@%s", operation.Holder, operation.Host)
+	}
+	return fmt.Sprintf("hubctl %s: %s %s\n\nOperation-Id: %s\nOperation: %s\nInitiator: %s@%s\n",
+		operation.Operation, verb, file,
+		operation.OperationId, operation.Operation, operation.Holder, operation.Host), author
+}
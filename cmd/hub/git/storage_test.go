@@ -0,0 +1,174 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package git
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/storage"
+)
+
+func TestParseRepoPath(t *testing.T) {
+	path, err := parseRepoPath("git+ssh://git@github.com/org/state.git//stacks/dev.state?ref=main")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "ssh://git@github.com/org/state.git", path.remote)
+		assert.Equal(t, "stacks/dev.state", path.file)
+		assert.Equal(t, "main", path.ref)
+		assert.Equal(t, "git+ssh://git@github.com/org/state.git//stacks/dev.state.lock?ref=main",
+			path.pathOf("stacks/dev.state.lock"))
+	}
+	path, err = parseRepoPath("git+file:///srv/state.git//dev.state")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "file:///srv/state.git", path.remote)
+		assert.Equal(t, "dev.state", path.file)
+		assert.Equal(t, "", path.ref)
+	}
+	_, err = parseRepoPath("git+ssh://github.com/org/state.git/dev.state")
+	assert.Error(t, err)
+	_, err = parseRepoPath("git+file:///srv/state.git//dev.state?branch=main")
+	assert.Error(t, err)
+}
+
+func bareRepo(t *testing.T) (*goGit.Repository, string) {
+	dir := t.TempDir()
+	repo, err := goGit.PlainInit(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, "git+file://" + dir + "//"
+}
+
+func TestRepoBackend(t *testing.T) {
+	config.Encrypted = true
+	config.CryptoPassword = "password"
+	defer func() {
+		config.Encrypted = false
+		config.CryptoPassword = ""
+	}()
+	repo, base := bareRepo(t)
+	path := base + "stacks/dev/hub.yaml.state"
+
+	files, errs := storage.Check([]string{path}, "state")
+	if !assert.Empty(t, errs) {
+		return
+	}
+	assert.Equal(t, "git+file", files.Files[0].Kind)
+	assert.False(t, files.Files[0].Exist)
+
+	lock, err := storage.AcquireLock(files, "deploy", "op-1", time.Minute, 0)
+	if !assert.Nil(t, err) {
+		return
+	}
+	written, errs := storage.Write([]byte("kind: state"), files)
+	assert.True(t, written)
+	assert.Empty(t, errs)
+	lock.Release()
+
+	data, _, err := storage.Read(files)
+	assert.Nil(t, err)
+	assert.Equal(t, "kind: state", string(data))
+
+	// the commit carries the operation and the file is encrypted
+	head, err := repo.Head()
+	if !assert.NoError(t, err) {
+		return
+	}
+	commits, err := repo.Log(&goGit.LogOptions{From: head.Hash()})
+	if !assert.NoError(t, err) {
+		return
+	}
+	var messages []string
+	commits.ForEach(func(commit *object.Commit) error {
+		messages = append(messages, commit.Message)
+		if file, err := commit.File("stacks/dev/hub.yaml.state"); err == nil {
+			content, _ := file.Contents()
+			assert.NotContains(t, content, "kind: state")
+		}
+		return nil
+	})
+	// lock, state, unlock
+	assert.Len(t, messages, 3)
+	stateCommit := messages[1]
+	assert.Contains(t, stateCommit, "hubctl deploy: add stacks/dev/hub.yaml.state")
+	assert.Contains(t, stateCommit, "Operation-Id: op-1")
+	assert.Contains(t, stateCommit, "Initiator: ")
+
+	// concurrent write is detected
+	other, _ := storage.Check([]string{path}, "state")
+	_, errs = storage.Write([]byte("kind: state\nstatus: deployed"), other)
+	assert.Empty(t, errs)
+	written, errs = storage.Write([]byte("kind: state\nstatus: failed"), files)
+	assert.False(t, written)
+	assert.Len(t, errs, 1)
+}
+
+func TestRepoBackendRequiresEncryption(t *testing.T) {
+	_, base := bareRepo(t)
+	for _, kind := range []string{"state", "backup bundle"} {
+		files, errs := storage.Check([]string{base + "stacks/dev/hub.yaml.state"}, kind)
+		assert.Nil(t, files)
+		if assert.NotEmpty(t, errs) {
+			assert.Contains(t, errs[0].Error(), "must be encrypted")
+		}
+	}
+}
+
+func TestRepoBackendPushConflict(t *testing.T) {
+	_, base := bareRepo(t)
+	backend := &repoBackend{repos: make(map[string]*stateRepo)}
+	version, err := backend.Write(base+"init", []byte("init"), "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the last file in the tree
+	assert.NoError(t, backend.Delete(base+"init", version))
+	_, err = backend.Write(base+"init", []byte("init"), "")
+	assert.NoError(t, err)
+
+	// separate clones push concurrently and re-apply their changes on conflict
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			clone := &repoBackend{repos: make(map[string]*stateRepo)}
+			_, err := clone.Write(fmt.Sprintf("%sstate-%d", base, i), []byte(fmt.Sprintf("state %d", i)), "")
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	fresh := &repoBackend{repos: make(map[string]*stateRepo)}
+	paths, err := fresh.List(base + "state-")
+	assert.NoError(t, err)
+	assert.Len(t, paths, 4)
+	data, version, err := fresh.Read(base + "state-2")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "state 2", string(data))
+	}
+
+	_, err = fresh.Write(base+"state-2", []byte("exists"), "")
+	assert.Equal(t, os.ErrExist, err)
+	assert.Equal(t, os.ErrExist, fresh.Delete(base+"state-2", "stale"))
+	assert.NoError(t, fresh.Delete(base+"state-2", version))
+	_, _, err = fresh.Read(base + "state-2")
+	assert.Equal(t, os.ErrNotExist, err)
+	paths, _ = fresh.List(base + "state-")
+	for _, path := range paths {
+		assert.False(t, strings.HasSuffix(path, "state-2"))
+	}
+}
@@ -8,6 +8,7 @@ package kube
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -152,11 +153,10 @@ func getFirstEnviron(name ...string) (string, string) {
 	return "", ""
 }
 
-func SetupKubernetes(params parameters.LockedParameters,
-	provider string, outputs parameters.CapturedOutputs,
-	context string, overwrite, keepPems bool) {
+// kubernetesDomain returns stack domain name that is also the name of Kubeconfig context and cluster
+func kubernetesDomain(params parameters.LockedParameters,
+	provider string, outputs parameters.CapturedOutputs) string {
 
-	kubectl := "kubectl"
 	domain, _ := mayOutput(params, outputs, provider, stackDomainOutput)
 	if domain == "" {
 		util.Debug("Parameters from %s are not providing: %s", provider, stackDomainOutput) // try to get domain from environment
@@ -185,8 +185,42 @@ func SetupKubernetes(params parameters.LockedParameters,
 			}
 		}
 	}
+	return domain
+}
+
+// KubeconfigContext returns the name of Kubeconfig context setup by SetupKubernetes for the provider
+// and checks it exists, for kubectl to reuse Kubeconfig without setting it up again
+func KubeconfigContext(params parameters.LockedParameters,
+	provider string, outputs parameters.CapturedOutputs) (string, error) {
+
+	context := kubernetesDomain(params, provider, outputs)
+	if context == "" {
+		return "", errors.New("Unable to find Kubeconfig context: no domain name found")
+	}
+	if err := CheckKubeconfigContext(context); err != nil {
+		return "", err
+	}
+	return context, nil
+}
+
+// CheckKubeconfigContext returns an error if Kubeconfig context does not exist
+func CheckKubeconfigContext(context string) error {
+	outBytes, err := execOutput("kubectl", "config", "get-contexts", context)
+	if err != nil {
+		return fmt.Errorf("Kubeconfig context `%s` is not setup: %v: %s", context, err, strings.TrimSpace(string(outBytes)))
+	}
+	return nil
+}
+
+func SetupKubernetes(params parameters.LockedParameters,
+	provider string, outputs parameters.CapturedOutputs,
+	context string, overwrite, keepPems bool) {
+
+	kubectl := "kubectl"
+	domain := kubernetesDomain(params, provider, outputs)
 	if domain == "" {
 		util.Errors("Unable to setup Kubeconfig: no domain name found")
+		util.Done()
 		os.Exit(1)
 		return
 	}
@@ -396,6 +430,7 @@ func mustOutput(params parameters.LockedParameters,
 	} else {
 		log.Printf("No %v stack parameter(s) are found", candidates)
 	}
+	util.Done()
 	os.Exit(1)
 	return ""
 }
@@ -403,12 +438,12 @@ func mustOutput(params parameters.LockedParameters,
 func writeFile(filename string, content string) {
 	file, err := os.Create(filename)
 	if err != nil {
-		log.Fatalf("Unable to open `%s` for write: %v", filename, err)
+		util.Fatalf("Unable to open `%s` for write: %v", filename, err)
 	}
 	wrote, err := strings.NewReader(content).WriteTo(file)
 	if err != nil || wrote != int64(len(content)) {
 		file.Close()
-		log.Fatalf("Unable to write `%s`: %v", filename, err)
+		util.Fatalf("Unable to write `%s`: %v", filename, err)
 	}
 	file.Close()
 	if config.Debug {
@@ -431,7 +466,7 @@ func execOutput(name string, args ...string) ([]byte, error) {
 func mustExec(name string, args ...string) {
 	_, err := execOutput(name, args...)
 	if err != nil {
-		log.Fatalf("%s failed: %v", name, err)
+		util.Fatalf("%s failed: %v", name, err)
 	}
 }
 
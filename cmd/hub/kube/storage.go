@@ -0,0 +1,469 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package kube
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/crypto"
+	"github.com/epam/hubctl/cmd/hub/storage"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+// secretBackend keeps files in Kubernetes Secrets: k8s://namespace/secret-name[?context=kube-context].
+// Data is gzipped; if it exceeds a Secret size limit, then the rest is chunked into additional
+// Secrets listed in the primary Secret annotation. Version is the primary Secret resourceVersion.
+// Locks - <file>.lock - are kept in Lease objects.
+type secretBackend struct{}
+
+const (
+	secretChunkSize = 768 * 1024
+
+	annotationPrefix     = "hubctl.epam.com/"
+	pathAnnotation       = annotationPrefix + "path"
+	sizeAnnotation       = annotationPrefix + "size"
+	modifiedAnnotation   = annotationPrefix + "modified"
+	chunksAnnotation     = annotationPrefix + "chunks"
+	lockAnnotation       = annotationPrefix + "lock"
+	roleLabel            = annotationPrefix + "role"
+	managedByLabel       = "app.kubernetes.io/managed-by"
+	leaseMicroTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+type kubeMetadata struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace,omitempty"`
+	ResourceVersion   string            `json:"resourceVersion,omitempty"`
+	CreationTimestamp *time.Time        `json:"creationTimestamp,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+}
+
+type kubeObject struct {
+	ApiVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   kubeMetadata      `json:"metadata"`
+	Type       string            `json:"type,omitempty"`
+	Data       map[string][]byte `json:"data,omitempty"`
+	Spec       *leaseSpec        `json:"spec,omitempty"`
+}
+
+type kubeObjectList struct {
+	Items []kubeObject `json:"items"`
+}
+
+type secretLocation struct {
+	namespace string
+	name      string
+	context   string
+	// path without query
+	path  string
+	query string
+	lease bool
+}
+
+func parseSecretPath(path string) (*secretLocation, error) {
+	location, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	if location.Host == "" || strings.Trim(location.Path, "/") == "" {
+		return nil, fmt.Errorf("Kubernetes Secret path `%s` must be k8s://namespace/secret-name", path)
+	}
+	context := ""
+	for name, values := range location.Query() {
+		if name != "context" {
+			return nil, fmt.Errorf("Unknown Kubernetes Secret `%s` parameter; supported is: context", name)
+		}
+		context = values[len(values)-1]
+	}
+	return &secretLocation{
+		namespace: location.Host,
+		name:      objectName(location.Path),
+		context:   context,
+		path:      fmt.Sprintf("%s://%s%s", location.Scheme, location.Host, location.Path),
+		query:     location.RawQuery,
+		lease:     strings.HasSuffix(location.Path, ".lock"),
+	}, nil
+}
+
+// objectName converts file path to Kubernetes object name: history versions, for example,
+// are <name>.history/<version>
+func objectName(path string) string {
+	name := strings.ToLower(strings.Trim(path, "/"))
+	name = strings.NewReplacer("/", ".", "_", "-").Replace(name)
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return name
+}
+
+func (location *secretLocation) kubectl(stdin []byte, args ...string) ([]byte, error) {
+	// KUBECONFIG may be a list of files which kubectl merges itself
+	if filename, err := kubeconfigFilename(); err == nil && !strings.ContainsRune(filename, os.PathListSeparator) {
+		args = append([]string{"--kubeconfig", filename}, args...)
+	}
+	if location.context != "" {
+		args = append([]string{"--context", location.context}, args...)
+	}
+	args = append([]string{"--namespace", location.namespace}, args...)
+	if config.Trace {
+		log.Printf("Executing kubectl %v", args)
+	}
+	cmd := exec.Command("kubectl", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		switch {
+		case strings.Contains(msg, "(NotFound)"):
+			return nil, os.ErrNotExist
+		case strings.Contains(msg, "(AlreadyExists)"), strings.Contains(msg, "(Conflict)"):
+			return nil, os.ErrExist
+		}
+		return nil, fmt.Errorf("kubectl failed: %v: %s", err, msg)
+	}
+	return out, nil
+}
+
+func (location *secretLocation) get(kind, name string) (*kubeObject, error) {
+	out, err := location.kubectl(nil, "get", kind, name, "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+	var object kubeObject
+	if err := json.Unmarshal(out, &object); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s `%s/%s`: %v", kind, location.namespace, name, err)
+	}
+	return &object, nil
+}
+
+// put creates the object if version is empty, replaces the object of the version,
+// or replaces the object unconditionally if version is AnyVersion; returns new resourceVersion
+func (location *secretLocation) put(object *kubeObject, version string) (string, error) {
+	object.Metadata.Namespace = location.namespace
+	object.Metadata.ResourceVersion = ""
+	verb := "create"
+	if version != "" {
+		verb = "replace"
+		if version != storage.AnyVersion {
+			object.Metadata.ResourceVersion = version
+		}
+	}
+	body, err := json.Marshal(object)
+	if err != nil {
+		return "", err
+	}
+	out, err := location.kubectl(body, verb, "--filename", "-", "--output", "jsonpath={.metadata.resourceVersion}")
+	if errors.Is(err, os.ErrNotExist) {
+		if version != storage.AnyVersion {
+			return "", os.ErrExist
+		}
+		out, err = location.kubectl(body, "create", "--filename", "-", "--output", "jsonpath={.metadata.resourceVersion}")
+	}
+	return string(out), err
+}
+
+func (location *secretLocation) delete(kind string, names ...string) error {
+	_, err := location.kubectl(nil, append([]string{"delete", kind}, names...)...)
+	return err
+}
+
+func managedMetadata(name, role string) kubeMetadata {
+	return kubeMetadata{
+		Name:        name,
+		Labels:      map[string]string{managedByLabel: "hubctl", roleLabel: role},
+		Annotations: make(map[string]string),
+	}
+}
+
+// splitChunks returns data chunks and names of additional Secrets for all chunks but first
+func splitChunks(name string, data []byte, size int) ([][]byte, []string) {
+	var chunks [][]byte
+	for len(data) > size {
+		chunks = append(chunks, data[:size])
+		data = data[size:]
+	}
+	chunks = append(chunks, data)
+	digest := fmt.Sprintf("%x", sha256.Sum256(bytes.Join(chunks, nil)))[:10]
+	var names []string
+	for i := 1; i < len(chunks); i++ {
+		names = append(names, fmt.Sprintf("%s-%s-%d", name, digest, i))
+	}
+	return chunks, names
+}
+
+func chunkNames(secret *kubeObject) []string {
+	if chunks := secret.Metadata.Annotations[chunksAnnotation]; chunks != "" {
+		return strings.Split(chunks, ",")
+	}
+	return nil
+}
+
+func (*secretBackend) Stat(path string) (int64, time.Time, string, error) {
+	location, err := parseSecretPath(path)
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+	if location.lease {
+		lease, err := location.get("lease", location.name)
+		if err != nil {
+			return 0, time.Time{}, "", err
+		}
+		renewed, _ := time.Parse(leaseMicroTimeFormat, lease.Spec.RenewTime)
+		return int64(len(lease.Metadata.Annotations[lockAnnotation])), renewed, lease.Metadata.ResourceVersion, nil
+	}
+	secret, err := location.get("secret", location.name)
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+	size, _ := strconv.ParseInt(secret.Metadata.Annotations[sizeAnnotation], 10, 64)
+	modified, err := time.Parse(time.RFC3339Nano, secret.Metadata.Annotations[modifiedAnnotation])
+	if err != nil && secret.Metadata.CreationTimestamp != nil {
+		modified = *secret.Metadata.CreationTimestamp
+	}
+	return size, modified, secret.Metadata.ResourceVersion, nil
+}
+
+func (*secretBackend) Read(path string) ([]byte, string, error) {
+	location, err := parseSecretPath(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if location.lease {
+		lease, err := location.get("lease", location.name)
+		if err != nil {
+			return nil, "", err
+		}
+		return []byte(lease.Metadata.Annotations[lockAnnotation]), lease.Metadata.ResourceVersion, nil
+	}
+	// chunks of the Secret read are deleted by concurrent write that replaced the Secret,
+	// then the new version is read
+	for retry := 0; ; retry++ {
+		data, version, err := location.readChunked()
+		if err == errChunkDeleted && retry < 1 {
+			continue
+		}
+		return data, version, err
+	}
+}
+
+var errChunkDeleted = errors.New("chunk was deleted by concurrent write")
+
+func (location *secretLocation) readChunked() ([]byte, string, error) {
+	secret, err := location.get("secret", location.name)
+	if err != nil {
+		return nil, "", err
+	}
+	data := secret.Data["data"]
+	for _, name := range chunkNames(secret) {
+		chunk, err := location.get("secret", name)
+		if err != nil {
+			if err == os.ErrNotExist {
+				return nil, "", errChunkDeleted
+			}
+			return nil, "", fmt.Errorf("Unable to read Secret `%s/%s` chunk: %v", location.namespace, name, err)
+		}
+		data = append(data, chunk.Data["data"]...)
+	}
+	if size := secret.Metadata.Annotations[sizeAnnotation]; size != strconv.Itoa(len(data)) {
+		return nil, "", fmt.Errorf("Secret `%s/%s` size is %d but must be %s", location.namespace, location.name, len(data), size)
+	}
+	return data, secret.Metadata.ResourceVersion, nil
+}
+
+func (b *secretBackend) Write(path string, data []byte, version string) (string, error) {
+	location, err := parseSecretPath(path)
+	if err != nil {
+		return "", err
+	}
+	if location.lease {
+		return location.putLease(data, version)
+	}
+
+	if !util.IsGzipData(data) && !crypto.IsEncryptedData(data) {
+		data, err = util.Gzip(data)
+		if err != nil {
+			return "", fmt.Errorf("Unable to gzip: %v", err)
+		}
+	}
+	var previousChunks []string
+	if version != "" {
+		current, err := location.get("secret", location.name)
+		if err != nil && err != os.ErrNotExist {
+			return "", err
+		}
+		if current != nil {
+			if version != storage.AnyVersion && current.Metadata.ResourceVersion != version {
+				return "", os.ErrExist
+			}
+			previousChunks = chunkNames(current)
+		} else if version != storage.AnyVersion {
+			return "", os.ErrExist
+		}
+	}
+
+	// chunks are written first under new names so that concurrent reader of the primary Secret
+	// never sees chunks of another version; chunk with the same content may already exist
+	// and be referenced by the primary Secret written by another operation
+	chunks, names := splitChunks(location.name, data, secretChunkSize)
+	var created []string
+	for i, name := range names {
+		chunk := &kubeObject{ApiVersion: "v1", Kind: "Secret", Type: "Opaque",
+			Metadata: managedMetadata(name, "chunk"), Data: map[string][]byte{"data": chunks[i+1]}}
+		if _, err := location.put(chunk, ""); err != nil {
+			if err != os.ErrExist {
+				location.deleteUnreferencedChunks(created)
+				return "", fmt.Errorf("Unable to write Secret `%s/%s` chunk: %v", location.namespace, name, err)
+			}
+		} else {
+			created = append(created, name)
+		}
+	}
+	secret := &kubeObject{ApiVersion: "v1", Kind: "Secret", Type: "Opaque",
+		Metadata: managedMetadata(location.name, "file"), Data: map[string][]byte{"data": chunks[0]}}
+	secret.Metadata.Annotations[pathAnnotation] = location.path
+	secret.Metadata.Annotations[sizeAnnotation] = strconv.Itoa(len(data))
+	secret.Metadata.Annotations[modifiedAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+	if len(names) > 0 {
+		secret.Metadata.Annotations[chunksAnnotation] = strings.Join(names, ",")
+	}
+	newVersion, err := location.put(secret, version)
+	if err != nil {
+		location.deleteUnreferencedChunks(created)
+		return "", err
+	}
+
+	var stale []string
+	for _, name := range previousChunks {
+		if !util.Contains(names, name) {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) > 0 {
+		if err := location.delete("secret", stale...); err != nil {
+			util.Warn("Unable to delete Secret `%s` chunks: %v", location.name, err)
+		}
+	}
+	return newVersion, nil
+}
+
+// deleteUnreferencedChunks deletes chunks created by failed write, except chunks the current
+// primary Secret refers to, as concurrent write of the same content may have reused them
+func (location *secretLocation) deleteUnreferencedChunks(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	if current, err := location.get("secret", location.name); err == nil {
+		referenced := chunkNames(current)
+		var unreferenced []string
+		for _, name := range names {
+			if !util.Contains(referenced, name) {
+				unreferenced = append(unreferenced, name)
+			}
+		}
+		names = unreferenced
+	} else if err != os.ErrNotExist {
+		util.Warn("Unable to read Secret `%s`, chunks are not deleted: %v", location.name, err)
+		return
+	}
+	if len(names) > 0 {
+		if err := location.delete("secret", names...); err != nil {
+			util.Warn("Unable to delete Secret `%s` chunks: %v", location.name, err)
+		}
+	}
+}
+
+func (location *secretLocation) putLease(data []byte, version string) (string, error) {
+	lease := &kubeObject{ApiVersion: "coordination.k8s.io/v1", Kind: "Lease",
+		Metadata: managedMetadata(location.name, "lock"), Spec: &leaseSpec{}}
+	lease.Metadata.Annotations[pathAnnotation] = location.path
+	lease.Metadata.Annotations[lockAnnotation] = string(data)
+	var info storage.LockInfo
+	if err := json.Unmarshal(data, &info); err == nil {
+		lease.Spec.HolderIdentity = fmt.Sprintf("%s@%s", info.Holder, info.Host)
+		if duration := int(time.Until(info.Expires).Seconds()); duration > 0 {
+			lease.Spec.LeaseDurationSeconds = duration
+		}
+		lease.Spec.AcquireTime = info.Acquired.UTC().Format(leaseMicroTimeFormat)
+		lease.Spec.RenewTime = time.Now().UTC().Format(leaseMicroTimeFormat)
+	}
+	return location.put(lease, version)
+}
+
+func (*secretBackend) Delete(path, version string) error {
+	location, err := parseSecretPath(path)
+	if err != nil {
+		return err
+	}
+	kind := "secret"
+	if location.lease {
+		kind = "lease"
+	}
+	// kubectl has no delete preconditions, there is a short window for a concurrent write
+	object, err := location.get(kind, location.name)
+	if err != nil {
+		return err
+	}
+	if version != "" && object.Metadata.ResourceVersion != version {
+		return os.ErrExist
+	}
+	return location.delete(kind, append([]string{location.name}, chunkNames(object)...)...)
+}
+
+func (*secretBackend) List(prefix string) ([]string, error) {
+	location, err := parseSecretPath(prefix)
+	if err != nil {
+		return nil, err
+	}
+	out, err := location.kubectl(nil, "get", "secret", "--selector", roleLabel+"=file", "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+	var list kubeObjectList
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("Unable to parse Secrets list: %v", err)
+	}
+	query := ""
+	if location.query != "" {
+		query = "?" + location.query
+	}
+	var paths []string
+	for _, secret := range list.Items {
+		if path := secret.Metadata.Annotations[pathAnnotation]; strings.HasPrefix(path, location.path) {
+			paths = append(paths, path+query)
+		}
+	}
+	return paths, nil
+}
+
+func init() {
+	storage.RegisterBackend("k8s", &secretBackend{})
+}
@@ -0,0 +1,350 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package kube
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/storage"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+const fakeKubectlEnv = "HUB_TEST_FAKE_KUBECTL_DIR"
+
+func TestMain(m *testing.M) {
+	if dir := os.Getenv(fakeKubectlEnv); dir != "" {
+		os.Exit(fakeKubectl(dir, os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// fakeKubectl is a minimal API server behind kubectl CLI keeping objects in `dir`, with
+// create, replace, and delete semantics on resourceVersion
+func fakeKubectl(dir string, args []string) int {
+	var verb string
+	var positional []string
+	flags := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "--") && i+1 < len(args) {
+			flags[args[i]] = args[i+1]
+			i++
+		} else if verb == "" {
+			verb = args[i]
+		} else {
+			positional = append(positional, args[i])
+		}
+	}
+	fail := func(format string, v ...interface{}) int {
+		fmt.Fprintf(os.Stderr, format+"\n", v...)
+		return 1
+	}
+	filename := func(kind, name string) string {
+		return filepath.Join(dir, fmt.Sprintf("%s.%s.%s.json", flags["--namespace"], strings.ToLower(kind), name))
+	}
+	read := func(kind, name string) (*kubeObject, error) {
+		data, err := os.ReadFile(filename(kind, name))
+		if err != nil {
+			return nil, err
+		}
+		var object kubeObject
+		return &object, json.Unmarshal(data, &object)
+	}
+	write := func(object *kubeObject) string {
+		counter := filepath.Join(dir, "resourceVersion")
+		data, _ := os.ReadFile(counter)
+		version, _ := strconv.Atoi(string(data))
+		object.Metadata.ResourceVersion = strconv.Itoa(version + 1)
+		os.WriteFile(counter, []byte(object.Metadata.ResourceVersion), 0644)
+		data, _ = json.Marshal(object)
+		os.WriteFile(filename(object.Kind, object.Metadata.Name), data, 0644)
+		return object.Metadata.ResourceVersion
+	}
+
+	switch verb {
+	case "get":
+		if len(positional) == 1 {
+			var list kubeObjectList
+			files, _ := filepath.Glob(filename(positional[0], "*"))
+			for _, file := range files {
+				var object kubeObject
+				data, _ := os.ReadFile(file)
+				json.Unmarshal(data, &object)
+				if selector := strings.SplitN(flags["--selector"], "=", 2); object.Metadata.Labels[selector[0]] == selector[1] {
+					list.Items = append(list.Items, object)
+				}
+			}
+			data, _ := json.Marshal(&list)
+			os.Stdout.Write(data)
+			return 0
+		}
+		object, err := read(positional[0], positional[1])
+		if err != nil {
+			return fail("Error from server (NotFound): %s %q not found", positional[0], positional[1])
+		}
+		data, _ := json.Marshal(object)
+		os.Stdout.Write(data)
+		// object replaced by concurrent write right after it was read
+		os.Rename(filename(positional[0], positional[1])+".next", filename(positional[0], positional[1]))
+
+	case "create", "replace":
+		var object kubeObject
+		if err := json.NewDecoder(os.Stdin).Decode(&object); err != nil {
+			return fail("error: %v", err)
+		}
+		current, err := read(object.Kind, object.Metadata.Name)
+		if verb == "create" && err == nil {
+			return fail("Error from server (AlreadyExists): %q already exists", object.Metadata.Name)
+		}
+		if verb == "replace" {
+			if err != nil {
+				return fail("Error from server (NotFound): %q not found", object.Metadata.Name)
+			}
+			if version := object.Metadata.ResourceVersion; version != "" && version != current.Metadata.ResourceVersion {
+				return fail("Error from server (Conflict): Operation cannot be fulfilled on %q: the object has been modified", object.Metadata.Name)
+			}
+		}
+		os.Stdout.Write([]byte(write(&object)))
+
+	case "delete":
+		for _, name := range positional[1:] {
+			if err := os.Remove(filename(positional[0], name)); err != nil {
+				return fail("Error from server (NotFound): %s %q not found", positional[0], name)
+			}
+		}
+
+	default:
+		return fail("error: unknown command %q", verb)
+	}
+	return 0
+}
+
+// withFakeKubectl puts the test binary on PATH as kubectl and returns objects directory
+func withFakeKubectl(t *testing.T) string {
+	bin := t.TempDir()
+	objects := t.TempDir()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := fmt.Sprintf("#!/bin/sh\n%s=%q exec %q \"$@\"\n", fakeKubectlEnv, objects, executable)
+	if err := os.WriteFile(filepath.Join(bin, "kubectl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("KUBECONFIG", filepath.Join(bin, "config"))
+	return objects
+}
+
+func TestParseSecretPath(t *testing.T) {
+	location, err := parseSecretPath("k8s://hub/dev.hub_state.history/20261016T235342.113Z-op?context=kind-dev")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "hub", location.namespace)
+		assert.Equal(t, "dev.hub-state.history.20261016t235342.113z-op", location.name)
+		assert.Equal(t, "kind-dev", location.context)
+		assert.Equal(t, "k8s://hub/dev.hub_state.history/20261016T235342.113Z-op", location.path)
+		assert.False(t, location.lease)
+	}
+	location, err = parseSecretPath("k8s://hub/dev.state.lock")
+	if assert.Nil(t, err) {
+		assert.True(t, location.lease)
+	}
+	_, err = parseSecretPath("k8s://hub")
+	assert.NotNil(t, err)
+	_, err = parseSecretPath("k8s://hub/state?cluster=dev")
+	assert.NotNil(t, err)
+}
+
+func TestSplitChunks(t *testing.T) {
+	chunks, names := splitChunks("state", []byte("0123456789"), 4)
+	assert.Equal(t, [][]byte{[]byte("0123"), []byte("4567"), []byte("89")}, chunks)
+	if assert.Len(t, names, 2) {
+		assert.Regexp(t, "^state-[0-9a-f]{10}-1$", names[0])
+		assert.Regexp(t, "^state-[0-9a-f]{10}-2$", names[1])
+	}
+	assert.Equal(t, []byte("0123456789"), bytes.Join(chunks, nil))
+
+	_, other := splitChunks("state", []byte("0123456780"), 4)
+	assert.NotEqual(t, names, other, "chunks of different data must not overwrite each other")
+
+	chunks, names = splitChunks("state", []byte("0123"), 4)
+	assert.Len(t, chunks, 1)
+	assert.Empty(t, names)
+}
+
+func TestSecretBackend(t *testing.T) {
+	withFakeKubectl(t)
+	backend := &secretBackend{}
+	path := "k8s://hub/dev.state"
+
+	_, _, err := backend.Read(path)
+	assert.Equal(t, os.ErrNotExist, err)
+	v1, err := backend.Write(path, []byte("state 1"), "")
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = backend.Write(path, []byte("again"), "")
+	assert.Equal(t, os.ErrExist, err)
+
+	v2, err := backend.Write(path, []byte("state 2"), v1)
+	assert.Nil(t, err)
+	assert.NotEqual(t, v1, v2)
+	_, err = backend.Write(path, []byte("stale"), v1)
+	assert.Equal(t, os.ErrExist, err, "write of stale resourceVersion must fail")
+
+	data, version, err := backend.Read(path)
+	if assert.Nil(t, err) {
+		assert.Equal(t, v2, version)
+		data, err = util.Gunzip(data)
+		assert.Nil(t, err)
+		assert.Equal(t, "state 2", string(data))
+	}
+	size, _, version, err := backend.Stat(path)
+	if assert.Nil(t, err) {
+		assert.Equal(t, v2, version)
+		assert.True(t, size > 0)
+	}
+
+	// incompressible data is chunked into additional Secrets
+	large := make([]byte, secretChunkSize+1024)
+	rand.Read(large)
+	large, _ = util.Gzip(large)
+	v3, err := backend.Write(path, large, storage.AnyVersion)
+	assert.Nil(t, err)
+	data, _, err = backend.Read(path)
+	if assert.Nil(t, err) {
+		assert.Equal(t, large, data)
+	}
+	_, err = backend.Write(path+".history/v1", []byte("history"), "")
+	assert.Nil(t, err)
+	paths, err := backend.List(path)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{path, path + ".history/v1"}, paths)
+
+	assert.Equal(t, os.ErrExist, backend.Delete(path, v2))
+	assert.Nil(t, backend.Delete(path, v3))
+	_, _, err = backend.Read(path)
+	assert.Equal(t, os.ErrNotExist, err)
+}
+
+func TestSecretBackendLease(t *testing.T) {
+	objects := withFakeKubectl(t)
+	backend := &secretBackend{}
+	path := "k8s://hub/dev.state.lock"
+	lease := func() *leaseSpec {
+		var object kubeObject
+		data, _ := os.ReadFile(filepath.Join(objects, "hub.lease.dev.state.lock.json"))
+		json.Unmarshal(data, &object)
+		return object.Spec
+	}
+	lockInfo := func(operationId string, expires time.Duration) []byte {
+		data, _ := json.Marshal(&storage.LockInfo{Holder: "ci", Host: "runner", OperationId: operationId,
+			Acquired: time.Now(), Expires: time.Now().Add(expires)})
+		return data
+	}
+
+	acquired, err := backend.Write(path, lockInfo("op-1", time.Minute), "")
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = backend.Write(path, lockInfo("op-2", time.Minute), "")
+	assert.Equal(t, os.ErrExist, err, "lease held by another operation")
+	if spec := lease(); assert.NotNil(t, spec) {
+		assert.Equal(t, "ci@runner", spec.HolderIdentity)
+		assert.InDelta(t, 60, spec.LeaseDurationSeconds, 1)
+	}
+
+	renewed, err := backend.Write(path, lockInfo("op-1", 2*time.Minute), acquired)
+	assert.Nil(t, err)
+	assert.InDelta(t, 120, lease().LeaseDurationSeconds, 1)
+	data, version, err := backend.Read(path)
+	if assert.Nil(t, err) {
+		assert.Equal(t, renewed, version)
+		assert.Contains(t, string(data), "op-1")
+	}
+
+	// takeover with the version read succeeds once, the other operation is late
+	_, err = backend.Write(path, lockInfo("op-2", time.Minute), renewed)
+	assert.Nil(t, err)
+	_, err = backend.Write(path, lockInfo("op-3", time.Minute), renewed)
+	assert.Equal(t, os.ErrExist, err)
+	_, err = backend.Write(path, lockInfo("op-1", time.Minute), renewed)
+	assert.Equal(t, os.ErrExist, err, "renew of lost lease must fail")
+
+	// via storage lock
+	assert.Nil(t, backend.Delete(path, ""))
+	t.Setenv(storage.LockEnvVar, "")
+	files, errs := storage.Check([]string{"k8s://hub/dev.state"}, "state")
+	if !assert.Empty(t, errs) {
+		return
+	}
+	lock, err := storage.AcquireLock(files, "deploy", "op-4", time.Minute, 0)
+	if assert.Nil(t, err) {
+		data, _, err := backend.Read(path)
+		assert.Nil(t, err)
+		assert.Contains(t, string(data), "op-4")
+		lock.Release()
+	}
+	_, _, err = backend.Read(path)
+	assert.Equal(t, os.ErrNotExist, err)
+}
+
+func TestSecretBackendChunks(t *testing.T) {
+	objects := withFakeKubectl(t)
+	backend := &secretBackend{}
+	path := "k8s://hub/dev.state"
+	primary := filepath.Join(objects, "hub.secret.dev.state.json")
+	chunks := func() []string {
+		files, _ := filepath.Glob(filepath.Join(objects, "hub.secret.dev.state-*.json"))
+		return files
+	}
+	random := func() []byte {
+		data := make([]byte, secretChunkSize+1024)
+		rand.Read(data)
+		data, _ = util.Gzip(data)
+		return data
+	}
+
+	first := random()
+	v1, err := backend.Write(path, first, "")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Len(t, chunks(), 1)
+	_, err = backend.Write(path, first, "")
+	assert.Equal(t, os.ErrExist, err)
+	assert.Len(t, chunks(), 1, "failed write of the same content must keep chunks in use")
+	_, err = backend.Write(path, random(), "0")
+	assert.Equal(t, os.ErrExist, err)
+	assert.Len(t, chunks(), 1, "failed write must delete chunks it created")
+	data, _, err := backend.Read(path)
+	if assert.Nil(t, err) {
+		assert.Equal(t, first, data)
+	}
+
+	// chunks are deleted by concurrent write between the read of the primary Secret and the chunks
+	previous, _ := os.ReadFile(primary)
+	second := random()
+	_, err = backend.Write(path, second, v1)
+	assert.Nil(t, err)
+	assert.Nil(t, os.Rename(primary, primary+".next"))
+	assert.Nil(t, os.WriteFile(primary, previous, 0644))
+	data, _, err = backend.Read(path)
+	if assert.Nil(t, err) {
+		assert.Equal(t, second, data)
+	}
+}
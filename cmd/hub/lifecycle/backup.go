@@ -7,6 +7,7 @@
 package lifecycle
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -89,7 +90,7 @@ func BackupCreate(request *Request, bundles []string, jsonOutput, allowPartial b
 		componentManifest := manifest.ComponentManifestByRef(componentsManifests, component)
 		if util.Contains(componentManifest.Lifecycle.Verbs, request.Verb) {
 			dir := manifest.ComponentSourceDirFromRef(component, stackBaseDir, componentsBaseDir)
-			impl, _ := probeImplementation(dir, verb, componentManifest)
+			impl, _ := probeImplementation(dir, verb, componentManifest, stackBaseDir)
 			if impl {
 				implementsBackup = append(implementsBackup, manifest.ComponentQualifiedNameFromRef(component))
 			}
@@ -116,6 +117,8 @@ func BackupCreate(request *Request, bundles []string, jsonOutput, allowPartial b
 	if len(errs) > 0 {
 		util.MaybeFatalf("Unable to check state files: %v", util.Errors2(errs...))
 	}
+	defer util.Done()
+	osEnv = append(osEnv, lockState(request, stateFiles, "")...)
 
 	var bundleFiles *storage.Files
 	if len(bundles) > 0 {
@@ -182,12 +185,26 @@ func BackupCreate(request *Request, bundles []string, jsonOutput, allowPartial b
 		prepareComponentRequires(provides, componentManifest, stackParameters, allOutputs, optionalRequires, request.EnabledClouds)
 
 		dir := manifest.ComponentSourceDirFromRef(component, stackBaseDir, componentsBaseDir)
-		stdout, _, err := delegate(verb, component, componentManifest, componentParameters, dir, osEnv, "", stackBaseDir)
+		var toolRawOutputs parameters.RawOutputs
+		stdout, _, _, err := withRetry(context.Background(), componentManifest.Lifecycle.Retry, componentName, verb,
+			func() ([]byte, []byte, error) {
+				stdout, stderr, rawOutputs, err := delegate(context.Background(), verb, component, componentManifest, componentParameters, dir, osEnv, "", stackBaseDir, nil)
+				toolRawOutputs = rawOutputs
+				return stdout, stderr, err
+			})
 
 		var rawOutputs parameters.RawOutputs
 		if len(stdout) > 0 {
 			rawOutputs = parseTextOutput(stdout)
 		}
+		if len(toolRawOutputs) > 0 {
+			if rawOutputs == nil {
+				rawOutputs = make(parameters.RawOutputs)
+			}
+			for k, v := range toolRawOutputs {
+				rawOutputs[k] = v
+			}
+		}
 		status := "error"
 		if err != nil || len(rawOutputs) == 0 {
 			if err == nil {
@@ -251,12 +268,12 @@ func BackupCreate(request *Request, bundles []string, jsonOutput, allowPartial b
 	}
 	bytes, err := marshall(&bundle)
 	if err != nil {
-		log.Fatalf("Unable to marshal backup bundle into %s: %v", format, err)
+		util.Fatalf("Unable to marshal backup bundle into %s: %v", format, err)
 	}
 	if bundleFiles != nil {
 		_, errs := storage.Write(bytes, bundleFiles)
 		if len(errs) > 0 {
-			log.Fatalf("Unable to write backup bundle: %s", util.Errors2(errs...))
+			util.Fatalf("Unable to write backup bundle: %s", util.Errors2(errs...))
 		}
 	} else {
 		os.Stdout.Write([]byte(fmt.Sprintf("--- %s\n", format)))
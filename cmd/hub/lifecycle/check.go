@@ -45,10 +45,10 @@ func checkComponentsSourcesExist(order []string, components []manifest.Component
 		dir := manifest.ComponentSourceDirFromRef(component, stackBaseDir, componentsBaseDir)
 		info, err := os.Stat(dir)
 		if err != nil {
-			log.Fatalf("`%s` source directory for component `%s` not found: %v", dir, compName, err)
+			util.Fatalf("`%s` source directory for component `%s` not found: %v", dir, compName, err)
 		}
 		if !info.IsDir() {
-			log.Fatalf("`%s` source for component `%s` is not a directory", dir, compName)
+			util.Fatalf("`%s` source for component `%s` is not a directory", dir, compName)
 		}
 	}
 }
@@ -58,7 +58,7 @@ func checkLifecycleVerbs(order []string, components []manifest.ComponentRef, com
 	verbs []string, stackBaseDir, componentsBaseDir string,
 	skip func(int, string) bool) {
 
-	optionalVerbs := []string{"backup"}
+	optionalVerbs := []string{"backup", checkVerb}
 	for i, name := range order {
 		if skip != nil && skip(i, name) {
 			continue
@@ -70,7 +70,7 @@ func checkLifecycleVerbs(order []string, components []manifest.ComponentRef, com
 				continue
 			}
 			componentManifest := manifest.ComponentManifestByRef(componentsManifests, component)
-			impl, err := probeImplementation(dir, verb, componentManifest)
+			impl, err := probeImplementation(dir, verb, componentManifest, stackBaseDir)
 			if !impl {
 				msg := fmt.Sprintf("`%s` component in `%s` has no `%s` implementation: %v",
 					manifest.ComponentQualifiedNameFromRef(component), dir, verb, err)
@@ -79,7 +79,7 @@ func checkLifecycleVerbs(order []string, components []manifest.ComponentRef, com
 						log.Print(msg)
 					}
 				} else {
-					log.Fatalf("%s;\n\tTry setting `lifecycle.bare: allow` in component's manifest if it's your intention", msg)
+					util.Fatalf("%s;\n\tTry setting `lifecycle.bare: allow` in component's manifest if it's your intention", msg)
 				}
 			}
 		}
@@ -207,7 +207,7 @@ func checkStateMatch(state *state.StateManifest, elaborate *manifest.Manifest, s
 		if config.Force {
 			util.Warn("%s", msg)
 		} else {
-			log.Fatal(msg)
+			util.Fatalf("%s", msg)
 		}
 	}
 }
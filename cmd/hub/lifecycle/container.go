@@ -0,0 +1,204 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+var (
+	containerRuntimes = []string{"docker", "podman"}
+	// host specific variables are not passed into the container
+	containerOmitEnv = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "PWD", "OLDPWD", "SHLVL", "TMPDIR",
+		"HOSTNAME", "TERM", "_"}
+	// cloud credentials location variables with default path under $HOME, if any;
+	// CLI configuration directories are mounted read-write to allow tokens refresh
+	containerCredentials = []struct {
+		env      string
+		home     string
+		readOnly bool
+	}{
+		{"AWS_SHARED_CREDENTIALS_FILE", ".aws/credentials", true},
+		{"AWS_CONFIG_FILE", ".aws/config", true},
+		{"GOOGLE_APPLICATION_CREDENTIALS", "", true},
+		{"CLOUDSDK_CONFIG", ".config/gcloud", false},
+		{"AZURE_AUTH_LOCATION", "", true},
+		{"AZURE_CONFIG_DIR", ".azure", false},
+	}
+)
+
+// containerImplementation wraps implementation command to execute it in a container
+// via Docker-compatible or Podman CLI
+func containerImplementation(impl *exec.Cmd, container *manifest.ContainerRunner) (*exec.Cmd, error) {
+	runtimeBin, err := containerRuntime(container.Runtime)
+	if err != nil {
+		return nil, err
+	}
+	args, env, err := containerRunArgs(impl, container, filepath.Base(runtimeBin))
+	if err != nil {
+		return nil, err
+	}
+	if config.Debug {
+		log.Printf("Running implementation in container: %s %s", runtimeBin, strings.Join(args, " "))
+	}
+	return &exec.Cmd{
+		Path: runtimeBin,
+		Args: append([]string{runtimeBin}, args...),
+		Dir:  impl.Dir,
+		// values are passed by variable name only, so that secrets are not visible in process list
+		Env: env,
+	}, nil
+}
+
+func containerRuntime(name string) (string, error) {
+	if name != "" {
+		bin, err := exec.LookPath(name)
+		if err != nil {
+			return "", fmt.Errorf("Unable to find container runtime `%s`: %v", name, err)
+		}
+		return bin, nil
+	}
+	for _, runtime := range containerRuntimes {
+		if bin, err := exec.LookPath(runtime); err == nil {
+			return bin, nil
+		}
+	}
+	return "", fmt.Errorf("Unable to find container runtime in PATH, tried: %s", strings.Join(containerRuntimes, ", "))
+}
+
+// containerRunArgs mounts component directory, Kubeconfig, and cloud credentials at the same paths
+// as on the host, so that paths in environment and implementation arguments stay valid;
+// returns runtime arguments and environment
+func containerRunArgs(impl *exec.Cmd, container *manifest.ContainerRunner, runtimeName string) ([]string, []string, error) {
+	if container.Image == "" {
+		return nil, nil, errors.New("lifecycle.container.image is not set")
+	}
+	dir, err := filepath.Abs(impl.Dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	env := envMap(impl.Env)
+
+	args := []string{"run", "--rm", "--workdir", dir}
+	if runtimeName == "podman" {
+		args = append(args, "--userns=keep-id")
+	} else if runtime.GOOS != "windows" {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+	mounted := map[string]bool{dir: true}
+	args = append(args, "--volume", dir+":"+dir)
+	mount := func(path, options string) {
+		if path == "" || mounted[path] {
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			return
+		}
+		mounted[path] = true
+		volume := path + ":" + path
+		if options != "" {
+			volume += ":" + options
+		}
+		args = append(args, "--volume", volume)
+	}
+
+	home := env["HOME"]
+	kubeconfig := env["KUBECONFIG"]
+	if kubeconfig == "" && home != "" {
+		kubeconfig = filepath.Join(home, ".kube", "config")
+		if _, err := os.Stat(kubeconfig); err == nil {
+			env["KUBECONFIG"] = kubeconfig
+		}
+	}
+	// Kubeconfig may refer to TLS certificates next to it
+	for _, filename := range filepath.SplitList(kubeconfig) {
+		mount(filepath.Dir(filename), "")
+	}
+	for _, credentials := range containerCredentials {
+		path := env[credentials.env]
+		if path == "" && credentials.home != "" && home != "" {
+			path = filepath.Join(home, credentials.home)
+			if _, err := os.Stat(path); err == nil {
+				env[credentials.env] = path
+			}
+		}
+		options := ""
+		if credentials.readOnly {
+			options = "ro"
+		}
+		mount(path, options)
+	}
+
+	for _, volume := range container.Mounts {
+		parts := strings.SplitN(volume, ":", 2)
+		host := parts[0]
+		if strings.HasPrefix(host, "~/") && home != "" {
+			host = filepath.Join(home, host[2:])
+		} else if !filepath.IsAbs(host) {
+			host = filepath.Join(dir, host)
+		}
+		if len(parts) == 1 {
+			parts = append(parts, host)
+		}
+		args = append(args, "--volume", host+":"+parts[1])
+	}
+
+	command := impl.Path
+	if len(impl.Args) > 0 && !filepath.IsAbs(impl.Args[0]) && filepath.IsAbs(command) {
+		// found in host PATH, ie. make - let the container find its own
+		command = impl.Args[0]
+	} else if !filepath.IsAbs(command) {
+		command = filepath.Join(dir, command)
+	} else if !strings.HasPrefix(command, dir+string(filepath.Separator)) {
+		// extension script
+		mount(filepath.Dir(command), "ro")
+	}
+
+	for _, name := range util.SortedKeys(env) {
+		if !util.Contains(containerOmitEnv, name) {
+			args = append(args, "--env", name)
+		}
+	}
+	if container.Entrypoint != "" {
+		args = append(args, "--entrypoint", container.Entrypoint)
+	}
+	args = append(args, container.Image, command)
+	if len(impl.Args) > 1 {
+		args = append(args, impl.Args[1:]...)
+	}
+	return args, mergeOsEnviron(envList(env)), nil
+}
+
+func envMap(env []string) map[string]string {
+	vars := make(map[string]string, len(env))
+	for _, v := range env {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) == 2 {
+			vars[kv[0]] = kv[1]
+		}
+	}
+	return vars
+}
+
+func envList(vars map[string]string) []string {
+	env := make([]string, 0, len(vars))
+	for name, value := range vars {
+		env = append(env, fmt.Sprintf("%s=%s", name, value))
+	}
+	return env
+}
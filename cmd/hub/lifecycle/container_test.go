@@ -0,0 +1,70 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build !windows
+
+package lifecycle
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/manifest"
+)
+
+func TestContainerRunArgs(t *testing.T) {
+	home := t.TempDir()
+	dir := t.TempDir()
+	for _, filename := range []string{".kube/config", ".aws/credentials"} {
+		path := filepath.Join(home, filename)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte{}, 0644))
+	}
+
+	impl := &exec.Cmd{Path: "bin/deploy", Dir: dir,
+		Env: []string{"HOME=" + home, "PATH=/usr/bin", "DOMAIN_NAME=test.example.com", "SECRET=s3cr3t"}}
+	container := &manifest.ContainerRunner{
+		Image:      "toolbox:1.0",
+		Entrypoint: "/bin/sh",
+		Mounts:     []string{"~/.ssh:/root/.ssh:ro", "cache:/cache"},
+	}
+	args, env, err := containerRunArgs(impl, container, "docker")
+	if !assert.Nil(t, err) {
+		return
+	}
+	line := strings.Join(args, " ")
+	assert.True(t, strings.HasPrefix(line,
+		fmt.Sprintf("run --rm --workdir %s --user %d:%d --volume %[1]s:%[1]s ", dir, os.Getuid(), os.Getgid())), line)
+	assert.Contains(t, line, fmt.Sprintf("--volume %s/.kube:%[1]s/.kube ", home))
+	assert.Contains(t, line, fmt.Sprintf("--volume %s/.aws/credentials:%[1]s/.aws/credentials:ro ", home))
+	assert.NotContains(t, line, ".aws/config")
+	assert.Contains(t, line, fmt.Sprintf("--volume %s/.ssh:/root/.ssh:ro --volume %s/cache:/cache ", home, dir))
+	assert.Contains(t, line, "--env AWS_SHARED_CREDENTIALS_FILE --env DOMAIN_NAME --env KUBECONFIG --env SECRET ")
+	assert.NotContains(t, line, "--env HOME")
+	assert.NotContains(t, line, "--env PATH")
+	assert.NotContains(t, line, "s3cr3t")
+	assert.True(t, strings.HasSuffix(line, "--entrypoint /bin/sh toolbox:1.0 "+filepath.Join(dir, "bin/deploy")), line)
+	assert.Contains(t, env, "KUBECONFIG="+filepath.Join(home, ".kube", "config"))
+	assert.Contains(t, env, "SECRET=s3cr3t")
+	assert.Contains(t, env, "PATH=/usr/bin")
+
+	impl = &exec.Cmd{Path: "/usr/bin/make", Args: []string{"make", "deploy"}, Dir: dir}
+	args, _, err = containerRunArgs(impl, &manifest.ContainerRunner{Image: "toolbox:1.0"}, "podman")
+	if assert.Nil(t, err) {
+		line = strings.Join(args, " ")
+		assert.Contains(t, line, "--userns=keep-id")
+		assert.True(t, strings.HasSuffix(line, " toolbox:1.0 make deploy"), line)
+	}
+
+	_, _, err = containerRunArgs(impl, &manifest.ContainerRunner{}, "docker")
+	assert.NotNil(t, err)
+}
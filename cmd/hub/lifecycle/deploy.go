@@ -7,6 +7,7 @@
 package lifecycle
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -21,6 +22,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/events"
 	"github.com/epam/hubctl/cmd/hub/ext"
 	"github.com/epam/hubctl/cmd/hub/manifest"
 	"github.com/epam/hubctl/cmd/hub/parameters"
@@ -36,6 +38,9 @@ const (
 	HubEnvVarNameRandom           = "HUB_RANDOM"
 	SkaffoldKubeContextEnvVarName = "SKAFFOLD_KUBE_CONTEXT"
 	HubEnvVarHubStackName         = "HUB_STACK_NAME"
+
+	deploymentIdParameterName = "hub.deploymentId"
+	stackNameParameterName    = "hub.stackName"
 )
 
 func Execute(request *Request, pipe io.WriteCloser) {
@@ -102,17 +107,32 @@ func Execute(request *Request, pipe io.WriteCloser) {
 	var operationsHistory []state.LifecycleOperation
 	stateUpdater := func(interface{}) {}
 	var operationLogId string
+	var resumedOperationId string
+	if len(request.StateFilenames) > 0 || request.LogsDir != "" {
+		u, err := uuid.NewRandom()
+		if err != nil {
+			log.Fatalf("Unable to generate operation Id random v4 UUID: %v", err)
+		}
+		operationLogId = u.String()
+	}
 	if len(request.StateFilenames) > 0 {
 		stateFiles, errs := storage.Check(request.StateFilenames, "state")
 		if len(errs) > 0 {
 			util.MaybeFatalf("Unable to check state files: %s", util.Errors2(errs...))
 		}
-		storage.EnsureNoLockFiles(stateFiles)
+		osEnv = append(osEnv, lockState(request, stateFiles, operationLogId)...)
 		parsed, err := state.ParseState(stateFiles)
+		if request.Resume {
+			if err != nil {
+				util.Fatalf("Unable to resume %s: failed to read %v state files: %v", request.Verb, request.StateFilenames, err)
+			}
+			resumedOperationId = resumeOperation(request, parsed, order)
+			isSomeComponents = true
+		}
 		if isUndeploy || isSomeComponents {
 			if err != nil {
 				if err != os.ErrNotExist {
-					log.Fatalf("Failed to read %v state files: %v", request.StateFilenames, err)
+					util.Fatalf("Failed to read %v state files: %v", request.StateFilenames, err)
 				}
 				if isSomeComponents {
 					comps := request.OffsetComponent
@@ -144,42 +164,27 @@ func Execute(request *Request, pipe io.WriteCloser) {
 			syncer = hubSyncer(request)
 		}
 		stateUpdater = state.InitWriter(stateFiles, syncer)
-
-		u, err := uuid.NewRandom()
-		if err != nil {
-			log.Fatalf("Unable to generate operation Id random v4 UUID: %v", err)
-		}
-		operationLogId = u.String()
 	}
-
-	deploymentIdParameterName := "hub.deploymentId"
-	deploymentId := ""
-	if stateManifest != nil {
-		for _, p := range stateManifest.StackParameters {
-			if p.Name == deploymentIdParameterName {
-				deploymentId = util.String(p.Value)
-				break
-			}
+	var logs *operationLogs
+	if request.LogsDir != "" {
+		logs = newOperationLogs(request.LogsDir, request.LogsGzip, request.LogsRetention, operationLogId)
+	}
+	recordLogFiles := func() {
+		if stateManifest != nil && logs != nil {
+			stateManifest = state.UpdateOperationLogFiles(stateManifest, operationLogId, logs.logFiles())
 		}
 	}
+
+	deploymentId := stateStackParameter(stateManifest, deploymentIdParameterName)
 	if deploymentId == "" {
 		u, err := uuid.NewRandom()
 		if err != nil {
-			log.Fatalf("Unable to generate `hub.deploymentId` random v4 UUID: %v", err)
+			util.Fatalf("Unable to generate `hub.deploymentId` random v4 UUID: %v", err)
 		}
 		deploymentId = u.String()
 	}
 
-	stackNameParameterName := "hub.stackName"
-	stackName := ""
-	if stateManifest != nil {
-		for _, p := range stateManifest.StackParameters {
-			if p.Name == stackNameParameterName {
-				stackName = util.String(p.Value)
-				break
-			}
-		}
-	}
+	stackName := stateStackParameter(stateManifest, stackNameParameterName)
 	if stackName == "" {
 		stackName = os.Getenv(HubEnvVarHubStackName)
 		if stackName == "" {
@@ -206,7 +211,7 @@ func Execute(request *Request, pipe io.WriteCloser) {
 				isDeploy)
 		})
 	if len(errs) > 0 {
-		log.Fatalf("Failed to lock stack parameters:\n\t%s", util.Errors("\n\t", errs...))
+		util.Fatalf("Failed to lock stack parameters:\n\t%s", util.Errors("\n\t", errs...))
 	}
 	allOutputs := make(parameters.CapturedOutputs)
 	if stateManifest != nil {
@@ -262,7 +267,7 @@ func Execute(request *Request, pipe io.WriteCloser) {
 	limitComponentIndex := util.Index(order, request.LimitComponent)
 	if offsetComponentIndex >= 0 && limitComponentIndex >= 0 &&
 		limitComponentIndex < offsetComponentIndex && !offsetGuessed {
-		log.Fatalf("Specified --limit %s (%d) is before specified --offset %s (%d) in component order",
+		util.Fatalf("Specified --limit %s (%d) is before specified --offset %s (%d) in component order",
 			request.LimitComponent, limitComponentIndex, request.OffsetComponent, offsetComponentIndex)
 	}
 	skipComponent := func(i int, name string) bool {
@@ -274,32 +279,196 @@ func Execute(request *Request, pipe io.WriteCloser) {
 	checkLifecycleVerbs(order, components, componentsManifests, stackManifest.Lifecycle.Verbs, stackBaseDir, componentsBaseDir, skipComponent)
 
 	failedComponents := make([]string, 0)
+	var componentsFailures []*hookFailure
 
-	// TODO handle ^C interrupt to update op log and stack status
-	// or expiry by time and set to `interrupted`
 	if stateManifest != nil {
 		stateManifest = state.UpdateOperation(stateManifest, operationLogId, request.Verb, "in-progress",
-			map[string]interface{}{"args": os.Args})
+			operationOptions(request, resumedOperationId))
+	}
+	operationStart := time.Now()
+	selectedComponents := make([]string, 0, len(order))
+	for i, name := range order {
+		if !skipComponent(i, name) {
+			selectedComponents = append(selectedComponents, name)
+		}
+	}
+	events.Emit(events.Event{Type: events.OperationStart, Verb: request.Verb, Operation: operationLogId,
+		Stack: stackManifest.Meta.Name, Components: selectedComponents})
+	emitOperationEnd := func(status, message string) {
+		events.Emit(events.Event{Type: events.OperationEnd, Verb: request.Verb, Operation: operationLogId,
+			Stack: stackManifest.Meta.Name, Status: status, Message: message,
+			DurationSeconds: events.Seconds(time.Since(operationStart))})
 	}
 
-	ctx := watchInterrupt()
+	ctx := withOperationLogs(watchInterrupt(), logs)
+	// ctx is cancelled on interrupt, stackCtx is also cancelled on stack timeout
+	stackCtx := ctx
+	if request.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		stackCtx, cancel = context.WithTimeout(ctx, time.Duration(request.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
 
-NEXT_COMPONENT:
-	for componentIndex, componentName := range order {
+	stackVerb := maybeTestVerb(request.Verb, request.DryRun)
+	// on-error-<verb> and always-<verb> hooks are executed even if the operation is interrupted
+	fireStackFinalHooks := func(status string, failure *hookFailure) {
+		hookCtx := withOperationLogs(context.Background(), logs)
+		fireFinalHooks(stackVerb, status, failure, osEnv, func(trigger string, env []string) ([]byte, []byte, error) {
+			return fireStackHooks(hookCtx, trigger, stackBaseDir, stackManifest.Hooks, stackParametersNoLinks, env, nil)
+		})
+		recordLogFiles()
+	}
+	stackFailed := false
+	failStack := func(message string, failure *hookFailure) {
+		if stateManifest != nil {
+			stateManifest = state.UpdateOperation(stateManifest, operationLogId, request.Verb, "error", nil)
+		}
+		fireStackFinalHooks("error", failure)
+		if stateManifest != nil {
+			stateUpdater(stateManifest)
+		}
+		emitOperationEnd("error", message)
+		util.MaybeFatalf("%s", message)
+		stackFailed = true
+	}
+	// failOperation finalizes the operation when mandatory component failure exits the process
+	failOperation := func(message string) {
+		fireStackFinalHooks("error", abortedFailure(componentsFailures, message))
+		if stateManifest != nil {
+			stateUpdater(stateManifest)
+		}
+		emitOperationEnd("error", message)
+	}
+	fireStackHook := func(trigger string) *hookFailure {
+		stdout, stderr, err := fireStackHooks(stackCtx, trigger, stackBaseDir, stackManifest.Hooks, stackParametersNoLinks, osEnv, nil)
+		if err == nil {
+			return nil
+		}
+		if stateManifest != nil && request.WriteOplogToStateOnError {
+			stateManifest = state.AppendOperationLog(stateManifest, operationLogId,
+				fmt.Sprintf("%v%s", err, formatStdoutStderr(stdout, stderr)))
+		}
+		return &hookFailure{message: fmt.Sprintf("One of %s stack hooks failed: %v", trigger, err),
+			logFile: logs.lastLogFile("")}
+	}
+	if failure := fireStackHook("pre-" + stackVerb); failure != nil {
+		if config.Force {
+			util.Warn("%s", failure.message)
+		} else {
+			failStack(failure.message, failure)
+		}
+	}
+
+	var rollback *rollbackRequest
+	if request.RollbackOnFailure && isDeploy {
+		if stateManifest != nil {
+			rollback = &rollbackRequest{
+				ctx:                 stackCtx,
+				dryRun:              request.DryRun,
+				stackManifest:       stackManifest,
+				componentsManifests: componentsManifests,
+				stackBaseDir:        stackBaseDir,
+				componentsBaseDir:   componentsBaseDir,
+				osEnv:               osEnv,
+				operationLogId:      operationLogId,
+				provides:            provides,
+				prevComponents:      snapshotComponents(stateManifest),
+				logs:                logs,
+			}
+		} else {
+			util.Warn("Rollback on failure requires state file - rollback disabled")
+		}
+	}
+
+	parallel := request.Parallelism > 1
+	var scheduler *componentScheduler
+	fatalf := util.MaybeFatalf2
+	if rollback != nil {
+		// mandatory component failure is handled after components loop
+		fatalf = abortComponentf
+	}
+	unlocked := func(routine func()) { routine() }
+	if parallel {
+		scheduler = newComponentScheduler(request.Parallelism, len(order))
+		fatalf = scheduler.fatalf
+		unlocked = scheduler.unlocked
+	}
+
+	isFinal := func(componentIndex int) bool {
+		last := componentIndex == len(order)-1
+		if parallel {
+			last = scheduler.last()
+		}
+		return last || (len(request.Components) > 0 && request.LoadFinalState)
+	}
+
+	executeComponent := func(componentIndex int, componentName string) {
 		if skipComponent(componentIndex, componentName) {
 			if config.Debug {
 				log.Printf("Skip %s", componentName)
 			}
-			continue
+			return
+		}
+
+		var output io.Writer
+		if parallel {
+			buffer := &util.Buffer{}
+			output = buffer
+			defer printComponentOutput(componentName, buffer)
 		}
 
 		if config.Verbose {
 			log.Printf(util.HighlightColor("%s ***%s*** (%d/%d)"), maybeTestVerb(request.Verb, request.DryRun),
 				componentName, componentIndex+1, len(components))
 		}
+		events.Emit(events.Event{Type: events.ComponentStart, Verb: request.Verb, Operation: operationLogId,
+			Component: componentName, Index: componentIndex + 1, Total: len(components)})
+		componentStart := time.Now()
+		componentStatus := "skipped"
+		failureStatus := "error"
+		failureMessage := ""
 
 		component := manifest.ComponentRefByName(components, componentName)
 		componentManifest := manifest.ComponentManifestByRef(componentsManifests, component)
+		verb := maybeTestVerb(request.Verb, request.DryRun)
+		var componentParameters parameters.LockedParameters
+		executed := false
+
+		finished := false
+		// finishComponent fires component final hooks and emits component end event, it is
+		// deferred and also called on mandatory component failure that exits the process
+		finishComponent := func() {
+			if finished {
+				return
+			}
+			finished = true
+			// final hooks are not fired for skipped component, but are fired on failure before execution
+			if executed || failureMessage != "" {
+				status := "success"
+				var failure *hookFailure
+				if failureMessage != "" {
+					status = failureStatus
+					failure = &hookFailure{component: componentName, message: failureMessage,
+						logFile: logs.lastLogFile(componentName)}
+					componentsFailures = append(componentsFailures, failure)
+				}
+				hookCtx := withOperationLogs(context.Background(), logs)
+				unlocked(func() {
+					fireFinalHooks(verb, status, failure, osEnv, func(trigger string, env []string) ([]byte, []byte, error) {
+						return fireHooks(hookCtx, trigger, stackBaseDir, component, componentParameters, env, output)
+					})
+				})
+			}
+			recordLogFiles()
+			status, message := componentStatus, ""
+			if failureMessage != "" {
+				status, message = failureStatus, failureMessage
+			}
+			events.Emit(events.Event{Type: events.ComponentEnd, Verb: request.Verb, Operation: operationLogId,
+				Component: componentName, Status: status, Message: message,
+				DurationSeconds: events.Seconds(time.Since(componentStart))})
+		}
+		defer finishComponent()
 
 		if stateManifest != nil && (componentIndex == offsetComponentIndex || len(request.Components) > 0) {
 			if len(request.Components) > 0 {
@@ -310,12 +479,18 @@ NEXT_COMPONENT:
 				allOutputs)
 		}
 
-		var updateStateComponentFailed func(string, bool)
+		var prevTimestamps state.Timestamps
 		if stateManifest != nil {
+			if step, exist := stateManifest.Components[componentName]; exist {
+				prevTimestamps = step.Timestamps
+			}
 			stateManifest = state.UpdateComponentStartTimestamp(stateManifest, componentName)
-			updateStateComponentFailed = func(msg string, final bool) {
-				stateManifest = state.UpdateComponentStatus(stateManifest, componentName, &componentManifest.Meta, "error", msg)
-				stateManifest = state.UpdatePhase(stateManifest, operationLogId, componentName, "error")
+		}
+		updateStateComponentFailed := func(msg string, final bool) {
+			failureMessage = msg
+			if stateManifest != nil {
+				stateManifest = state.UpdateComponentStatus(stateManifest, componentName, &componentManifest.Meta, failureStatus, msg)
+				stateManifest = state.UpdatePhase(stateManifest, operationLogId, componentName, failureStatus)
 				// Erasing provides of a failed component on redeploy has undesirable effect on undeploy, for example:
 				// Kubernetes Terraform failed safely - failed to download plugin, or failed to add minor resource - leaving
 				// Kubernetes fully operation, yet the `kubernetes` capability is removed from stack provides. Such stack
@@ -331,6 +506,21 @@ NEXT_COMPONENT:
 				}
 				stateUpdater(stateManifest)
 			}
+			if final {
+				finishComponent()
+				failOperation(msg)
+			}
+		}
+		// the signal is forwarded to the implementation or hook, so most probably it failed due to interrupt
+		componentInterrupted := func() bool {
+			if ctx.Err() == nil {
+				return false
+			}
+			msg := fmt.Sprintf("Component `%s` %s interrupted by %v", componentName, request.Verb, interruptSignal())
+			log.Print(msg)
+			failureStatus = "interrupted"
+			updateStateComponentFailed(msg, false)
+			return true
 		}
 
 		if isDeploy && len(component.Depends) > 0 {
@@ -344,9 +534,9 @@ NEXT_COMPONENT:
 				maybeFatalIfMandatory(&stackManifest.Lifecycle, componentName,
 					fmt.Sprintf("Component `%s` failed to %s: depends on failed optional component `%s`",
 						componentName, request.Verb, strings.Join(failed, ", ")),
-					updateStateComponentFailed)
+					updateStateComponentFailed, fatalf)
 				failedComponents = append(failedComponents, componentName)
-				continue NEXT_COMPONENT
+				return
 			}
 		}
 
@@ -361,19 +551,46 @@ NEXT_COMPONENT:
 			if stateManifest != nil {
 				stateManifest = state.EraseComponentEmptyState(stateManifest, componentName)
 			}
-			continue NEXT_COMPONENT
+			return
 		}
 		if len(expansionErrs) > 0 {
 			log.Printf("Component `%s` failed to %s", componentName, request.Verb)
 			maybeFatalIfMandatory(&stackManifest.Lifecycle, componentName,
 				fmt.Sprintf("Component `%s` parameters expansion failed:\n\t%s",
 					componentName, util.Errors("\n\t", expansionErrs...)),
-				updateStateComponentFailed)
+				updateStateComponentFailed, fatalf)
 			failedComponents = append(failedComponents, componentName)
-			continue NEXT_COMPONENT
+			return
 		}
 
-		componentParameters := parameters.MergeParameters(make(parameters.LockedParameters), expandedComponentParameters)
+		componentParameters = parameters.MergeParameters(make(parameters.LockedParameters), expandedComponentParameters)
+
+		if request.ChangedOnly && stateManifest != nil {
+			plan := planComponent(componentName, expandedComponentParameters, stateManifest, false)
+			if plan.Status == "unchanged" {
+				if config.Verbose {
+					log.Printf("Skip `%s`: parameters are unchanged since last %s", componentName, request.Verb)
+				}
+				componentStatus = "unchanged"
+				step := stateManifest.Components[componentName]
+				step.Timestamps = prevTimestamps
+				// make outputs of skipped component visible to components that follows
+				for _, output := range step.CapturedOutputs {
+					if output.Component == componentName {
+						parameters.MergeOutput(allOutputs, output)
+					}
+				}
+				stateManifest = state.UpdateState(stateManifest, componentName,
+					stackParameters, step.Parameters,
+					nil, allOutputs, stackManifest.Outputs,
+					noEnvironmentProvides(provides), isFinal(componentIndex))
+				stateUpdater(stateManifest)
+				return
+			}
+			if config.Debug {
+				log.Printf("Component `%s` is %s", componentName, plan.Status)
+			}
+		}
 
 		if optionalNotProvided, err := prepareComponentRequires(provides, componentManifest, allParameters, allOutputs, optionalRequires, request.EnabledClouds); len(optionalNotProvided) > 0 || err != nil {
 			if err != nil {
@@ -381,15 +598,15 @@ NEXT_COMPONENT:
 					// proceed without --force set to handle required component (depends on) being already undeployed via --component
 					util.Warn("%v", err)
 				} else {
-					maybeFatalIfMandatory(&stackManifest.Lifecycle, componentName, fmt.Sprintf("%v", err), updateStateComponentFailed)
-					continue NEXT_COMPONENT
+					maybeFatalIfMandatory(&stackManifest.Lifecycle, componentName, fmt.Sprintf("%v", err), updateStateComponentFailed, fatalf)
+					return
 				}
 			}
 			if len(optionalNotProvided) > 0 {
 				log.Printf("Skip %s due to unsatisfied optional requirements %v", componentName, optionalNotProvided)
 				// there will be a gap in state file but `deploy -c` will be able to find some state from
 				// a preceding component
-				continue NEXT_COMPONENT
+				return
 			}
 		}
 
@@ -421,41 +638,78 @@ NEXT_COMPONENT:
 		}
 		componentDir := manifest.ComponentSourceDirFromRef(component, stackBaseDir, componentsBaseDir)
 
-		verb := maybeTestVerb(request.Verb, request.DryRun)
-
+		executed = true
 		preHookVerb := fmt.Sprintf("pre-%s", verb)
-		stdout, stderr, err := fireHooks(preHookVerb, stackBaseDir, component, componentParameters, osEnv)
+		var stdout, stderr []byte
+		unlocked(func() {
+			stdout, stderr, err = fireHooks(stackCtx, preHookVerb, stackBaseDir, component, componentParameters, osEnv, output)
+		})
 		if err != nil {
+			if componentInterrupted() {
+				return
+			}
 			if stateManifest != nil && request.WriteOplogToStateOnError {
 				stateManifest = state.AppendOperationLog(stateManifest, operationLogId,
 					fmt.Sprintf("%v%s", err, formatStdoutStderr(stdout, stderr)))
 			}
-			util.MaybeFatalf2(updateStateComponentFailed, "One of %s hooks failed. See logs", preHookVerb)
+			fatalf(updateStateComponentFailed, "One of %s hooks failed. See logs", preHookVerb)
 		}
 
-		stdout, stderr, err = delegate(verb,
-			component, componentManifest, componentParameters,
-			componentDir, osEnv, randomStr, stackBaseDir)
+		componentCtx := stackCtx
+		if timeout := componentManifest.Lifecycle.TimeoutSeconds; timeout > 0 {
+			var cancel context.CancelFunc
+			componentCtx, cancel = context.WithTimeout(stackCtx, time.Duration(timeout)*time.Second)
+			defer cancel()
+		}
+		var attempts []error
+		// outputs captured from JSON of the tool executed directly
+		var toolRawOutputs parameters.RawOutputs
+		unlocked(func() {
+			stdout, stderr, attempts, err = withRetry(componentCtx, componentManifest.Lifecycle.Retry, componentName, request.Verb,
+				func() ([]byte, []byte, error) {
+					stdout, stderr, rawOutputs, err := delegate(componentCtx, verb,
+						component, componentManifest, componentParameters,
+						componentDir, osEnv, randomStr, stackBaseDir, output)
+					toolRawOutputs = rawOutputs
+					return stdout, stderr, err
+				})
+		})
+		if stateManifest != nil && retryAttempts(componentManifest.Lifecycle.Retry) > 1 {
+			for i, attemptErr := range attempts {
+				status := "success"
+				if attemptErr != nil {
+					status = "error"
+				}
+				stateManifest = state.UpdatePhase(stateManifest, operationLogId, attemptPhase(componentName, i+1), status)
+			}
+		}
 		var rawOutputs parameters.RawOutputs
+		if err != nil && componentInterrupted() {
+			return
+		}
 		if err != nil {
 			if stateManifest != nil && request.WriteOplogToStateOnError {
 				stateManifest = state.AppendOperationLog(stateManifest, operationLogId,
 					fmt.Sprintf("%v%s", err, formatStdoutStderr(stdout, stderr)))
 			}
-			maybeFatalIfMandatory(&stackManifest.Lifecycle, componentName,
-				fmt.Sprintf("Component `%s` failed to %s: %v", componentName, request.Verb, err),
-				updateStateComponentFailed)
+			msg := fmt.Sprintf("Component `%s` failed to %s: %v", componentName, request.Verb, err)
+			if isTimeout(err) {
+				failureStatus = "timeout"
+				msg = fmt.Sprintf("Component `%s` failed to %s: %s", componentName, request.Verb,
+					timeoutMessage(stackCtx, request.TimeoutSeconds, componentManifest.Lifecycle.TimeoutSeconds))
+			}
+			maybeFatalIfMandatory(&stackManifest.Lifecycle, componentName, msg, updateStateComponentFailed, fatalf)
 			failedComponents = append(failedComponents, componentName)
 		} else if isDeploy {
 			rawOutputsCaptured, componentOutputs, dynamicProvides, errs := captureOutputs(componentName, componentDir, componentManifest, componentParameters,
-				stdout, random)
+				stdout, toolRawOutputs, random)
 			rawOutputs = rawOutputsCaptured
 			if len(errs) > 0 {
 				log.Printf("Component `%s` failed to %s", componentName, request.Verb)
 				maybeFatalIfMandatory(&stackManifest.Lifecycle, componentName,
 					fmt.Sprintf("Component `%s` outputs capture failed:\n\t%s",
 						componentName, util.Errors("\n\t", errs...)),
-					updateStateComponentFailed)
+					updateStateComponentFailed, fatalf)
 				failedComponents = append(failedComponents, componentName)
 			}
 			if len(componentOutputs) > 0 &&
@@ -464,6 +718,7 @@ NEXT_COMPONENT:
 				parameters.PrintCapturedOutputs(componentOutputs)
 			}
 			parameters.MergeOutputs(allOutputs, componentOutputs)
+			emitOutputs(componentName, componentOutputs)
 
 			if request.GitOutputs {
 				if config.Debug || (config.Verbose && request.GitOutputsStatus) {
@@ -495,34 +750,47 @@ NEXT_COMPONENT:
 		}
 
 		postHookVerb := fmt.Sprintf("post-%s", verb)
-		stdout, stderr, err = fireHooks(postHookVerb, stackBaseDir, component, componentParameters, osEnv)
+		unlocked(func() {
+			stdout, stderr, err = fireHooks(stackCtx, postHookVerb, stackBaseDir, component, componentParameters, osEnv, output)
+		})
 		if err != nil {
+			if componentInterrupted() {
+				return
+			}
 			if stateManifest != nil && request.WriteOplogToStateOnError {
 				stateManifest = state.AppendOperationLog(stateManifest, operationLogId,
 					fmt.Sprintf("%v%s", err, formatStdoutStderr(stdout, stderr)))
 			}
-			util.MaybeFatalf2(updateStateComponentFailed, "One of %s hooks failed. See logs", postHookVerb)
-		}
-
-		if ctx.Err() != nil {
-			break
+			fatalf(updateStateComponentFailed, "One of %s hooks failed. See logs", postHookVerb)
 		}
 
 		if stateManifest != nil && isDeploy {
-			final := componentIndex == len(order)-1 || (len(request.Components) > 0 && request.LoadFinalState)
 			stateManifest = state.UpdateState(stateManifest, componentName,
 				stackParameters, expandedComponentParameters,
 				rawOutputs, allOutputs, stackManifest.Outputs,
-				noEnvironmentProvides(provides), final)
+				noEnvironmentProvides(provides), isFinal(componentIndex))
+		}
+
+		if componentInterrupted() {
+			return
 		}
 
 		if err == nil && isDeploy {
-			err = waitForReadyConditions(ctx, componentManifest.Lifecycle.ReadyConditions, componentParameters, allOutputs, component.Depends)
+			outputs := allOutputs
+			if parallel {
+				outputs = make(parameters.CapturedOutputs)
+				parameters.MergeOutputs(outputs, allOutputs)
+			}
+			kubernetesProvider := requirementProvider(provides, "kubernetes")
+			unlocked(func() {
+				err = waitForReadyConditions(stackCtx, componentName, componentManifest.Lifecycle.ReadyConditions, componentParameters, outputs, component.Depends,
+					kubernetesProvider)
+			})
 			if err != nil {
 				log.Printf("Component `%s` failed to %s", componentName, request.Verb)
 				maybeFatalIfMandatory(&stackManifest.Lifecycle, componentName,
 					fmt.Sprintf("Component `%s` ready condition failed: %v", componentName, err),
-					updateStateComponentFailed)
+					updateStateComponentFailed, fatalf)
 				failedComponents = append(failedComponents, componentName)
 			}
 		}
@@ -531,10 +799,13 @@ NEXT_COMPONENT:
 			log.Printf("Component `%s` completed %s", componentName, request.Verb)
 		}
 
+		if !util.Contains(failedComponents, componentName) {
+			componentStatus = fmt.Sprintf("%sed", request.Verb)
+		}
 		if stateManifest != nil {
 			if !util.Contains(failedComponents, componentName) {
 				stateManifest = state.UpdateComponentStatus(stateManifest, componentName, &componentManifest.Meta,
-					fmt.Sprintf("%sed", request.Verb), "")
+					componentStatus, "")
 				stateManifest = state.UpdatePhase(stateManifest, operationLogId, componentName, "success")
 				stateUpdater(stateManifest)
 			}
@@ -543,23 +814,83 @@ NEXT_COMPONENT:
 		// end of component cycle
 	}
 
-	stackReadyConditionFailed := false
+	aborted := ""
+	if parallel {
+		if config.Verbose {
+			log.Printf("Executing components with parallelism %d", request.Parallelism)
+		}
+		depends := componentsGraph(order, components, componentsManifests, isUndeploy)
+		aborted = scheduler.run(stackCtx, order, depends, executeComponent)
+	} else {
+		for componentIndex, componentName := range order {
+			aborted = catchComponentAborted(func() { executeComponent(componentIndex, componentName) })
+			if aborted != "" || stackCtx.Err() != nil {
+				break
+			}
+		}
+	}
+	if aborted == "" && ctx.Err() == nil && stackCtx.Err() != nil {
+		aborted = fmt.Sprintf("Stack failed to %s: %s", request.Verb, timeoutMessage(stackCtx, request.TimeoutSeconds, 0))
+		log.Print(aborted)
+		if stateManifest != nil {
+			stateManifest = state.UpdateStackStatus(stateManifest, "incomplete", aborted)
+		}
+	}
+	if ctx.Err() != nil {
+		sig := interruptSignal()
+		message := fmt.Sprintf("Stack %s interrupted by %v", request.Verb, sig)
+		log.Print(message)
+		fireStackFinalHooks("interrupted", &hookFailure{message: message})
+		if stateManifest != nil {
+			stateManifest = state.UpdateStackStatus(stateManifest, "incomplete", message)
+			stateManifest = state.UpdateOperation(stateManifest, operationLogId, request.Verb, "interrupted", nil)
+			stateUpdater(stateManifest)
+		}
+		emitOperationEnd("interrupted", message)
+		util.Done()
+		os.Exit(interruptExitCode(sig))
+	}
+	if aborted != "" {
+		if stateManifest != nil {
+			if rollback != nil {
+				stateManifest = rollbackDeploy(rollback, stateManifest, stateUpdater, aborted)
+			}
+			stateManifest = state.UpdateOperation(stateManifest, operationLogId, request.Verb, "error", nil)
+		}
+		fireStackFinalHooks("error", abortedFailure(componentsFailures, aborted))
+		if stateManifest != nil {
+			stateUpdater(stateManifest)
+		}
+		emitOperationEnd("error", aborted)
+		util.Done()
+		os.Exit(1)
+	}
+
 	if isDeploy {
-		err := waitForReadyConditions(ctx, stackManifest.Lifecycle.ReadyConditions, stackParameters, allOutputs, nil)
+		err := waitForReadyConditions(stackCtx, "", stackManifest.Lifecycle.ReadyConditions, stackParameters, allOutputs, nil,
+			requirementProvider(provides, "kubernetes"))
 		if err != nil {
 			message := fmt.Sprintf("Stack ready condition failed: %v", err)
 			if stateManifest != nil {
 				stateManifest = state.UpdateStackStatus(stateManifest, "incomplete", message)
-				stateManifest = state.UpdateOperation(stateManifest, operationLogId, request.Verb, "error", nil)
-				stateUpdater(stateManifest)
 			}
-			util.MaybeFatalf("%s", message)
-			stackReadyConditionFailed = true
+			failStack(message, &hookFailure{message: message})
+		}
+	}
+	if !stackFailed {
+		if failure := fireStackHook("post-" + stackVerb); failure != nil {
+			if stateManifest != nil {
+				stateManifest = state.UpdateStackStatus(stateManifest, "incomplete", failure.message)
+			}
+			failStack(failure.message, failure)
 		}
 	}
+	if !stackFailed {
+		fireStackFinalHooks("success", nil)
+	}
 
 	if stateManifest != nil {
-		if !stackReadyConditionFailed {
+		if !stackFailed {
 			status, message := calculateStackStatus(stackManifest, stateManifest, request.Verb)
 			stateManifest = state.UpdateStackStatus(stateManifest, status, message)
 			stateManifest = state.UpdateOperation(stateManifest, operationLogId, request.Verb, "success", nil)
@@ -567,6 +898,9 @@ NEXT_COMPONENT:
 		}
 		stateUpdater("sync")
 	}
+	if !stackFailed {
+		emitOperationEnd("success", "")
+	}
 
 	var stackOutputs []parameters.ExpandedOutput
 	if stateManifest != nil {
@@ -591,19 +925,28 @@ NEXT_COMPONENT:
 	}
 }
 
+func timeoutMessage(stackCtx context.Context, stackTimeout, componentTimeout int) string {
+	if stackCtx.Err() != nil {
+		return fmt.Sprintf("stack timeout of %d seconds exceeded", stackTimeout)
+	}
+	return fmt.Sprintf("component timeout of %d seconds exceeded", componentTimeout)
+}
+
 func optionalComponent(lifecycle *manifest.Lifecycle, componentName string) bool {
 	return (len(lifecycle.Mandatory) > 0 && !util.Contains(lifecycle.Mandatory, componentName)) ||
 		util.Contains(lifecycle.Optional, componentName)
 }
 
-func maybeFatalIfMandatory(lifecycle *manifest.Lifecycle, componentName string, msg string, cleanup func(string, bool)) {
+func maybeFatalIfMandatory(lifecycle *manifest.Lifecycle, componentName string, msg string, cleanup func(string, bool),
+	fatalf func(func(string, bool), string, ...interface{})) {
+
 	if optionalComponent(lifecycle, componentName) {
 		util.Warn("%s", msg)
 		if cleanup != nil {
 			cleanup(msg, false)
 		}
 	} else {
-		util.MaybeFatalf2(cleanup, "%s", msg)
+		fatalf(cleanup, "%s", msg)
 	}
 }
 
@@ -649,27 +992,38 @@ func maybeTestVerb(verb string, test bool) string {
 	return verb
 }
 
-func fireHooks(trigger string, stackBaseDir string, component *manifest.ComponentRef,
-	componentParameters parameters.LockedParameters, osEnv []string,
+func fireHooks(ctx context.Context, trigger string, stackBaseDir string, component *manifest.ComponentRef,
+	componentParameters parameters.LockedParameters, osEnv []string, output io.Writer,
 ) ([]byte, []byte, error) {
 	hooks := findHooksByTrigger(trigger, component.Hooks)
 	if len(hooks) == 0 {
 		return nil, nil, nil
 	}
+	return runHooks(trigger, hooks, component.Name, hookSearchDirs(stackBaseDir, component.Source.Dir), componentParameters,
+		func(script string) ([]byte, []byte, error) {
+			return delegateHook(ctx, trigger, script, stackBaseDir, component, componentParameters, osEnv, output)
+		})
+}
 
-	extensions := ext.GetExtensionLocations()
-	paths := filepath.SplitList(os.Getenv("PATH"))
-
-	searchDirs := []string{
-		component.Source.Dir,
+func hookSearchDirs(stackBaseDir string, dirs ...string) []string {
+	// stack base dir is empty when manifest is in current directory
+	if abs, err := filepath.Abs(stackBaseDir); err == nil {
+		stackBaseDir = abs
+	}
+	searchDirs := append(dirs,
 		stackBaseDir,
 		filepath.Join(stackBaseDir, "bin"),
 		filepath.Join(stackBaseDir, ".hub"),
 		filepath.Join(stackBaseDir, ".hub", "bin"),
-	}
-	searchDirs = append(searchDirs, extensions...)
-	searchDirs = append(searchDirs, paths...)
+	)
+	searchDirs = append(searchDirs, ext.GetExtensionLocations()...)
+	return append(searchDirs, filepath.SplitList(os.Getenv("PATH"))...)
+}
 
+// runHooks locates and executes hooks scripts; componentName is empty for stack hooks
+func runHooks(trigger string, hooks []manifest.Hook, componentName string, searchDirs []string,
+	hookParameters parameters.LockedParameters, run func(string) ([]byte, []byte, error),
+) ([]byte, []byte, error) {
 	for _, hook := range hooks {
 		var err error
 		script, err := findScript(hook.File, searchDirs...)
@@ -678,11 +1032,14 @@ func fireHooks(trigger string, stackBaseDir string, component *manifest.Componen
 			continue
 		}
 		log.Printf("Running %s script: %s", trigger, util.HighlightColor(hook.File))
-		if config.Verbose && len(componentParameters) > 0 {
+		if config.Verbose && len(hookParameters) > 0 {
 			log.Print("Environment:")
-			parameters.PrintLockedParameters(componentParameters)
+			parameters.PrintLockedParameters(hookParameters)
 		}
-		stdout, stderr, err := delegateHook(script, stackBaseDir, component, componentParameters, osEnv)
+		events.Emit(events.Event{Type: events.HookStart, Component: componentName, Hook: trigger, File: hook.File})
+		start := time.Now()
+		stdout, stderr, err := run(script)
+		emitHookEnd(componentName, trigger, hook.File, start, err)
 		if err != nil {
 			if strings.Contains(err.Error(), "fork/exec : no such file or directory") {
 				log.Printf("Error: file %s has not been found.", script)
@@ -756,7 +1113,7 @@ func findScript(script string, dirs ...string) (string, error) {
 	return result, nil
 }
 
-func delegateHook(script string, stackDir string, component *manifest.ComponentRef, componentParameters parameters.LockedParameters, osEnv []string) ([]byte, []byte, error) {
+func delegateHook(ctx context.Context, trigger, script string, stackDir string, component *manifest.ComponentRef, componentParameters parameters.LockedParameters, osEnv []string, output io.Writer) ([]byte, []byte, error) {
 	var err error
 	componentDir := component.Source.Dir
 	// components usually stored as relative paths
@@ -772,15 +1129,20 @@ func delegateHook(script string, stackDir string, component *manifest.ComponentR
 	command := &exec.Cmd{
 		Path: script,
 		Dir:  componentDir,
-		Env:  mergeOsEnviron(osEnv, processEnv),
+		Env:  mergeOsEnviron(osEnv, processEnv, hookTriggerEnv(trigger)),
 	}
-	return execImplementation(command, false, true)
+	var logFile io.Writer
+	if file := openLogFile(ctx, component.Name, fmt.Sprintf("%s-%s", trigger, filepath.Base(script))); file != nil {
+		defer file.Close()
+		logFile = file
+	}
+	return execImplementation(ctx, command, false, true, output, logFile)
 }
 
-func delegate(verb string, component *manifest.ComponentRef, componentManifest *manifest.Manifest,
+func delegate(ctx context.Context, verb string, component *manifest.ComponentRef, componentManifest *manifest.Manifest,
 	componentParameters parameters.LockedParameters,
-	dir string, osEnv []string, random string, baseDir string,
-) ([]byte, []byte, error) {
+	dir string, osEnv []string, random string, baseDir string, output io.Writer,
+) ([]byte, []byte, parameters.RawOutputs, error) {
 	if config.Debug && len(componentParameters) > 0 {
 		log.Print("Component parameters:")
 		parameters.PrintLockedParameters(componentParameters)
@@ -789,22 +1151,35 @@ func delegate(verb string, component *manifest.ComponentRef, componentManifest *
 	componentName := manifest.ComponentQualifiedNameFromRef(component)
 	errs := processTemplates(component, &componentManifest.Templates, componentParameters, nil, dir)
 	if len(errs) > 0 {
-		return nil, nil, fmt.Errorf("Failed to process templates:\n\t%s", util.Errors("\n\t", errs...))
+		return nil, nil, nil, fmt.Errorf("Failed to process templates:\n\t%s", util.Errors("\n\t", errs...))
 	}
 
 	processEnv := parametersInEnv(component, componentParameters, baseDir)
-	impl, err := findImplementation(dir, verb, componentManifest)
+	impl, outputs, err := findImplementation2(dir, verb, componentManifest, baseDir)
 	if err != nil {
 		if componentManifest.Lifecycle.Bare == "allow" {
 			if config.Verbose {
 				log.Printf("Skip `%s`: %v", componentName, err)
 			}
-			return nil, nil, nil
+			return nil, nil, nil, nil
 		}
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	skaffoldEnvironment := skaffoldEnv(impl, processEnv)
-	impl.Env = mergeOsEnviron(osEnv, processEnv, randomEnv(random), skaffoldEnvironment)
+	env := mergeOsEnviron(impl.Env, osEnv, processEnv, randomEnv(random), skaffoldEnvironment)
+	impl.Env = env
+	if outputs != nil && outputs.command != nil {
+		outputs.command.Env = env
+	}
+	if container := componentManifest.Lifecycle.Container; container != nil {
+		impl, err = containerImplementation(impl, container)
+		if err == nil && outputs != nil && outputs.command != nil {
+			outputs.command, err = containerImplementation(outputs.command, container)
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("Unable to run `%s` in container: %v", componentName, err)
+		}
+	}
 	if config.Debug && len(processEnv) > 0 {
 		log.Print("Component environment:")
 		printEnvironment(processEnv)
@@ -815,8 +1190,17 @@ func delegate(verb string, component *manifest.ComponentRef, componentManifest *
 		}
 	}
 
-	stdout, stderr, err := execImplementation(impl, false, true)
-	return stdout, stderr, err
+	var logFile io.Writer
+	if file := openLogFile(ctx, componentName, verb); file != nil {
+		defer file.Close()
+		logFile = file
+	}
+	stdout, stderr, err := execImplementation(ctx, impl, false, true, output, logFile)
+	var rawOutputs parameters.RawOutputs
+	if err == nil && outputs != nil {
+		rawOutputs, err = outputs.capture(ctx, stdout)
+	}
+	return stdout, stderr, rawOutputs, err
 }
 
 func randomEnv(random string) []string {
@@ -0,0 +1,238 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/epam/hubctl/cmd/hub/parameters"
+	"github.com/epam/hubctl/cmd/hub/state"
+	"github.com/epam/hubctl/cmd/hub/storage"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+const (
+	checkVerb = "check"
+	// as `terraform plan -detailed-exitcode` and `helm diff --detailed-exitcode`
+	driftExitCode = 2
+	// some components failed the check or cannot be checked, and no drift is found
+	driftErrorExitCode = 3
+	// `drift` output of the `check` verb, if present, takes precedence over exit code
+	driftOutput = "drift"
+)
+
+type StackDrift struct {
+	Meta       state.Metadata         `json:"meta"`
+	Drifted    bool                   `json:"drifted"`
+	Components []state.ComponentDrift `json:"components"`
+}
+
+// driftStatus interprets `check` verb result: exit code 0 is in-sync, 2 is drifted,
+// any other failure is error. The `drift` output overrides exit code 0 or 2.
+func driftStatus(stdout []byte, err error) (string, string) {
+	var exitErr *exec.ExitError
+	drifted := err != nil && errors.As(err, &exitErr) && exitErr.ExitCode() == driftExitCode
+	if err != nil && !drifted {
+		return "error", err.Error()
+	}
+	if outputs := parseTextOutput(stdout); len(outputs) > 0 {
+		if value, exist := outputs[driftOutput]; exist {
+			switch strings.ToLower(value) {
+			case "in-sync", "false", "no":
+				return "in-sync", ""
+			case "drifted", "true", "yes":
+				return "drifted", ""
+			default:
+				return "unknown", fmt.Sprintf("unrecognized `%s` output value `%s`", driftOutput, value)
+			}
+		}
+	}
+	if drifted {
+		return "drifted", ""
+	}
+	return "in-sync", ""
+}
+
+// Drift executes `check` verb of deployed components to find out if deployed resources
+// have drifted from the state. Exits with code 2 if drift is found, or 3 if any component
+// status is error or unknown.
+func Drift(request *Request, jsonFormat bool) {
+	if len(request.StateFilenames) == 0 {
+		util.Fatalf("Drift detection without state file(s) is not implemented; try --state")
+	}
+	if jsonFormat && config.Verbose && !config.Debug {
+		config.Verbose = false
+	}
+
+	verb := maybeTestVerb(checkVerb, request.DryRun)
+
+	stackManifest, componentsManifests, _, err := manifest.ParseManifest(request.ManifestFilenames)
+	if err != nil {
+		util.Fatalf("Unable to detect drift: %v", err)
+	}
+
+	osEnv, err := initOsEnv(request.OsEnvironmentMode)
+	if err != nil {
+		util.Fatalf("Unable to parse OS environment setup: %v", err)
+	}
+
+	stackBaseDir := util.Basedir(request.ManifestFilenames)
+	componentsBaseDir := request.ComponentsBaseDir
+	if componentsBaseDir == "" {
+		componentsBaseDir = stackBaseDir
+	}
+
+	order, err := manifest.GenerateLifecycleOrder(stackManifest)
+	if err != nil {
+		util.Fatalf("%v", err)
+	}
+	stackManifest.Lifecycle.Order = order
+	components := stackManifest.Components
+	checkComponentsManifests(components, componentsManifests)
+	manifest.CheckComponentsExist(components, request.Components...)
+
+	optionalRequires := parseRequiresTunning(stackManifest.Lifecycle.Requires)
+	requiresOfOptionalComponents := calculateRequiresOfOptionalComponents(componentsManifests, &stackManifest.Lifecycle, stackManifest.Requires)
+	stackRequires := maybeOmitCloudRequires(stackManifest.Requires, request.EnabledClouds)
+	stackProvides := checkStackRequires(stackRequires, optionalRequires, requiresOfOptionalComponents)
+
+	stateFiles, errs := storage.Check(request.StateFilenames, "state")
+	if len(errs) > 0 {
+		util.MaybeFatalf("Unable to check state files: %s", util.Errors2(errs...))
+	}
+	// drift status is written to state
+	osEnv = append(osEnv, lockState(request, stateFiles, "")...)
+	stateManifest, err := state.ParseState(stateFiles)
+	if err != nil {
+		util.Fatalf("Unable to load state %v: %v", request.StateFilenames, err)
+	}
+	stateUpdater := state.InitWriter(stateFiles, nil)
+
+	// implementation output must not mix with JSON report
+	var output io.Writer
+	if jsonFormat {
+		output = os.Stderr
+	}
+
+	incomplete := false
+	report := StackDrift{
+		Meta:       state.Metadata{Kind: stackManifest.Kind, Name: stackManifest.Meta.Name},
+		Components: make([]state.ComponentDrift, 0, len(order)),
+	}
+	for _, componentName := range order {
+		if len(request.Components) > 0 && !util.Contains(request.Components, componentName) {
+			continue
+		}
+		step, exist := stateManifest.Components[componentName]
+		if !exist || step.Status != "deployed" {
+			if config.Verbose {
+				log.Printf("Skip `%s`: not deployed", componentName)
+			}
+			continue
+		}
+
+		component := manifest.ComponentRefByName(components, componentName)
+		componentManifest := manifest.ComponentManifestByRef(componentsManifests, component)
+		dir := manifest.ComponentSourceDirFromRef(component, stackBaseDir, componentsBaseDir)
+
+		drift := state.ComponentDrift{Component: componentName}
+		if impl, _ := probeImplementation(dir, verb, componentManifest, stackBaseDir); !impl {
+			drift.Status = "unknown"
+			drift.Message = fmt.Sprintf("no `%s` implementation", verb)
+		} else {
+			if config.Verbose {
+				log.Printf(util.HighlightColor("%s ***%s***"), verb, componentName)
+			}
+			stackParameters := make(parameters.LockedParameters)
+			allOutputs := make(parameters.CapturedOutputs)
+			provides := util.CopyMap2(stackProvides)
+			state.MergeParsedState(stateManifest,
+				componentName, component.Depends, stackManifest.Lifecycle.Order, false,
+				stackParameters, allOutputs, provides)
+
+			expandedComponentParameters, errs := parameters.ExpandParameters(componentName, componentManifest.Meta.Kind, component.Depends,
+				stackParameters, allOutputs,
+				manifest.FlattenParameters(componentManifest.Parameters, componentManifest.Meta.Name))
+			if len(errs) > 0 {
+				drift.Status = "unknown"
+				drift.Message = fmt.Sprintf("parameters expansion failed: %s", util.Errors("; ", errs...))
+			} else {
+				componentParameters := parameters.MergeParameters(make(parameters.LockedParameters), expandedComponentParameters)
+				prepareComponentRequires(provides, componentManifest, stackParameters, allOutputs, optionalRequires, request.EnabledClouds)
+				stdout, _, _, err := delegate(context.Background(), verb, component, componentManifest, componentParameters,
+					dir, osEnv, "", stackBaseDir, output)
+				drift.Status, drift.Message = driftStatus(stdout, err)
+			}
+		}
+		drift.Timestamp = time.Now()
+		switch drift.Status {
+		case "drifted":
+			report.Drifted = true
+		case "error", "unknown":
+			incomplete = true
+		}
+		if config.Verbose {
+			log.Printf("Component `%s` is %s", componentName, drift.Status)
+		}
+		stateManifest = state.UpdateComponentDrift(stateManifest, componentName, drift.Status, drift.Message, drift.Timestamp)
+		stateUpdater(stateManifest)
+		report.Components = append(report.Components, drift)
+	}
+	util.Done()
+
+	if jsonFormat {
+		bytes, err := json.MarshalIndent(&report, "", "  ")
+		if err != nil {
+			util.Fatalf("Unable to marshal drift report into JSON: %v", err)
+		}
+		os.Stdout.Write(bytes)
+		os.Stdout.Write([]byte("\n"))
+	} else {
+		printDrift(&report)
+	}
+
+	if report.Drifted {
+		os.Exit(driftExitCode)
+	}
+	if incomplete {
+		os.Exit(driftErrorExitCode)
+	}
+}
+
+func printDrift(report *StackDrift) {
+	counts := make(map[string]int)
+	fmt.Printf("Drift of %s:\n", report.Meta.Name)
+	for _, drift := range report.Components {
+		counts[drift.Status]++
+		message := ""
+		if drift.Message != "" {
+			message = fmt.Sprintf(" (%s)", drift.Message)
+		}
+		fmt.Printf("\t%s: %s%s\n", drift.Component, drift.Status, message)
+	}
+	summary := make([]string, 0, len(counts))
+	for _, status := range []string{"in-sync", "drifted", "unknown", "error"} {
+		if count, exist := counts[status]; exist {
+			summary = append(summary, fmt.Sprintf("%d %s", count, status))
+		}
+	}
+	if len(summary) == 0 {
+		summary = append(summary, "no deployed components")
+	}
+	fmt.Printf("Summary: %s\n", strings.Join(summary, ", "))
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build !windows
+
+package lifecycle
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriftStatus(t *testing.T) {
+	status, _ := driftStatus([]byte("No changes.\n"), nil)
+	assert.Equal(t, "in-sync", status)
+
+	exit2 := fmt.Errorf("%w", exec.Command("sh", "-c", "exit 2").Run())
+	status, _ = driftStatus(nil, exit2)
+	assert.Equal(t, "drifted", status)
+
+	exit1 := fmt.Errorf("%w", exec.Command("sh", "-c", "exit 1").Run())
+	status, message := driftStatus(nil, exit1)
+	assert.Equal(t, "error", status)
+	assert.Equal(t, "exit status 1", message)
+
+	status, _ = driftStatus([]byte("Outputs:\ndrift = drifted\n"), nil)
+	assert.Equal(t, "drifted", status)
+
+	status, _ = driftStatus([]byte("Outputs:\ndrift = in-sync\n"), exit2)
+	assert.Equal(t, "in-sync", status)
+
+	// unexpected exit code is an error regardless of outputs
+	status, _ = driftStatus([]byte("Outputs:\ndrift = in-sync\n"), exit1)
+	assert.Equal(t, "error", status)
+
+	status, _ = driftStatus([]byte("Outputs:\ndrift = maybe\n"), nil)
+	assert.Equal(t, "unknown", status)
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/events"
+	"github.com/epam/hubctl/cmd/hub/parameters"
+	"github.com/epam/hubctl/cmd/hub/state"
+)
+
+func statusOf(err error) (string, string) {
+	if err != nil {
+		return "error", fmt.Sprintf("%v", err)
+	}
+	return "success", ""
+}
+
+func emitHookEnd(componentName, trigger, file string, start time.Time, err error) {
+	status, message := statusOf(err)
+	events.Emit(events.Event{Type: events.HookEnd, Component: componentName, Hook: trigger, File: file,
+		Status: status, Message: message, DurationSeconds: events.Seconds(time.Since(start))})
+}
+
+func emitReadyCondition(componentName, condition, status string, err error) {
+	message := ""
+	if err != nil {
+		status = "failed"
+		message = fmt.Sprintf("%v", err)
+	}
+	events.Emit(events.Event{Type: events.ReadyCondition, Component: componentName, Condition: condition,
+		Status: status, Message: message})
+}
+
+func emitOutputs(componentName string, outputs parameters.CapturedOutputs) {
+	if len(outputs) == 0 || !events.Enabled() {
+		return
+	}
+	values := make(map[string]string, len(outputs))
+	for _, output := range outputs {
+		value := state.MaybeMaskValue(output.Name, output.Value, false)
+		if strings.HasPrefix(output.Kind, "secret") && value != "" {
+			value = state.MaskedValue
+		}
+		values[output.Name] = value
+	}
+	events.Emit(events.Event{Type: events.Outputs, Component: componentName, Outputs: values})
+}
@@ -8,17 +8,48 @@ package lifecycle
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"syscall"
+	"time"
 
 	"github.com/mattn/go-isatty"
 
 	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/events"
 )
 
+// timeoutGracePeriod is the time given to the implementation process group to exit
+// after SIGTERM is sent on timeout, before it is killed
+const timeoutGracePeriod = 30 * time.Second
+
+type timeoutError struct {
+	err error
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("timed out: %v", e.err)
+}
+
+func isTimeout(err error) bool {
+	var timeout *timeoutError
+	return errors.As(err, &timeout)
+}
+
+// stdoutOrStderr returns stream to send sub-process output to, which is stdout unless
+// stdout is occupied by events
+func stdoutOrStderr() *os.File {
+	if events.ToStdout() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
 func goWait(routine func()) chan string {
 	ch := make(chan string)
 	wrapper := func() {
@@ -29,7 +60,12 @@ func goWait(routine func()) chan string {
 	return ch
 }
 
-func execImplementation(impl *exec.Cmd, passStdin, paginate bool) ([]byte, []byte, error) {
+// execImplementation runs impl sending sub-process output to the terminal or to `output`
+// if set, ie. when components are executed in parallel and the output must not interleave.
+// The output is also written to `logFile` if set.
+// When ctx has a deadline or interrupt is watched, the implementation is started in a separate
+// process group to be terminated as a whole on expiry, or to receive the forwarded signal.
+func execImplementation(ctx context.Context, impl *exec.Cmd, passStdin, paginate bool, output, logFile io.Writer) ([]byte, []byte, error) {
 	stderrImpl, err := impl.StderrPipe()
 	if err != nil {
 		return nil, nil, fmt.Errorf("Unable to obtain sub-process stderr pipe: %v", err)
@@ -41,10 +77,16 @@ func execImplementation(impl *exec.Cmd, passStdin, paginate bool) ([]byte, []byt
 
 	logOutput := log.Writer()
 
-	var stdout io.Writer = os.Stdout
+	var stdout io.Writer = stdoutOrStderr()
 	var stderr io.Writer = os.Stderr
+	var header io.Writer = stdoutOrStderr()
+	if output != nil {
+		stdout = output
+		stderr = output
+		header = output
+	}
 
-	if paginate && config.Tty && !config.Debug {
+	if paginate && output == nil && config.Tty && !config.Debug && !events.ToStdout() {
 		stdoutTerminal := isatty.IsTerminal(os.Stdout.Fd())
 		stderrTerminal := isatty.IsTerminal(os.Stderr.Fd())
 		to := os.Stdout
@@ -67,16 +109,20 @@ func execImplementation(impl *exec.Cmd, passStdin, paginate bool) ([]byte, []byt
 	var stderrBuffer bytes.Buffer
 	stdoutWritter := io.MultiWriter(&stdoutBuffer, stdout)
 	stderrWritter := io.MultiWriter(&stderrBuffer, stderr)
+	if logFile != nil {
+		stdoutWritter = io.MultiWriter(stdoutWritter, logFile)
+		stderrWritter = io.MultiWriter(stderrWritter, logFile)
+	}
 	if impl.Path != "" {
 		dir := impl.Dir
-		fmt.Printf("  Working dir: %s\n", dir)
-		fmt.Printf("  File: %s\n", impl.Path)
+		fmt.Fprintf(header, "  Working dir: %s\n", dir)
+		fmt.Fprintf(header, "  File: %s\n", impl.Path)
 		args := ""
 		if len(impl.Args) > 1 {
 			args = fmt.Sprintf("Args: %v", impl.Args[1:])
 		}
 		if args != "" {
-			fmt.Printf("--- %s\n", args)
+			fmt.Fprintf(header, "--- %s\n", args)
 		}
 	}
 	os.Stdout.Sync()
@@ -92,12 +138,28 @@ func execImplementation(impl *exec.Cmd, passStdin, paginate bool) ([]byte, []byt
 	// need not close the pipe themselves; however, an implication is that it is
 	// incorrect to call Wait before all reads from the pipe have completed.
 	// For the same reason, it is incorrect to call Run when using StdoutPipe.
+	_, hasDeadline := ctx.Deadline()
+	// interactive process must stay in the terminal foreground process group
+	forwardInterrupt := !passStdin && watchingInterrupt()
+	if hasDeadline || forwardInterrupt {
+		setProcessGroup(impl)
+	}
 	err = impl.Start()
+	var stopWatch func() bool
+	if err == nil {
+		if forwardInterrupt {
+			addInterruptible(impl)
+			defer removeInterruptible(impl)
+		}
+		if hasDeadline {
+			stopWatch = watchDeadline(ctx, impl)
+		}
+	}
 	<-stdoutComplete
 	<-stderrComplete
 
 	if impl.Path != "" {
-		fmt.Printf("--- \n")
+		fmt.Fprintf(header, "--- \n")
 	}
 	os.Stdout.Sync()
 	os.Stderr.Sync()
@@ -108,8 +170,45 @@ func execImplementation(impl *exec.Cmd, passStdin, paginate bool) ([]byte, []byt
 		err = impl.Wait()
 	}
 	if err != nil {
-		err = fmt.Errorf("%v", err)
+		err = fmt.Errorf("%w", err)
+	}
+	if stopWatch != nil && stopWatch() {
+		err = &timeoutError{err}
 	}
 
 	return stdoutBuffer.Bytes(), stderrBuffer.Bytes(), err
 }
+
+// watchDeadline terminates implementation process group when ctx deadline is exceeded:
+// SIGTERM is sent first, then SIGKILL after grace period. The returned function must be
+// called after the process exits; it returns true if the deadline was exceeded.
+func watchDeadline(ctx context.Context, impl *exec.Cmd) func() bool {
+	exited := make(chan struct{})
+	expired := make(chan bool, 1)
+	go func() {
+		select {
+		case <-exited:
+			expired <- false
+
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				// interrupt is forwarded by watchInterrupt()
+				expired <- false
+				return
+			}
+			log.Printf("Timeout: terminating `%s` (pid %d)", impl.Path, impl.Process.Pid)
+			signalProcessGroup(impl, syscall.SIGTERM)
+			select {
+			case <-exited:
+			case <-time.After(timeoutGracePeriod):
+				log.Printf("Timeout: killing `%s` (pid %d) after %v grace period", impl.Path, impl.Process.Pid, timeoutGracePeriod)
+				signalProcessGroup(impl, syscall.SIGKILL)
+			}
+			expired <- true
+		}
+	}()
+	return func() bool {
+		close(exited)
+		return <-expired
+	}
+}
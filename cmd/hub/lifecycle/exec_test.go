@@ -0,0 +1,34 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build !windows
+
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecImplementationTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := execImplementation(ctx, exec.Command("sh", "-c", "sleep 10; echo done"), false, false, &bytes.Buffer{}, nil)
+	assert.True(t, isTimeout(err), "Expected timeout error, got %v", err)
+	assert.Less(t, time.Since(start), 5*time.Second, "Process group must be terminated")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stdout, _, err := execImplementation(ctx, exec.Command("sh", "-c", "echo done"), false, false, &bytes.Buffer{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "done\n", string(stdout))
+}
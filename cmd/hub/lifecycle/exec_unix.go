@@ -0,0 +1,33 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build !windows
+
+package lifecycle
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+func setProcessGroup(impl *exec.Cmd) {
+	if impl.SysProcAttr == nil {
+		impl.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	impl.SysProcAttr.Setpgid = true
+}
+
+func signalProcessGroup(impl *exec.Cmd, sig os.Signal) {
+	if impl.Process == nil {
+		return
+	}
+	if err := syscall.Kill(-impl.Process.Pid, sig.(syscall.Signal)); err != nil && err != syscall.ESRCH {
+		util.Warn("Unable to send %v to process group %d: %v", sig, impl.Process.Pid, err)
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build windows
+
+package lifecycle
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(impl *exec.Cmd) {
+}
+
+// there are no signals on Windows, so the process is killed right away
+func signalProcessGroup(impl *exec.Cmd, sig os.Signal) {
+	if impl.Process != nil && sig != os.Interrupt {
+		impl.Process.Kill()
+	}
+}
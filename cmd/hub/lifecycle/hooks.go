@@ -0,0 +1,120 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/epam/hubctl/cmd/hub/parameters"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+const (
+	HubEnvVarNameHookTrigger     = "HUB_HOOK_TRIGGER"
+	HubEnvVarNameOperationStatus = "HUB_OPERATION_STATUS"
+	HubEnvVarNameFailedComponent = "HUB_FAILED_COMPONENT"
+	HubEnvVarNameErrorMessage    = "HUB_ERROR_MESSAGE"
+	HubEnvVarNameLogFile         = "HUB_LOG_FILE"
+)
+
+// hookFailure describes the failure passed to on-error-<verb> and always-<verb> hooks
+type hookFailure struct {
+	component string
+	message   string
+	logFile   string
+}
+
+func hookTriggerEnv(trigger string) []string {
+	return []string{fmt.Sprintf("%s=%s", HubEnvVarNameHookTrigger, trigger)}
+}
+
+// hookFailureEnv is merged into OS environment of on-error-<verb> and always-<verb> hooks
+func hookFailureEnv(status string, failure *hookFailure) []string {
+	env := []string{fmt.Sprintf("%s=%s", HubEnvVarNameOperationStatus, status)}
+	if failure != nil {
+		env = append(env, fmt.Sprintf("%s=%s", HubEnvVarNameErrorMessage, failure.message))
+		if failure.component != "" {
+			env = append(env, fmt.Sprintf("%s=%s", HubEnvVarNameFailedComponent, failure.component))
+		}
+		if failure.logFile != "" {
+			env = append(env, fmt.Sprintf("%s=%s", HubEnvVarNameLogFile, failure.logFile))
+		}
+	}
+	return env
+}
+
+// fireFinalHooks executes on-error-<verb> hooks if there is a failure, then always-<verb> hooks.
+// Hooks failures are reported but do not change the outcome of the operation.
+func fireFinalHooks(verb, status string, failure *hookFailure, osEnv []string,
+	fire func(trigger string, osEnv []string) ([]byte, []byte, error)) {
+
+	env := mergeOsEnviron(osEnv, hookFailureEnv(status, failure))
+	triggers := []string{"always-" + verb}
+	if failure != nil {
+		triggers = append([]string{"on-error-" + verb}, triggers...)
+	}
+	for _, trigger := range triggers {
+		if _, _, err := fire(trigger, env); err != nil {
+			util.Warn("One of %s hooks failed: %v", trigger, err)
+		}
+	}
+}
+
+// abortedFailure finds the failure of the mandatory component that aborted the operation
+func abortedFailure(failures []*hookFailure, aborted string) *hookFailure {
+	for _, failure := range failures {
+		if failure.message == aborted {
+			return failure
+		}
+	}
+	return &hookFailure{message: aborted}
+}
+
+func fireStackHooks(ctx context.Context, trigger string, stackBaseDir string, stackHooks []manifest.Hook,
+	stackParameters parameters.LockedParameters, osEnv []string, output io.Writer,
+) ([]byte, []byte, error) {
+	hooks := findHooksByTrigger(trigger, stackHooks)
+	if len(hooks) == 0 {
+		return nil, nil, nil
+	}
+	return runHooks(trigger, hooks, "", hookSearchDirs(stackBaseDir), stackParameters,
+		func(script string) ([]byte, []byte, error) {
+			return delegateStackHook(ctx, trigger, script, stackBaseDir, stackParameters, osEnv, output)
+		})
+}
+
+func delegateStackHook(ctx context.Context, trigger, script string, stackDir string,
+	stackParameters parameters.LockedParameters, osEnv []string, output io.Writer,
+) ([]byte, []byte, error) {
+	stackDir, err := filepath.Abs(stackDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	processEnv := make([]string, 0, len(stackParameters)+1)
+	for _, parameter := range stackParameters {
+		if parameter.Env != "" {
+			processEnv = append(processEnv, fmt.Sprintf("%s=%s", parameter.Env, util.MaybeJson(parameter.Value)))
+		}
+	}
+	processEnv = append(processEnv, fmt.Sprintf("%s=%s", HubEnvVarNameStackBasedir, stackDir))
+	command := &exec.Cmd{
+		Path: script,
+		Dir:  stackDir,
+		Env:  mergeOsEnviron(osEnv, processEnv, hookTriggerEnv(trigger)),
+	}
+	var logFile io.Writer
+	if file := openLogFile(ctx, "", fmt.Sprintf("%s-%s", trigger, filepath.Base(script))); file != nil {
+		defer file.Close()
+		logFile = file
+	}
+	return execImplementation(ctx, command, false, true, output, logFile)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build !windows
+
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/epam/hubctl/cmd/hub/parameters"
+)
+
+func TestFireStackFinalHooks(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "hooks.out")
+	script := []byte(`#!/bin/sh -e
+echo "$HUB_HOOK_TRIGGER $HUB_OPERATION_STATUS $HUB_FAILED_COMPONENT $HUB_LOG_FILE $STACK_NAME: $HUB_ERROR_MESSAGE" >> ` + out + `
+`)
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "notify"), script, 0755))
+
+	hooks := []manifest.Hook{
+		{File: "notify", Triggers: []string{"on-error-deploy", "always-*"}},
+	}
+	stackParameters := parameters.LockedParameters{
+		"hub.stackName": {Name: "hub.stackName", Value: "test", Env: "STACK_NAME"},
+	}
+	fire := func(trigger string, env []string) ([]byte, []byte, error) {
+		return fireStackHooks(context.Background(), trigger, dir, hooks, stackParameters, env, nil)
+	}
+
+	fireFinalHooks("deploy", "error",
+		&hookFailure{component: "a", message: "failed to deploy", logFile: "/logs/a-deploy.log"}, nil, fire)
+	fireFinalHooks("undeploy", "success", nil, nil, fire)
+
+	data, err := os.ReadFile(out)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"on-error-deploy error a /logs/a-deploy.log test: failed to deploy",
+		"always-deploy error a /logs/a-deploy.log test: failed to deploy",
+		"always-undeploy success   test: ",
+	}, strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"))
+}
+
+func TestAbortedFailure(t *testing.T) {
+	failures := []*hookFailure{
+		{component: "a", message: "optional a failed"},
+		{component: "b", message: "mandatory b failed"},
+	}
+	assert.Equal(t, "b", abortedFailure(failures, "mandatory b failed").component)
+	failure := abortedFailure(failures, "stack timeout")
+	assert.Equal(t, "", failure.component)
+	assert.Equal(t, "stack timeout", failure.message)
+}
+
+func TestLastLogFile(t *testing.T) {
+	logs := newOperationLogs(t.TempDir(), false, 0, "op")
+	if !assert.NotNil(t, logs) {
+		return
+	}
+	for _, log := range [][]string{{"a", "deploy"}, {"ab", "deploy"}, {"", "pre-deploy-hook.sh"}, {"a-b", "post-deploy-hook.sh"}} {
+		if file := logs.open(log[0], log[1]); assert.NotNil(t, file) {
+			file.Close()
+		}
+	}
+	// log of `a-b` component hook is not confused with `a` component log
+	assert.True(t, strings.HasSuffix(logs.lastLogFile("a"), "-a-deploy.log"))
+	assert.True(t, strings.HasSuffix(logs.lastLogFile("ab"), "-ab-deploy.log"))
+	assert.True(t, strings.HasSuffix(logs.lastLogFile("a-b"), "-a-b-post-deploy-hook.sh.log"))
+	assert.True(t, strings.HasSuffix(logs.lastLogFile(""), "-stack-pre-deploy-hook.sh.log"))
+	assert.Equal(t, "", logs.lastLogFile("c"))
+	assert.Equal(t, "", (*operationLogs)(nil).lastLogFile("a"))
+}
@@ -10,7 +10,9 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/exec"
 	"os/signal"
+	"sync"
 	"syscall"
 
 	"github.com/epam/hubctl/cmd/hub/config"
@@ -19,15 +21,27 @@ import (
 
 var interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
 
+// interruptible tracks running implementations and hooks while interrupt is watched.
+// Those are started in a separate process group, thus the signal received by Hub CTL
+// is forwarded to them.
+var interruptible = struct {
+	mutex     sync.Mutex
+	watching  bool
+	signal    os.Signal
+	processes map[*exec.Cmd]struct{}
+}{processes: make(map[*exec.Cmd]struct{})}
+
 func watchInterrupt() context.Context {
 	ctx, interrupted := context.WithCancel(context.Background())
 	sigs := make(chan os.Signal, 1)
 	unwatch := make(chan struct{})
 	signal.Notify(sigs, interruptSignals...)
+	setWatchingInterrupt(true)
 	go func() {
 		for {
 			select {
 			case sig := <-sigs:
+				forwardInterrupt(sig)
 				if ctx.Err() != nil {
 					os.Exit(3)
 				}
@@ -39,6 +53,7 @@ func watchInterrupt() context.Context {
 
 			case <-unwatch:
 				signal.Reset(interruptSignals...)
+				setWatchingInterrupt(false)
 				return
 			}
 		}
@@ -49,3 +64,53 @@ func watchInterrupt() context.Context {
 	})
 	return ctx
 }
+
+func setWatchingInterrupt(watching bool) {
+	interruptible.mutex.Lock()
+	defer interruptible.mutex.Unlock()
+	interruptible.watching = watching
+}
+
+func watchingInterrupt() bool {
+	interruptible.mutex.Lock()
+	defer interruptible.mutex.Unlock()
+	return interruptible.watching
+}
+
+// interruptSignal returns the signal received, if any
+func interruptSignal() os.Signal {
+	interruptible.mutex.Lock()
+	defer interruptible.mutex.Unlock()
+	return interruptible.signal
+}
+
+func addInterruptible(impl *exec.Cmd) {
+	interruptible.mutex.Lock()
+	defer interruptible.mutex.Unlock()
+	interruptible.processes[impl] = struct{}{}
+}
+
+func removeInterruptible(impl *exec.Cmd) {
+	interruptible.mutex.Lock()
+	defer interruptible.mutex.Unlock()
+	delete(interruptible.processes, impl)
+}
+
+func forwardInterrupt(sig os.Signal) {
+	interruptible.mutex.Lock()
+	defer interruptible.mutex.Unlock()
+	interruptible.signal = sig
+	for impl := range interruptible.processes {
+		if config.Debug {
+			log.Printf("Sending %v to `%s` (pid %d)", sig, impl.Path, impl.Process.Pid)
+		}
+		signalProcessGroup(impl, sig)
+	}
+}
+
+func interruptExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
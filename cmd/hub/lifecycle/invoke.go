@@ -7,6 +7,7 @@
 package lifecycle
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -38,10 +39,12 @@ func Invoke(request *Request) {
 	if len(errs) > 0 {
 		util.MaybeFatalf("Unable to check state file: %v", util.Errors2(errs...))
 	}
+	defer util.Done()
+	osEnv = append(osEnv, lockState(request, stateFiles, "")...)
 
 	order, err := manifest.GenerateLifecycleOrder(stackManifest)
 	if err != nil {
-		log.Fatal(err)
+		util.Fatalf("%v", err)
 	}
 	stackManifest.Lifecycle.Order = order
 
@@ -91,24 +94,28 @@ func Invoke(request *Request) {
 	if config.Debug {
 		log.Printf("Component `%s` directory: %s", request.Component, dir)
 	}
-	impl, err := findImplementation(dir, request.Verb, componentManifest)
-	if err != nil {
-		log.Fatalf("Failed to %s %s: %v", request.Verb, request.Component, err)
-	}
 	processEnv := mergeOsEnviron(
 		parametersInEnv(component, componentParameters, stackBaseDir),
 		additionalEnvironmentToList(additionalEnvironment))
-	impl.Env = mergeOsEnviron(osEnv, processEnv)
 	if config.Debug && len(processEnv) > 0 {
 		log.Print("Component environment:")
 		printEnvironment(processEnv)
 		if config.Trace {
 			log.Print("Full process environment:")
-			printEnvironment(impl.Env)
+			printEnvironment(mergeOsEnviron(osEnv, processEnv))
 		}
 	}
 
-	_, _, err = execImplementation(impl, true, false)
+	_, _, _, err = withRetry(context.Background(), componentManifest.Lifecycle.Retry, request.Component, request.Verb,
+		func() ([]byte, []byte, error) {
+			// exec.Cmd cannot be reused between attempts
+			impl, err := findImplementation(dir, request.Verb, componentManifest, stackBaseDir)
+			if err != nil {
+				util.Fatalf("Failed to %s %s: %v", request.Verb, request.Component, err)
+			}
+			impl.Env = mergeOsEnviron(impl.Env, osEnv, processEnv)
+			return execImplementation(context.Background(), impl, true, false, nil, nil)
+		})
 
 	if err != nil {
 		util.MaybeFatalf("Failed to %s %s: %v", request.Verb, request.Component, err)
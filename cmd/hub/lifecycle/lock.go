@@ -0,0 +1,44 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/epam/hubctl/cmd/hub/storage"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+// lockState acquires state files lock for the duration of the operation; the lock is released
+// at util.Done() after the state is written. Returns environment to pass the lock to nested
+// Hub CTL invocations.
+func lockState(request *Request, stateFiles *storage.Files, operationId string) []string {
+	if stateFiles == nil {
+		return nil
+	}
+	if operationId == "" {
+		u, err := uuid.NewRandom()
+		if err != nil {
+			log.Fatalf("Unable to generate operation Id random v4 UUID: %v", err)
+		}
+		operationId = u.String()
+	}
+	lock, err := storage.AcquireLock(stateFiles, request.Verb, operationId,
+		time.Duration(request.LockLeaseSeconds)*time.Second, time.Duration(request.LockWaitSeconds)*time.Second)
+	if err != nil {
+		util.MaybeFatalf("Unable to lock state: %v", err)
+		return nil
+	}
+	if lock == nil {
+		return nil
+	}
+	util.AtDoneLast(lock.Release)
+	return lock.Env()
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+const logTimestampFormat = "20060102T150405Z"
+
+var (
+	operationLogsDirRegexp = regexp.MustCompile(`^\d{8}T\d{6}Z-`)
+	logFilenameUnsafe      = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+// operationLogs writes output of implementations and hooks into separate files
+// under `<logs dir>/<timestamp>-<operation id>/` directory
+type operationLogs struct {
+	dir   string
+	gzip  bool
+	mutex sync.Mutex
+	files []string
+	last  map[string]string // component name, empty for stack => last log file
+}
+
+type operationLogsKey struct{}
+
+// newOperationLogs creates operation logs directory and removes old operations logs
+// directories to keep at most `retention` of them (0 = keep all)
+func newOperationLogs(logsDir string, gzip bool, retention int, operationId string) *operationLogs {
+	dir := filepath.Join(logsDir, fmt.Sprintf("%s-%s", time.Now().UTC().Format(logTimestampFormat), operationId))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		util.Warn("Unable to create logs directory `%s`: %v", dir, err)
+		return nil
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if retention > 0 {
+		pruneOperationLogs(logsDir, retention)
+	}
+	if config.Verbose {
+		log.Printf("Writing components logs to %s", dir)
+	}
+	return &operationLogs{dir: dir, gzip: gzip, last: make(map[string]string)}
+}
+
+func pruneOperationLogs(logsDir string, retention int) {
+	entries, err := os.ReadDir(logsDir)
+	if err != nil {
+		util.Warn("Unable to read logs directory `%s`: %v", logsDir, err)
+		return
+	}
+	dirs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && operationLogsDirRegexp.MatchString(entry.Name()) {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	if len(dirs) <= retention {
+		return
+	}
+	sort.Strings(dirs)
+	for _, name := range dirs[:len(dirs)-retention] {
+		dir := filepath.Join(logsDir, name)
+		if config.Debug {
+			log.Printf("Removing old logs directory %s", dir)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			util.Warn("Unable to remove old logs directory `%s`: %v", dir, err)
+		}
+	}
+}
+
+// open creates a new timestamped log file for `name`, ie. verb or hook, of `owner` component,
+// or of the stack if `owner` is empty; returns nil if logs are not enabled or the file cannot be created
+func (l *operationLogs) open(owner, name string) io.WriteCloser {
+	if l == nil {
+		return nil
+	}
+	prefix := owner
+	if prefix == "" {
+		prefix = "stack"
+	}
+	filename := fmt.Sprintf("%s-%s.log", time.Now().UTC().Format(logTimestampFormat), logFilename(prefix+"-"+name))
+	if l.gzip {
+		filename += ".gz"
+	}
+	path := filepath.Join(l.dir, filename)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		util.Warn("Unable to create log file `%s`: %v", path, err)
+		return nil
+	}
+	l.mutex.Lock()
+	l.files = append(l.files, path)
+	l.last[owner] = path
+	l.mutex.Unlock()
+	logFile := &logFile{file: file}
+	if l.gzip {
+		logFile.gzip = gzip.NewWriter(file)
+	}
+	return logFile
+}
+
+func (l *operationLogs) logFiles() []string {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]string(nil), l.files...)
+}
+
+// lastLogFile returns the most recent log file opened for `owner` component, or for the stack
+// if `owner` is empty
+func (l *operationLogs) lastLogFile(owner string) string {
+	if l == nil {
+		return ""
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.last[owner]
+}
+
+func logFilename(name string) string {
+	return strings.Trim(logFilenameUnsafe.ReplaceAllString(name, "_"), "_")
+}
+
+func withOperationLogs(ctx context.Context, logs *operationLogs) context.Context {
+	if logs == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, operationLogsKey{}, logs)
+}
+
+func openLogFile(ctx context.Context, owner, name string) io.WriteCloser {
+	logs, _ := ctx.Value(operationLogsKey{}).(*operationLogs)
+	return logs.open(owner, name)
+}
+
+// logFile serializes writes of sub-process stdout and stderr
+type logFile struct {
+	mutex sync.Mutex
+	file  *os.File
+	gzip  *gzip.Writer
+}
+
+func (f *logFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.gzip != nil {
+		return f.gzip.Write(p)
+	}
+	return f.file.Write(p)
+}
+
+func (f *logFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.gzip != nil {
+		if err := f.gzip.Close(); err != nil {
+			f.file.Close()
+			return err
+		}
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+func TestOperationLogs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"20220101T000000Z-op1", "20220102T000000Z-op2", "other"} {
+		assert.Nil(t, os.Mkdir(filepath.Join(dir, name), 0755))
+	}
+
+	logs := newOperationLogs(dir, true, 2, "op3")
+	if !assert.NotNil(t, logs) {
+		return
+	}
+	entries, _ := os.ReadDir(dir)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Len(t, names, 3)
+	assert.Contains(t, names, "20220102T000000Z-op2")
+	assert.Contains(t, names, "other")
+	assert.NotContains(t, names, "20220101T000000Z-op1")
+
+	ctx := withOperationLogs(context.Background(), logs)
+	file := openLogFile(ctx, "component", "deploy")
+	if !assert.NotNil(t, file) {
+		return
+	}
+	file.Write([]byte("deployed\n"))
+	assert.Nil(t, file.Close())
+
+	files := logs.logFiles()
+	if assert.Len(t, files, 1) {
+		assert.True(t, strings.HasSuffix(files[0], "-component-deploy.log.gz"), files[0])
+		data, err := os.ReadFile(files[0])
+		assert.Nil(t, err)
+		data, err = util.Gunzip(data)
+		assert.Nil(t, err)
+		assert.Equal(t, "deployed\n", string(data))
+	}
+
+	assert.Nil(t, openLogFile(context.Background(), "component", "deploy"))
+}
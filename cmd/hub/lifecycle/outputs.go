@@ -31,9 +31,12 @@ var (
 
 func captureOutputs(componentName, componentDir string, componentManifest *manifest.Manifest,
 	componentParameters parameters.LockedParameters,
-	textOutput []byte, random []byte) (parameters.RawOutputs, parameters.CapturedOutputs, []string, []error) {
+	textOutput []byte, toolOutputs parameters.RawOutputs, random []byte) (parameters.RawOutputs, parameters.CapturedOutputs, []string, []error) {
 
 	tfOutputs := parseTextOutput(textOutput)
+	for k, v := range toolOutputs {
+		tfOutputs[k] = v
+	}
 	secrets := extractSecrets(tfOutputs, random)
 	if len(secrets) > 0 {
 		if config.Trace {
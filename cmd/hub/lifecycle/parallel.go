@@ -0,0 +1,211 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+// componentAborted is raised by a component routine on mandatory component failure
+// when components are executed in parallel, so that the failure does not exit the
+// process while other components are still in flight
+type componentAborted struct {
+	msg string
+}
+
+// componentScheduler runs components as a dependency graph with at most `parallelism`
+// components in flight. Component routines are executed with the scheduler mutex held,
+// which guards state, outputs, and provides; the mutex is released via unlocked() around
+// blocking calls - hooks, implementation, ready conditions.
+type componentScheduler struct {
+	mutex       sync.Mutex
+	parallelism int
+	remaining   int
+	aborted     string
+}
+
+func newComponentScheduler(parallelism int, components int) *componentScheduler {
+	return &componentScheduler{parallelism: parallelism, remaining: components}
+}
+
+func (s *componentScheduler) unlocked(routine func()) {
+	s.mutex.Unlock()
+	defer s.mutex.Lock()
+	routine()
+}
+
+// last returns true if the calling component routine is the last one to complete
+func (s *componentScheduler) last() bool {
+	return s.remaining == 1
+}
+
+func (s *componentScheduler) fatalf(cleanup func(string, bool), format string, v ...interface{}) {
+	abortComponentf(cleanup, format, v...)
+}
+
+// abortComponentf is a substitute for util.MaybeFatalf2 that does not exit the process but
+// unwinds the component routine, to be caught by catchComponentAborted()
+func abortComponentf(cleanup func(string, bool), format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	if config.Force {
+		util.MaybeFatalf2(cleanup, "%s", msg)
+		return
+	}
+	log.Print(msg)
+	if cleanup != nil {
+		cleanup(msg, false)
+	}
+	panic(componentAborted{msg})
+}
+
+// catchComponentAborted executes component routine and returns the message of mandatory
+// component failure, if any
+func catchComponentAborted(routine func()) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			abort, ok := r.(componentAborted)
+			if !ok {
+				panic(r)
+			}
+			msg = abort.msg
+		}
+	}()
+	routine()
+	return
+}
+
+// run executes components in `order` respecting `depends` and returns the message
+// of mandatory component failure, if any
+func (s *componentScheduler) run(ctx context.Context, order []string, depends map[string][]string,
+	execute func(int, string)) string {
+
+	started := make(map[string]bool)
+	finished := make(map[string]bool)
+	completions := make(chan string)
+	running := 0
+
+	routine := func(index int, name string) {
+		defer func() {
+			s.mutex.Unlock()
+			completions <- name
+		}()
+		s.mutex.Lock()
+		msg := catchComponentAborted(func() { execute(index, name) })
+		if msg != "" && s.aborted == "" {
+			s.aborted = msg
+		}
+	}
+
+	s.mutex.Lock()
+	for {
+		if s.aborted == "" && ctx.Err() == nil {
+			for i, name := range order {
+				if running >= s.parallelism {
+					break
+				}
+				if started[name] || !allFinished(depends[name], finished) {
+					continue
+				}
+				started[name] = true
+				running++
+				go routine(i, name)
+			}
+		}
+		if running == 0 {
+			break
+		}
+		s.mutex.Unlock()
+		name := <-completions
+		s.mutex.Lock()
+		finished[name] = true
+		running--
+		s.remaining--
+	}
+	s.mutex.Unlock()
+
+	if s.aborted == "" && ctx.Err() == nil && len(started) < len(order) {
+		util.Warn("Not all components were scheduled due to unsatisfied dependencies: %d out of %d",
+			len(started), len(order))
+	}
+	return s.aborted
+}
+
+func allFinished(names []string, finished map[string]bool) bool {
+	for _, name := range names {
+		if !finished[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// componentsGraph calculates components dependencies as declared by `depends:` and implied
+// by requirements provided by components preceding in lifecycle order. On undeploy the graph
+// is reversed - a component is undeployed after all components depending on it.
+func componentsGraph(order []string, components []manifest.ComponentRef,
+	componentsManifests []manifest.Manifest, isUndeploy bool) map[string][]string {
+
+	depends := make(map[string][]string)
+	for i, name := range order {
+		component := manifest.ComponentRefByName(components, name)
+		componentManifest := manifest.ComponentManifestByRef(componentsManifests, component)
+		deps := make([]string, 0, len(component.Depends))
+		for _, dep := range component.Depends {
+			if util.Contains(order, dep) {
+				deps = append(deps, dep)
+			}
+		}
+		for _, prev := range order[:i] {
+			if util.Contains(deps, prev) {
+				continue
+			}
+			prevManifest := manifest.ComponentManifestByRef(componentsManifests,
+				manifest.ComponentRefByName(components, prev))
+			for _, req := range componentManifest.Requires {
+				if util.Contains(prevManifest.Provides, req) {
+					deps = append(deps, prev)
+					break
+				}
+			}
+		}
+		depends[name] = deps
+	}
+
+	if isUndeploy {
+		reverse := make(map[string][]string)
+		for _, name := range order {
+			for _, dep := range depends[name] {
+				reverse[dep] = append(reverse[dep], name)
+			}
+		}
+		depends = reverse
+	}
+
+	if config.Debug {
+		log.Print("Components dependency graph:")
+		util.PrintDeps(depends)
+	}
+	return depends
+}
+
+func printComponentOutput(componentName string, output *util.Buffer) {
+	text := output.String()
+	if text == "" {
+		return
+	}
+	log.Printf("--- %s output", componentName)
+	out := stdoutOrStderr()
+	out.WriteString(text)
+	out.Sync()
+}
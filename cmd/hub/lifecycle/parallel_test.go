@@ -0,0 +1,92 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComponentsGraph(t *testing.T) {
+	components := []manifest.ComponentRef{
+		{Name: "dns"},
+		{Name: "kubernetes", Depends: []string{"dns"}},
+		{Name: "ingress"},
+		{Name: "monitoring"},
+	}
+	componentsManifests := []manifest.Manifest{
+		{Meta: manifest.Metadata{Name: "dns"}},
+		{Meta: manifest.Metadata{Name: "kubernetes"}, Provides: []string{"kubernetes"}},
+		{Meta: manifest.Metadata{Name: "ingress"}, Requires: []string{"kubernetes"}},
+		{Meta: manifest.Metadata{Name: "monitoring"}},
+	}
+	order := []string{"dns", "kubernetes", "ingress", "monitoring"}
+
+	depends := componentsGraph(order, components, componentsManifests, false)
+	assert.Empty(t, depends["dns"])
+	assert.Equal(t, []string{"dns"}, depends["kubernetes"])
+	assert.Equal(t, []string{"kubernetes"}, depends["ingress"], "Requirement provided by preceding component is a dependency")
+	assert.Empty(t, depends["monitoring"])
+
+	reverse := componentsGraph(order, components, componentsManifests, true)
+	assert.Equal(t, []string{"kubernetes"}, reverse["dns"])
+	assert.Equal(t, []string{"ingress"}, reverse["kubernetes"])
+	assert.Empty(t, reverse["ingress"])
+}
+
+func TestSchedulerRespectsDependenciesAndParallelism(t *testing.T) {
+	order := []string{"a", "b", "c", "d", "e"}
+	depends := map[string][]string{
+		"c": {"a", "b"},
+		"e": {"c"},
+	}
+	scheduler := newComponentScheduler(2, len(order))
+
+	inFlight := 0
+	maxInFlight := 0
+	completed := make([]string, 0, len(order))
+	aborted := scheduler.run(context.Background(), order, depends, func(_ int, name string) {
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		scheduler.unlocked(func() { time.Sleep(10 * time.Millisecond) })
+		for _, dep := range depends[name] {
+			assert.Contains(t, completed, dep, "Component `%s` started before dependency `%s` completed", name, dep)
+		}
+		inFlight--
+		completed = append(completed, name)
+	})
+
+	assert.Empty(t, aborted)
+	assert.ElementsMatch(t, order, completed)
+	assert.Equal(t, 2, maxInFlight)
+}
+
+func TestSchedulerStopsOnAbort(t *testing.T) {
+	order := []string{"a", "b", "c"}
+	depends := map[string][]string{
+		"b": {"a"},
+		"c": {"b"},
+	}
+	scheduler := newComponentScheduler(4, len(order))
+
+	executed := make([]string, 0, len(order))
+	aborted := scheduler.run(context.Background(), order, depends, func(_ int, name string) {
+		executed = append(executed, name)
+		if name == "b" {
+			scheduler.fatalf(nil, "Component `%s` failed", name)
+		}
+	})
+
+	assert.Equal(t, "Component `b` failed", aborted)
+	assert.Equal(t, []string{"a", "b"}, executed)
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/epam/hubctl/cmd/hub/parameters"
+	"github.com/epam/hubctl/cmd/hub/state"
+	"github.com/epam/hubctl/cmd/hub/storage"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+type ComponentPlan struct {
+	Component  string              `json:"component"`
+	Status     string              `json:"status"` // new, changed, unchanged, error
+	Message    string              `json:"message,omitempty"`
+	Parameters []state.ValueChange `json:"parameters,omitempty"`
+}
+
+type StackPlan struct {
+	Meta       state.Metadata  `json:"meta"`
+	Components []ComponentPlan `json:"components"`
+}
+
+// planComponent compares component parameters expanded for deploy with the parameters
+// component was deployed with as recorded in state
+func planComponent(componentName string, expandedParameters []parameters.LockedParameter,
+	stateManifest *state.StateManifest, showSecrets bool) ComponentPlan {
+
+	plan := ComponentPlan{Component: componentName}
+	var step *state.StateStep
+	if stateManifest != nil {
+		step = stateManifest.Components[componentName]
+	}
+	if step == nil || step.Status == "" {
+		plan.Status = "new"
+		plan.Parameters = state.DiffParameters(expandedParameters, nil, showSecrets)
+		return plan
+	}
+	plan.Parameters = state.DiffParameters(expandedParameters, step.Parameters, showSecrets)
+	if len(plan.Parameters) > 0 {
+		plan.Status = "changed"
+	} else if step.Status != "deployed" {
+		plan.Status = "changed"
+		plan.Message = fmt.Sprintf("state status is `%s`", step.Status)
+	} else {
+		plan.Status = "unchanged"
+	}
+	return plan
+}
+
+// Plan expands components parameters the same way deploy does, but without executing
+// components, and compares them with the state
+func Plan(request *Request, showSecrets, jsonFormat bool) {
+	if jsonFormat && config.Verbose && !config.Debug {
+		config.Verbose = false
+	}
+
+	stackManifest, componentsManifests, chosenManifestFilename, err := manifest.ParseManifest(request.ManifestFilenames)
+	if err != nil {
+		log.Fatalf("Unable to plan: %s", err)
+	}
+
+	environment, err := util.ParseKvList(request.EnvironmentOverrides)
+	if err != nil {
+		log.Fatalf("Unable to parse environment settings `%s`: %v", request.EnvironmentOverrides, err)
+	}
+
+	order, err := manifest.GenerateLifecycleOrder(stackManifest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	stackManifest.Lifecycle.Order = order
+
+	components := stackManifest.Components
+	checkComponentsManifests(components, componentsManifests)
+	checkLifecycleOrder(components, stackManifest.Lifecycle)
+	checkComponentsDepends(components, order)
+	manifest.CheckComponentsExist(components, append(request.Components, request.OffsetComponent, request.LimitComponent)...)
+	optionalRequires := parseRequiresTunning(stackManifest.Lifecycle.Requires)
+	requiresOfOptionalComponents := calculateRequiresOfOptionalComponents(componentsManifests, &stackManifest.Lifecycle, stackManifest.Requires)
+	stackRequires := maybeOmitCloudRequires(stackManifest.Requires, request.EnabledClouds)
+	provides := checkStackRequires(stackRequires, optionalRequires, requiresOfOptionalComponents)
+	mergePlatformProvides(provides, stackManifest.Platform.Provides)
+
+	var stateManifest *state.StateManifest
+	if len(request.StateFilenames) > 0 {
+		stateFiles, errs := storage.Check(request.StateFilenames, "state")
+		if len(errs) > 0 {
+			util.MaybeFatalf("Unable to check state files: %s", util.Errors2(errs...))
+		}
+		parsed, err := state.ParseState(stateFiles)
+		if err != nil {
+			if err != os.ErrNotExist {
+				log.Fatalf("Failed to read %v state files: %v", request.StateFilenames, err)
+			}
+			if config.Verbose {
+				log.Printf("No state found in %v - all components are new", request.StateFilenames)
+			}
+		} else {
+			stateManifest = parsed
+		}
+	}
+
+	deploymentId := stateStackParameter(stateManifest, deploymentIdParameterName)
+	stackName := stateStackParameter(stateManifest, stackNameParameterName)
+	extraExpansionValues := []manifest.Parameter{
+		{Name: deploymentIdParameterName, Value: deploymentId},
+		{Name: stackNameParameterName, Value: stackName},
+	}
+
+	stackParameters, errs := parameters.LockParameters(
+		manifest.FlattenParameters(stackManifest.Parameters, chosenManifestFilename),
+		extraExpansionValues,
+		func(parameter manifest.Parameter) (interface{}, error) {
+			return AskParameter(parameter, environment,
+				request.Environment, request.StackInstance, request.Application,
+				true)
+		})
+	if len(errs) > 0 {
+		log.Fatalf("Failed to lock stack parameters:\n\t%s", util.Errors("\n\t", errs...))
+	}
+	if stateManifest != nil {
+		checkStateMatch(stateManifest, stackManifest, stackParameters)
+		state.MergeParsedStateParametersAndProvides(stateManifest, stackParameters, nil)
+	}
+	addLockedParameter(stackParameters, deploymentIdParameterName, "DEPLOYMENT_ID", deploymentId)
+	addLockedParameter(stackParameters, stackNameParameterName, "STACK_NAME", stackName)
+
+	offsetComponentIndex := util.Index(order, request.OffsetComponent)
+	limitComponentIndex := util.Index(order, request.LimitComponent)
+
+	plan := StackPlan{
+		Meta:       state.Metadata{Kind: stackManifest.Kind, Name: stackManifest.Meta.Name},
+		Components: make([]ComponentPlan, 0, len(order)),
+	}
+	for componentIndex, componentName := range order {
+		component := manifest.ComponentRefByName(components, componentName)
+		componentManifest := manifest.ComponentManifestByRef(componentsManifests, component)
+
+		if !((len(request.Components) > 0 && !util.Contains(request.Components, componentName)) ||
+			(offsetComponentIndex >= 0 && componentIndex < offsetComponentIndex) ||
+			(limitComponentIndex >= 0 && componentIndex > limitComponentIndex)) {
+
+			outputs := make(parameters.CapturedOutputs)
+			if stateManifest != nil {
+				state.MergeParsedStateOutputs(stateManifest,
+					componentName, component.Depends, order, true,
+					outputs)
+			}
+			expandedComponentParameters, expansionErrs := parameters.ExpandParameters(componentName, componentManifest.Meta.Kind, component.Depends,
+				stackParameters, outputs,
+				manifest.FlattenParameters(componentManifest.Parameters, componentManifest.Meta.Name))
+			expandedComponentParameters = addHubProvides(expandedComponentParameters, provides)
+			componentPlan := planComponent(componentName, expandedComponentParameters, stateManifest, showSecrets)
+			if len(expansionErrs) > 0 {
+				componentPlan.Status = "error"
+				componentPlan.Message = fmt.Sprintf("parameters expansion failed: %s", util.Errors("; ", expansionErrs...))
+			}
+			plan.Components = append(plan.Components, componentPlan)
+		}
+
+		// accumulate provides in lifecycle order, as on deploy
+		if stateManifest != nil {
+			for prov, by := range stateManifest.Provides {
+				if util.Contains(by, componentName) && !util.Contains(provides[prov], componentName) {
+					provides[prov] = append(provides[prov], componentName)
+				}
+			}
+		}
+	}
+
+	if jsonFormat {
+		bytes, err := json.MarshalIndent(&plan, "", "  ")
+		if err != nil {
+			log.Fatalf("Unable to marshal plan into JSON: %v", err)
+		}
+		os.Stdout.Write(bytes)
+		os.Stdout.Write([]byte("\n"))
+		return
+	}
+	printPlan(&plan)
+}
+
+func printPlan(plan *StackPlan) {
+	counts := make(map[string]int)
+	fmt.Printf("Plan for %s:\n", plan.Meta.Name)
+	for _, componentPlan := range plan.Components {
+		counts[componentPlan.Status]++
+		message := ""
+		if componentPlan.Message != "" {
+			message = fmt.Sprintf(" (%s)", componentPlan.Message)
+		}
+		fmt.Printf("\t%s: %s%s\n", componentPlan.Component, componentPlan.Status, message)
+		if componentPlan.Status != "new" || config.Debug {
+			for _, change := range componentPlan.Parameters {
+				fmt.Printf("\t\t%s\n", state.FormatValueChange(change))
+			}
+		}
+	}
+	summary := make([]string, 0, len(counts))
+	for _, status := range []string{"new", "changed", "unchanged", "error"} {
+		if count, exist := counts[status]; exist {
+			summary = append(summary, fmt.Sprintf("%d %s", count, status))
+		}
+	}
+	fmt.Printf("Summary: %s\n", strings.Join(summary, ", "))
+}
+
+func stateStackParameter(stateManifest *state.StateManifest, name string) string {
+	if stateManifest != nil {
+		for _, p := range stateManifest.StackParameters {
+			if p.Name == name {
+				return util.String(p.Value)
+			}
+		}
+	}
+	return ""
+}
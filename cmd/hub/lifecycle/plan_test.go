@@ -0,0 +1,63 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"testing"
+
+	"github.com/epam/hubctl/cmd/hub/parameters"
+	"github.com/epam/hubctl/cmd/hub/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanComponent(t *testing.T) {
+	stateManifest := &state.StateManifest{
+		Components: map[string]*state.StateStep{
+			"ingress": {
+				Status: "deployed",
+				Parameters: []parameters.LockedParameter{
+					{Name: "dns.domain", Value: "dev.example.com"},
+					{Name: "ingress.password", Value: "old"},
+					{Name: "ingress.class", Value: "nginx"},
+				},
+			},
+			"failed": {
+				Status:     "error",
+				Parameters: []parameters.LockedParameter{{Name: "dns.domain", Value: "dev.example.com"}},
+			},
+		},
+	}
+
+	plan := planComponent("dns", []parameters.LockedParameter{{Name: "dns.domain", Value: "dev.example.com"}},
+		stateManifest, false)
+	assert.Equal(t, "new", plan.Status)
+
+	plan = planComponent("failed", []parameters.LockedParameter{{Name: "dns.domain", Value: "dev.example.com"}},
+		stateManifest, false)
+	assert.Equal(t, "changed", plan.Status, "Component not in `deployed` status must be changed")
+	assert.Empty(t, plan.Parameters)
+
+	expanded := []parameters.LockedParameter{
+		{Name: "dns.domain", Value: "dev.example.com"},
+		{Name: "ingress.password", Value: "new"},
+		{Name: "ingress.replicas", Value: 2},
+	}
+	plan = planComponent("ingress", expanded, stateManifest, false)
+	assert.Equal(t, "changed", plan.Status)
+	assert.Equal(t, []state.ValueChange{
+		{Name: "ingress.password", Change: "changed", Value: "(masked)", Was: "(masked)"},
+		{Name: "ingress.replicas", Change: "added", Value: "2"},
+		{Name: "ingress.class", Change: "removed", Was: "nginx"},
+	}, plan.Parameters)
+
+	plan = planComponent("ingress", expanded[:1], stateManifest, true)
+	assert.Contains(t, plan.Parameters, state.ValueChange{Name: "ingress.password", Change: "removed", Was: "old"},
+		"Secrets must be shown with showSecrets")
+
+	plan = planComponent("ingress", stateManifest.Components["ingress"].Parameters, stateManifest, false)
+	assert.Equal(t, "unchanged", plan.Status)
+}
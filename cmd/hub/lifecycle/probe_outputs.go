@@ -0,0 +1,106 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+
+	"github.com/epam/hubctl/cmd/hub/parameters"
+)
+
+var toolOutputsFormats = []string{"terraform", "json", "cloudformation", "helmfile", "ansible"}
+
+// toolOutputs captures outputs from tool's JSON after successful verb execution
+type toolOutputs struct {
+	command *exec.Cmd // nil to parse verb stdout
+	format  string
+}
+
+// capture executes outputs command, or parses verb stdout, into raw outputs
+func (outputs *toolOutputs) capture(ctx context.Context, stdout []byte) (parameters.RawOutputs, error) {
+	data := stdout
+	if outputs.command != nil {
+		command := exec.CommandContext(ctx, outputs.command.Path, outputs.command.Args[1:]...)
+		command.Dir = outputs.command.Dir
+		command.Env = outputs.command.Env
+		var stderr bytes.Buffer
+		command.Stderr = &stderr
+		out, err := command.Output()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to capture outputs: %v%s", err, lastOutputLine(stderr.Bytes()))
+		}
+		data = out
+	}
+	return parseToolOutputs(outputs.format, data)
+}
+
+func parseToolOutputs(format string, data []byte) (parameters.RawOutputs, error) {
+	outputs := make(parameters.RawOutputs)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return outputs, nil
+	}
+	var err error
+	switch format {
+	case "terraform": // {"name": {"value": ..., "sensitive": false}}
+		var document map[string]struct {
+			Value interface{} `json:"value"`
+		}
+		if err = json.Unmarshal(data, &document); err == nil {
+			for name, output := range document {
+				outputs[name] = jsonValueString(output.Value)
+			}
+		}
+	case "json": // {"name": value}
+		var document map[string]interface{}
+		if err = json.Unmarshal(data, &document); err == nil {
+			for name, value := range document {
+				outputs[name] = jsonValueString(value)
+			}
+		}
+	case "cloudformation": // [{"OutputKey": "name", "OutputValue": "value"}]
+		var document []struct {
+			OutputKey   string
+			OutputValue string
+		}
+		if err = json.Unmarshal(data, &document); err == nil {
+			for _, output := range document {
+				outputs[output.OutputKey] = output.OutputValue
+			}
+		}
+	case "helmfile": // [{"name": "release", "namespace": "ns", "chart": "repo/chart", "version": "1.0.0"}]
+		var document []map[string]interface{}
+		if err = json.Unmarshal(data, &document); err == nil {
+			for _, release := range document {
+				name := jsonValueString(release["name"])
+				for _, key := range []string{"namespace", "chart", "version"} {
+					if value, exist := release[key]; exist {
+						outputs[name+"."+key] = jsonValueString(value)
+					}
+				}
+			}
+		}
+	case "ansible": // {"global_custom_stats": {"name": value}}
+		var document struct {
+			Stats map[string]interface{} `json:"global_custom_stats"`
+		}
+		if err = json.Unmarshal(data, &document); err == nil {
+			for name, value := range document.Stats {
+				outputs[name] = jsonValueString(value)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("Unknown outputs format `%s`", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse %s outputs JSON: %v", format, err)
+	}
+	return outputs, nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build !windows
+
+package lifecycle
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/parameters"
+)
+
+func TestToolOutputsCapture(t *testing.T) {
+	dir := t.TempDir()
+	outputs := &toolOutputs{
+		command: toolCommand(dir, `echo '{"endpoint": "https://api", "port": 443}'`, nil),
+		format:  "json",
+	}
+	raw, err := outputs.capture(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, parameters.RawOutputs{"endpoint": "https://api", "port": "443"}, raw)
+
+	outputs = &toolOutputs{format: "ansible"}
+	raw, err = outputs.capture(context.Background(), []byte(`{"plays": [], "global_custom_stats": {"ip": "10.0.0.1"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, parameters.RawOutputs{"ip": "10.0.0.1"}, raw)
+
+	outputs = &toolOutputs{command: toolCommand(dir, "exit 1", nil), format: "json"}
+	_, err = outputs.capture(context.Background(), nil)
+	assert.NotNil(t, err)
+}
+
+func TestParseToolOutputs(t *testing.T) {
+	raw, err := parseToolOutputs("terraform",
+		[]byte(`{"vpc_id": {"value": "vpc-1", "sensitive": false}, "subnets": {"value": ["a", "b"]}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, parameters.RawOutputs{"vpc_id": "vpc-1", "subnets": `["a","b"]`}, raw)
+
+	raw, err = parseToolOutputs("cloudformation", []byte(`[{"OutputKey": "VpcId", "OutputValue": "vpc-2"}]`))
+	assert.Nil(t, err)
+	assert.Equal(t, parameters.RawOutputs{"VpcId": "vpc-2"}, raw)
+
+	raw, err = parseToolOutputs("helmfile",
+		[]byte(`[{"name": "ingress", "namespace": "kube-system", "enabled": true, "chart": "nginx/ingress", "version": "4.0.1"}]`))
+	assert.Nil(t, err)
+	assert.Equal(t, parameters.RawOutputs{"ingress.namespace": "kube-system", "ingress.chart": "nginx/ingress",
+		"ingress.version": "4.0.1"}, raw)
+
+	raw, err = parseToolOutputs("cloudformation", []byte("\n"))
+	assert.Nil(t, err)
+	assert.Empty(t, raw)
+
+	_, err = parseToolOutputs("terraform", []byte("Outputs:"))
+	assert.NotNil(t, err)
+	_, err = parseToolOutputs("xml", []byte("{}"))
+	assert.NotNil(t, err)
+}
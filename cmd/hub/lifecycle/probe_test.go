@@ -27,7 +27,7 @@ func TestProbeARM(t *testing.T) {
 		Requires: []string{"foo"},
 	}
 
-	found, err := probeImplementation("./foo", "deploy", &requiresFoo)
+	found, err := probeImplementation("./foo", "deploy", &requiresFoo, "")
 	if err != nil {
 		assert.Errorf(t, err, "When 'foo' in requires is not a well known, should not find implementation and fail with error", err)
 	}
@@ -36,7 +36,7 @@ func TestProbeARM(t *testing.T) {
 	requiresARM := manifest.Manifest{
 		Requires: []string{"arm"},
 	}
-	found, err = probeImplementation("./foo", "deploy", &requiresARM)
+	found, err = probeImplementation("./foo", "deploy", &requiresARM, "")
 	if err != nil {
 		assert.NoErrorf(t, err, "When 'arm' in component requires, it should resolve to implementation %v", err)
 	}
@@ -49,7 +49,7 @@ func TestFindARMImplementation(t *testing.T) {
 	requiresARM := manifest.Manifest{
 		Requires: []string{"arm"},
 	}
-	extension, err := findImplementation("./foo", "deploy", &requiresARM)
+	extension, err := findImplementation("./foo", "deploy", &requiresARM, "")
 	if err != nil {
 		assert.Fail(t, "When 'arm' in component requires, it should resolve to extension. Error is not expected here. Error is %v", err)
 	}
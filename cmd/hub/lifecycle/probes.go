@@ -0,0 +1,386 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/ext"
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+const probesFilename = "probes.yaml"
+
+//go:embed builtin-probes.yaml
+var builtinProbes []byte
+
+// implementationProbe describes how to detect component implementation by marker files,
+// and how to execute verbs: via hub-component-<extension>-<verb> extension, or via commands
+type implementationProbe struct {
+	Name     string `yaml:"name"`
+	Priority int    `yaml:"priority"`
+	Disabled bool   `yaml:"disabled"`
+	// globs relative to component directory, at least one must match a file
+	Markers []string `yaml:"markers"`
+	// regexp the first marker file found must match
+	Contains string `yaml:"contains"`
+	// component must have the requirement
+	Requires string `yaml:"requires"`
+	Binary   string `yaml:"binary"`
+	// path to try when binary is not found in PATH
+	Fallback string `yaml:"fallback"`
+	// probe matches only if binary is found in PATH
+	RequireBinary bool   `yaml:"requireBinary"`
+	Extension     string `yaml:"extension"`
+	// single command is executed directly, not via shell
+	Exec bool `yaml:"exec"`
+	// verb or glob => commands
+	Verbs map[string][]string `yaml:"verbs"`
+	Env   []string            `yaml:"env"`
+	// command printing outputs as JSON; if empty then verb stdout is parsed
+	Outputs string `yaml:"outputs"`
+	Format  string `yaml:"format"`
+}
+
+// verbs that produce no outputs, in addition to `-test` verbs
+var toolNoOutputsVerbs = []string{"undeploy", checkVerb}
+
+var (
+	probesLock  sync.Mutex
+	probesCache = make(map[string][]implementationProbe)
+)
+
+// implementationProbes returns probes in priority order: built-in probes merged with ~/.hub/probes.yaml
+// and probes.yaml in stack directory
+func implementationProbes(stackBaseDir string) ([]implementationProbe, error) {
+	probesLock.Lock()
+	defer probesLock.Unlock()
+
+	if probes, exist := probesCache[stackBaseDir]; exist {
+		return probes, nil
+	}
+	filenames := make([]string, 0, 2)
+	if home := os.Getenv("HOME"); home != "" {
+		filenames = append(filenames, filepath.Join(home, ".hub", probesFilename))
+	}
+	filenames = append(filenames, filepath.Join(stackBaseDir, probesFilename))
+	probes, err := loadProbes(filenames)
+	if err != nil {
+		return nil, err
+	}
+	probesCache[stackBaseDir] = probes
+	return probes, nil
+}
+
+func loadProbes(filenames []string) ([]implementationProbe, error) {
+	probes, err := mergeProbes(nil, builtinProbes)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load built-in implementation probes: %v", err)
+	}
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			if util.NoSuchFile(err) {
+				continue
+			}
+			return nil, fmt.Errorf("Unable to read implementation probes: %v", err)
+		}
+		probes, err = mergeProbes(probes, data)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load implementation probes from %s: %v", filename, err)
+		}
+		if config.Debug {
+			log.Printf("Loaded implementation probes from %s", filename)
+		}
+	}
+
+	enabled := make([]implementationProbe, 0, len(probes))
+	for _, probe := range probes {
+		if probe.Disabled {
+			continue
+		}
+		if err := probe.validate(); err != nil {
+			return nil, fmt.Errorf("Invalid `%s` implementation probe: %v", probe.Name, err)
+		}
+		enabled = append(enabled, probe)
+	}
+	sort.SliceStable(enabled, func(i, j int) bool { return enabled[i].Priority < enabled[j].Priority })
+	return enabled, nil
+}
+
+// mergeProbes overlays probes document on top of probes with the same name;
+// set fields replace existing values, verbs are merged
+func mergeProbes(probes []implementationProbe, data []byte) ([]implementationProbe, error) {
+	var document struct {
+		Probes []yaml.MapSlice `yaml:"probes"`
+	}
+	if err := yaml.UnmarshalStrict(data, &document); err != nil {
+		return nil, err
+	}
+	for i, entry := range document.Probes {
+		name := ""
+		for _, item := range entry {
+			if item.Key == "name" {
+				name, _ = item.Value.(string)
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("probes[%d]: `name` is not set", i)
+		}
+		overlay, err := yaml.Marshal(entry)
+		if err != nil {
+			return nil, fmt.Errorf("probe `%s`: %v", name, err)
+		}
+		index := -1
+		var probe implementationProbe
+		for j := range probes {
+			if probes[j].Name == name {
+				index = j
+				probe = probes[j]
+				// yaml decoder updates maps in place
+				probe.Verbs = make(map[string][]string, len(probes[j].Verbs))
+				for verb, commands := range probes[j].Verbs {
+					probe.Verbs[verb] = commands
+				}
+				break
+			}
+		}
+		// strict decoder rejects keys already set in map, so the overlay is validated separately
+		if err := yaml.UnmarshalStrict(overlay, &implementationProbe{}); err != nil {
+			return nil, fmt.Errorf("probe `%s`: %v", name, err)
+		}
+		if err := yaml.Unmarshal(overlay, &probe); err != nil {
+			return nil, fmt.Errorf("probe `%s`: %v", name, err)
+		}
+		if index >= 0 {
+			probes[index] = probe
+		} else {
+			probes = append(probes, probe)
+		}
+	}
+	return probes, nil
+}
+
+func (probe *implementationProbe) validate() error {
+	if len(probe.Markers) == 0 && probe.Requires == "" {
+		return errors.New("either `markers` or `requires` must be set")
+	}
+	if probe.Extension == "" && len(probe.Verbs) == 0 {
+		return errors.New("either `extension` or `verbs` must be set")
+	}
+	if probe.Exec {
+		for verb, commands := range probe.Verbs {
+			if len(commands) > 1 {
+				return fmt.Errorf("`%s` must have single command to `exec`", verb)
+			}
+		}
+	}
+	if probe.Outputs != "" && probe.Format == "" {
+		return errors.New("`outputs` is set but `format` is not")
+	}
+	if probe.Format != "" && !util.Contains(toolOutputsFormats, probe.Format) {
+		return fmt.Errorf("unknown outputs format `%s`, must be one of: %s",
+			probe.Format, strings.Join(toolOutputsFormats, ", "))
+	}
+	return nil
+}
+
+// commands returns verb commands, or nil if the verb is not implemented; exact verb match is
+// preferred over the longest glob
+func (probe *implementationProbe) commands(verb string) []string {
+	commands, exist := probe.Verbs[verb]
+	if !exist {
+		patterns := make([]string, 0, len(probe.Verbs))
+		for pattern := range probe.Verbs {
+			patterns = append(patterns, pattern)
+		}
+		sort.Slice(patterns, func(i, j int) bool {
+			if len(patterns[i]) != len(patterns[j]) {
+				return len(patterns[i]) > len(patterns[j])
+			}
+			return patterns[i] < patterns[j]
+		})
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, verb); matched {
+				commands = probe.Verbs[pattern]
+				break
+			}
+		}
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	return commands
+}
+
+// match returns true and the first marker file found, if the probe applies to component
+func (probe *implementationProbe) match(dir, verb string, component *manifest.Manifest) (bool, string, error) {
+	if probe.Requires != "" && !util.Contains(component.Requires, probe.Requires) {
+		return false, "", nil
+	}
+	if probe.Extension == "" && probe.commands(verb) == nil {
+		return false, "", nil
+	}
+	file := ""
+	if len(probe.Markers) > 0 {
+		markers, err := renderProbeTemplates(probe.Markers, map[string]string{"Verb": verb})
+		if err != nil {
+			return false, "", fmt.Errorf("Unable to render %s probe markers: %v", probe.Name, err)
+		}
+		file, err = probeMarkers(dir, markers)
+		if file == "" {
+			return false, "", err
+		}
+	}
+	if probe.Contains != "" {
+		found, err := probe.contains(filepath.Join(dir, file), verb)
+		if !found {
+			return false, "", err
+		}
+	}
+	if probe.RequireBinary {
+		if _, err := exec.LookPath(probe.Binary); err != nil {
+			return false, "", nil
+		}
+	}
+	return true, file, nil
+}
+
+func (probe *implementationProbe) contains(filename, verb string) (bool, error) {
+	rendered, err := renderProbeTemplates([]string{probe.Contains}, map[string]string{"Verb": verb})
+	if err != nil {
+		return false, fmt.Errorf("Unable to render %s probe regexp: %v", probe.Name, err)
+	}
+	re, err := regexp.Compile(rendered[0])
+	if err != nil {
+		return false, fmt.Errorf("Unable to compile %s probe regexp: %v", probe.Name, err)
+	}
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", filename, err)
+	}
+	return re.Match(text), nil
+}
+
+// implementation returns verb implementation and outputs capture, if verb produce outputs
+func (probe *implementationProbe) implementation(dir, verb, file, componentName string) (*exec.Cmd, *toolOutputs, error) {
+	if probe.Extension != "" {
+		path, args, err := ext.ExtensionPath([]string{"component", probe.Extension, verb}, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &exec.Cmd{Path: path, Args: append([]string{path}, args...), Dir: dir, Env: probe.Env}, nil, nil
+	}
+
+	data := map[string]string{"Bin": probe.Binary, "Verb": verb, "File": file, "Name": componentName}
+	commands, err := renderProbeTemplates(probe.commands(verb), data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to render %s `%s` command: %v", probe.Name, verb, err)
+	}
+	var impl *exec.Cmd
+	if probe.Exec {
+		args := strings.Fields(commands[0])
+		if len(args) == 0 {
+			return nil, nil, fmt.Errorf("Empty %s `%s` command", probe.Name, verb)
+		}
+		bin := args[0]
+		if probe.Binary != "" && bin == probe.Binary {
+			bin = probe.binaryPath()
+		}
+		impl = &exec.Cmd{Path: bin, Args: args, Dir: dir, Env: probe.Env}
+	} else {
+		impl = toolCommand(dir, strings.Join(commands, "\n")+"\n", probe.Env)
+	}
+
+	if probe.Format == "" || strings.HasSuffix(verb, "-test") || util.Contains(toolNoOutputsVerbs, verb) {
+		return impl, nil, nil
+	}
+	outputs := &toolOutputs{format: probe.Format}
+	if probe.Outputs != "" {
+		commands, err := renderProbeTemplates([]string{probe.Outputs}, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Unable to render %s outputs command: %v", probe.Name, err)
+		}
+		outputs.command = toolCommand(dir, commands[0]+"\n", probe.Env)
+	}
+	return impl, outputs, nil
+}
+
+func (probe *implementationProbe) binaryPath() string {
+	bin, err := exec.LookPath(probe.Binary)
+	if err == nil {
+		return bin
+	}
+	if probe.Fallback != "" {
+		util.WarnOnce("Unable to lookup `%s` in PATH: %v; trying `%s`", probe.Binary, err, probe.Fallback)
+		return probe.Fallback
+	}
+	return probe.Binary
+}
+
+var probeTemplateFuncs = template.FuncMap{"quoteMeta": regexp.QuoteMeta}
+
+func renderProbeTemplates(templates []string, data map[string]string) ([]string, error) {
+	rendered := make([]string, 0, len(templates))
+	for _, text := range templates {
+		tmpl, err := template.New("probe").Funcs(probeTemplateFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, data); err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, out.String())
+	}
+	return rendered, nil
+}
+
+// toolCommand returns shell command with tool environment to be merged with OS and component environment
+func toolCommand(dir, script string, env []string) *exec.Cmd {
+	return &exec.Cmd{Path: "/bin/sh", Args: []string{"sh", "-ec", script}, Dir: dir, Env: env}
+}
+
+// probeMarkers returns the first regular file matching globs
+func probeMarkers(dir string, markers []string) (string, error) {
+	var lastErr error
+	for _, glob := range markers {
+		matches, err := filepath.Glob(filepath.Join(dir, glob))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if info.Mode().IsRegular() {
+				return filepath.Rel(dir, match)
+			}
+		}
+	}
+	return "", lastErr
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build !windows
+
+package lifecycle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/manifest"
+)
+
+func probesNames(probes []implementationProbe) []string {
+	names := make([]string, 0, len(probes))
+	for _, probe := range probes {
+		names = append(names, probe.Name)
+	}
+	return names
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for filename, content := range files {
+		path := filepath.Join(dir, filename)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0755))
+	}
+}
+
+func TestLoadProbes(t *testing.T) {
+	probes, err := loadProbes(nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"make", "script", "helm", "kustomize", "skaffold",
+		"opentofu", "pulumi", "cloudformation", "helmfile", "ansible", "terraform", "arm"}, probesNames(probes))
+
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home.yaml")
+	stack := filepath.Join(dir, "stack.yaml")
+	writeFiles(t, dir, map[string]string{
+		"home.yaml": `
+probes:
+- name: make
+  disabled: true
+- name: bicep
+  priority: 750
+  markers: ['*.bicep']
+  binary: az
+  verbs:
+    deploy: ['{{.Bin}} deployment group create --template-file {{.File}}']
+`,
+		"stack.yaml": `
+probes:
+- name: script
+  priority: 2000
+- name: pulumi
+  verbs:
+    deploy: ['{{.Bin}} up --yes --stack dev']
+`,
+	})
+	probes, err = loadProbes([]string{home, filepath.Join(dir, "missing.yaml"), stack})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"helm", "kustomize", "skaffold",
+		"opentofu", "pulumi", "bicep", "cloudformation", "helmfile", "ansible", "terraform", "arm", "script"},
+		probesNames(probes))
+	pulumi := &probes[4]
+	assert.Equal(t, []string{"{{.Bin}} up --yes --stack dev"}, pulumi.commands("deploy"))
+	assert.NotNil(t, pulumi.commands("undeploy"))
+	assert.Equal(t, "json", pulumi.Format)
+
+	builtin, _ := loadProbes(nil)
+	assert.NotEqual(t, pulumi.commands("deploy"), builtin[6].commands("deploy"), "built-in probes must not be modified")
+
+	for _, invalid := range []string{
+		"probes:\n- priority: 1\n",
+		"probes:\n- name: make\n  marker: [Makefile]\n",
+		"probes:\n- name: new\n  markers: [x]\n",
+		"probes:\n- name: new\n  markers: [x]\n  exec: true\n  verbs:\n    '*': [a, b]\n",
+		"probes:\n- name: new\n  markers: [x]\n  verbs:\n    '*': [a]\n  format: xml\n",
+	} {
+		writeFiles(t, dir, map[string]string{"invalid.yaml": invalid})
+		_, err = loadProbes([]string{filepath.Join(dir, "invalid.yaml")})
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestProbeCommands(t *testing.T) {
+	probe := &implementationProbe{Verbs: map[string][]string{
+		"deploy": {"up"},
+		"*-test": {},
+		"*":      {"run"},
+	}}
+	assert.Equal(t, []string{"up"}, probe.commands("deploy"))
+	assert.Equal(t, []string{"run"}, probe.commands("undeploy"))
+	assert.Nil(t, probe.commands("deploy-test"))
+}
+
+func TestFindImplementation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	stackDir := t.TempDir()
+	component := &manifest.Manifest{Meta: manifest.Metadata{Name: "vpc"}}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Makefile":            "build:\n\ttrue\nundeploy:\n\ttrue\n",
+		"bin/deploy":          "#!/bin/sh\n",
+		"skaffold.yaml":       "",
+		"main.tf":             "",
+		"network.cfn.yaml":    "",
+		"_deploy.sh.disabled": "",
+	})
+	impl, outputs, err := findImplementation2(dir, "undeploy", component, stackDir)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"make", "undeploy"}, impl.Args)
+		assert.Equal(t, "/usr/bin/make", impl.Path)
+		assert.Nil(t, outputs)
+	}
+	impl, _, err = findImplementation2(dir, "deploy", component, stackDir)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"bin/deploy"}, impl.Args)
+		assert.Equal(t, "bin/deploy", impl.Path)
+	}
+	impl, _, err = findImplementation2(dir, "deploy-test", component, stackDir)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"skaffold", "deploy-test"}, impl.Args)
+	}
+
+	assert.Nil(t, os.Remove(filepath.Join(dir, "skaffold.yaml")))
+	impl, outputs, err = findImplementation2(dir, "status", component, stackDir)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"sh", "-ec",
+			"aws cloudformation status --stack-name \"${CFN_STACK_NAME:-vpc}\"\n"}, impl.Args)
+		assert.Equal(t, "cloudformation", outputs.format)
+	}
+
+	writeFiles(t, bin, map[string]string{"tofu": "#!/bin/sh\n"})
+	impl, _, err = findImplementation2(dir, "status", component, stackDir)
+	if assert.Nil(t, err) {
+		assert.Contains(t, impl.Args[2], "aws cloudformation status", "*.tf is not claimed by OpenTofu by default")
+	}
+	tofuStackDir := t.TempDir()
+	writeFiles(t, tofuStackDir, map[string]string{"probes.yaml": `
+probes:
+- name: opentofu
+  markers: ['*.tofu', '*.tf', '*.tf.*']
+`})
+	impl, outputs, err = findImplementation2(dir, "status", component, tofuStackDir)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"sh", "-ec", "tofu init -input=false\ntofu status\n"}, impl.Args)
+		assert.Equal(t, []string{"TF_IN_AUTOMATION=1"}, impl.Env)
+		if assert.NotNil(t, outputs) {
+			assert.Equal(t, "terraform", outputs.format)
+			assert.Equal(t, []string{"sh", "-ec", "tofu output -json\n"}, outputs.command.Args)
+		}
+	}
+
+	_, _, err = findImplementation2(dir, "backup-test", component, stackDir)
+	assert.NotNil(t, err, "tools do not implement dry-run of custom verbs")
+
+	// stack probes are loaded once per stack directory
+	otherStackDir := t.TempDir()
+	writeFiles(t, otherStackDir, map[string]string{"probes.yaml": `
+probes:
+- name: opentofu
+  disabled: true
+- name: cloudformation
+  priority: 10
+`})
+	impl, _, err = findImplementation2(dir, "undeploy", component, otherStackDir)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"sh", "-ec",
+			"aws cloudformation delete-stack --stack-name \"${CFN_STACK_NAME:-vpc}\"\n" +
+				"aws cloudformation wait stack-delete-complete --stack-name \"${CFN_STACK_NAME:-vpc}\"\n"}, impl.Args)
+	}
+	impl, _, err = findImplementation2(dir, "undeploy", component, stackDir)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "make", impl.Args[0])
+	}
+}
@@ -62,6 +62,7 @@ func mergeProvides(provides map[string][]string, componentName string, component
 					log.Print("Outputs:")
 					parameters.PrintCapturedOutputs(componentOutputs)
 					if !config.Force {
+						util.Done()
 						os.Exit(1)
 					}
 				}
@@ -16,16 +16,20 @@ import (
 	"time"
 
 	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/kube"
 	"github.com/epam/hubctl/cmd/hub/manifest"
 	"github.com/epam/hubctl/cmd/hub/parameters"
 	"github.com/epam/hubctl/cmd/hub/util"
 )
 
-func waitForReadyConditions(ctx context.Context, conditions []manifest.ReadyCondition,
-	parameters parameters.LockedParameters, outputs parameters.CapturedOutputs, componentDepends []string) error {
+// waitForReadyConditions waits for each condition in turn; `kubernetesProvider` is the component
+// providing `kubernetes` to find Kubeconfig context for `kubernetes:` conditions, if any
+func waitForReadyConditions(ctx context.Context, componentName string, conditions []manifest.ReadyCondition,
+	parameters parameters.LockedParameters, outputs parameters.CapturedOutputs, componentDepends []string,
+	kubernetesProvider string) error {
 
 	for _, condition := range conditions {
-		err := waitForReadyCondition(ctx, condition, parameters, outputs, componentDepends)
+		err := waitForReadyCondition(ctx, componentName, condition, parameters, outputs, componentDepends, kubernetesProvider)
 		if err != nil {
 			return err
 		}
@@ -41,13 +45,19 @@ func expandReadyConditionParameter(what string, value string, componentDepends [
 
 const defaultReadyConditionWaitSeconds = 1200
 
-func waitForReadyCondition(ctx context.Context, condition manifest.ReadyCondition,
-	params parameters.LockedParameters, outputs parameters.CapturedOutputs, componentDepends []string) error {
+func hasReadyCondition(condition manifest.ReadyCondition) bool {
+	return condition.DNS != "" || condition.URL != "" || condition.TCP != "" || condition.HTTP != nil ||
+		condition.Command != "" || condition.Kubernetes != nil
+}
+
+func waitForReadyCondition(ctx context.Context, componentName string, condition manifest.ReadyCondition,
+	params parameters.LockedParameters, outputs parameters.CapturedOutputs, componentDepends []string,
+	kubernetesProvider string) error {
 
 	if condition.PauseSeconds > 0 {
 		why := ""
 		if config.Verbose {
-			if condition.DNS != "" || condition.URL != "" {
+			if hasReadyCondition(condition) {
 				why = " before checking for ready condition(s)"
 			}
 			log.Printf("Sleeping %d seconds%s", condition.PauseSeconds, why)
@@ -59,7 +69,7 @@ func waitForReadyCondition(ctx context.Context, condition manifest.ReadyConditio
 		}
 	}
 
-	if condition.DNS == "" && condition.URL == "" {
+	if !hasReadyCondition(condition) {
 		return nil
 	}
 
@@ -70,14 +80,76 @@ func waitForReadyCondition(ctx context.Context, condition manifest.ReadyConditio
 	kv := parameters.ParametersAndOutputsKV(params, outputs, nil)
 	if condition.DNS != "" {
 		fqdn := expandReadyConditionParameter("DNS", condition.DNS, componentDepends, kv)
+		emitReadyCondition(componentName, "dns:"+fqdn, "waiting", nil)
 		err := waitForFqdn(ctx, maybeStripPort(fqdn), wait)
+		emitReadyCondition(componentName, "dns:"+fqdn, "ready", err)
 		if err != nil {
 			return err
 		}
 	}
 	if condition.URL != "" {
 		url := expandReadyConditionParameter("URL", condition.URL, componentDepends, kv)
+		emitReadyCondition(componentName, "url:"+url, "waiting", nil)
 		err := waitForUrl(ctx, url, wait)
+		emitReadyCondition(componentName, "url:"+url, "ready", err)
+		if err != nil {
+			return err
+		}
+	}
+	if condition.TCP != "" {
+		address := expandReadyConditionParameter("TCP", condition.TCP, componentDepends, kv)
+		emitReadyCondition(componentName, "tcp:"+address, "waiting", nil)
+		err := waitForTcp(ctx, address, wait)
+		emitReadyCondition(componentName, "tcp:"+address, "ready", err)
+		if err != nil {
+			return err
+		}
+	}
+	if condition.HTTP != nil {
+		check := *condition.HTTP
+		check.URL = expandReadyConditionParameter("HTTP.URL", check.URL, componentDepends, kv)
+		if len(check.Headers) > 0 {
+			check.Headers = make(map[string]string, len(condition.HTTP.Headers))
+			for name, value := range condition.HTTP.Headers {
+				check.Headers[name] = expandReadyConditionParameter("HTTP.Headers."+name, value, componentDepends, kv)
+			}
+		}
+		emitReadyCondition(componentName, "http:"+check.URL, "waiting", nil)
+		err := waitForHttp(ctx, &check, wait)
+		emitReadyCondition(componentName, "http:"+check.URL, "ready", err)
+		if err != nil {
+			return err
+		}
+	}
+	if condition.Command != "" {
+		command := expandReadyConditionParameter("Command", condition.Command, componentDepends, kv)
+		emitReadyCondition(componentName, "command:"+command, "waiting", nil)
+		err := waitForCommand(ctx, command, wait)
+		emitReadyCondition(componentName, "command:"+command, "ready", err)
+		if err != nil {
+			return err
+		}
+	}
+	if condition.Kubernetes != nil {
+		check := *condition.Kubernetes
+		check.Name = expandReadyConditionParameter("Kubernetes.Name", check.Name, componentDepends, kv)
+		check.Namespace = expandReadyConditionParameter("Kubernetes.Namespace", check.Namespace, componentDepends, kv)
+		check.Context = expandReadyConditionParameter("Kubernetes.Context", check.Context, componentDepends, kv)
+		// reuse Kubeconfig setup for `kubernetes` requirement, setting it up here would race with
+		// other components executed in parallel
+		var err error
+		if check.Context == "" && kubernetesProvider != "" {
+			check.Context, err = kube.KubeconfigContext(params, kubernetesProvider, outputs)
+		} else if check.Context != "" {
+			err = kube.CheckKubeconfigContext(check.Context)
+		}
+		if err != nil {
+			return err
+		}
+		what := fmt.Sprintf("kubernetes:%s/%s", check.Kind, check.Name)
+		emitReadyCondition(componentName, what, "waiting", nil)
+		err = waitForKubernetes(ctx, &check, wait)
+		emitReadyCondition(componentName, what, "ready", err)
 		if err != nil {
 			return err
 		}
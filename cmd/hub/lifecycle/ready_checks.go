@@ -0,0 +1,313 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+const readyConditionHttpBodyLimit = 1024 * 1024
+
+var readyConditionInterval = 10 * time.Second
+
+// pollReadyCondition calls probe until it succeeds, ctx is canceled, or waitSeconds elapsed
+func pollReadyCondition(ctx context.Context, what string, waitSeconds int, probe func() error) error {
+	deadline := time.Now().Add(time.Duration(waitSeconds) * time.Second)
+	lastMsg := ""
+	for {
+		err := probe()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || util.ContextCanceled(err) {
+			return context.Canceled
+		}
+		if config.Verbose {
+			msg := err.Error()
+			if config.Debug || lastMsg != msg {
+				log.Print(msg)
+				lastMsg = msg
+			}
+		}
+		if time.Now().Add(readyConditionInterval).After(deadline) {
+			return fmt.Errorf("Timeout waiting for %s: %v", what, err)
+		}
+		select {
+		case <-ctx.Done():
+			return context.Canceled
+		case <-time.After(readyConditionInterval):
+		}
+	}
+}
+
+func waitForTcp(ctx context.Context, address string, waitSeconds int) error {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return fmt.Errorf("lifecycle.readyCondition.TCP must be host:port, expanded to `%s`: %v", address, err)
+	}
+	if config.Verbose {
+		log.Printf("Waiting for `%s` to accept TCP connection", address)
+	}
+	dialer := &net.Dialer{Timeout: readyConditionInterval}
+	return pollReadyCondition(ctx, fmt.Sprintf("`%s` to accept TCP connection", address), waitSeconds,
+		func() error {
+			conn, err := dialer.DialContext(ctx, "tcp", address)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		})
+}
+
+func waitForHttp(ctx context.Context, check *manifest.HttpReadyCondition, waitSeconds int) error {
+	url := check.URL
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("Only HTTP and HTTPS is supported in lifecycle.readyCondition.HTTP.URL, expanded to `%s`", url)
+	}
+	var bodyRegexp *regexp.Regexp
+	if check.Body != "" {
+		var err error
+		bodyRegexp, err = regexp.Compile(check.Body)
+		if err != nil {
+			return fmt.Errorf("Unable to compile lifecycle.readyCondition.HTTP.Body regexp `%s`: %v", check.Body, err)
+		}
+	}
+	method := check.Method
+	if method == "" {
+		method = "GET"
+	}
+	if config.Verbose {
+		log.Printf("Waiting for `%s %s` to respond as expected", method, url)
+	}
+	client := util.RobustHttpClient(readyConditionInterval, true)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return pollReadyCondition(ctx, fmt.Sprintf("`%s` to respond as expected", url), waitSeconds,
+		func() error {
+			req, err := http.NewRequestWithContext(ctx, method, url, nil)
+			if err != nil {
+				return err
+			}
+			for name, value := range check.Headers {
+				if strings.EqualFold(name, "Host") {
+					req.Host = value
+				} else {
+					req.Header.Set(name, value)
+				}
+			}
+			response, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			body, err := io.ReadAll(io.LimitReader(response.Body, readyConditionHttpBodyLimit))
+			response.Body.Close()
+			if err != nil {
+				return err
+			}
+			return checkHttpResponse(check, bodyRegexp, response.StatusCode, body)
+		})
+}
+
+func checkHttpResponse(check *manifest.HttpReadyCondition, bodyRegexp *regexp.Regexp, status int, body []byte) error {
+	if !expectedHttpStatus(check.Status, status) {
+		expected := "2xx"
+		if len(check.Status) > 0 {
+			expected = fmt.Sprintf("%v", check.Status)
+		}
+		return fmt.Errorf("`%s` responded with status %d, expected %s", check.URL, status, expected)
+	}
+	if bodyRegexp != nil && !bodyRegexp.Match(body) {
+		return fmt.Errorf("`%s` response body does not match `%s`", check.URL, check.Body)
+	}
+	if check.JSONPath != "" {
+		var document interface{}
+		if err := json.Unmarshal(body, &document); err != nil {
+			return fmt.Errorf("`%s` response is not JSON: %v", check.URL, err)
+		}
+		value, err := jsonPathValue(document, check.JSONPath)
+		if err != nil {
+			return fmt.Errorf("`%s` response: %v", check.URL, err)
+		}
+		if check.JSONValue != "" {
+			if str := jsonValueString(value); str != check.JSONValue {
+				return fmt.Errorf("`%s` response `%s` is `%s`, expected `%s`", check.URL, check.JSONPath, str, check.JSONValue)
+			}
+		}
+	}
+	return nil
+}
+
+func expectedHttpStatus(expected []int, status int) bool {
+	if len(expected) == 0 {
+		return status >= 200 && status <= 299
+	}
+	for _, code := range expected {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonPathValue evaluates simple JSONPath: $.key.list[0]['other key']
+func jsonPathValue(document interface{}, path string) (interface{}, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	value := document
+	for rest != "" {
+		key := ""
+		index := -1
+		if rest[0] == '[' {
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath `%s` has unterminated `[`", path)
+			}
+			token := rest[1:end]
+			rest = rest[end+1:]
+			if len(token) >= 2 && (token[0] == '\'' || token[0] == '"') && token[len(token)-1] == token[0] {
+				key = token[1 : len(token)-1]
+			} else {
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 {
+					return nil, fmt.Errorf("JSONPath `%s` has invalid index `%s`", path, token)
+				}
+				index = i
+			}
+		} else {
+			rest = strings.TrimPrefix(rest, ".")
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key = rest[:end]
+			rest = rest[end:]
+			if key == "" {
+				return nil, fmt.Errorf("JSONPath `%s` has empty key", path)
+			}
+		}
+		if index >= 0 {
+			list, ok := value.([]interface{})
+			if !ok || index >= len(list) {
+				return nil, fmt.Errorf("JSONPath `%s`: no element %d", path, index)
+			}
+			value = list[index]
+		} else {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("JSONPath `%s`: no key `%s`", path, key)
+			}
+			v, exist := object[key]
+			if !exist {
+				return nil, fmt.Errorf("JSONPath `%s`: no key `%s`", path, key)
+			}
+			value = v
+		}
+	}
+	return value, nil
+}
+
+func jsonValueString(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(bytes)
+}
+
+func waitForCommand(ctx context.Context, command string, waitSeconds int) error {
+	if config.Verbose {
+		log.Printf("Waiting for `%s` to succeed", command)
+	}
+	return pollReadyCondition(ctx, fmt.Sprintf("`%s` to succeed", command), waitSeconds,
+		func() error {
+			out, err := exec.CommandContext(ctx, "/bin/sh", "-c", command).CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("`%s` failed: %v%s", command, err, lastOutputLine(out))
+			}
+			if config.Debug && len(out) > 0 {
+				log.Printf("`%s` output:\n%s", command, out)
+			}
+			return nil
+		})
+}
+
+func lastOutputLine(out []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if last := lines[len(lines)-1]; last != "" {
+		return ": " + last
+	}
+	return ""
+}
+
+var kubernetesRolloutKinds = []string{"deployment", "statefulset", "daemonset"}
+
+func kubernetesWaitArgs(check *manifest.KubernetesReadyCondition, timeout time.Duration) ([]string, error) {
+	if check.Kind == "" || check.Name == "" {
+		return nil, errors.New("lifecycle.readyCondition.Kubernetes must specify kind and name")
+	}
+	resource := fmt.Sprintf("%s/%s", check.Kind, check.Name)
+	var args []string
+	if check.Condition != "" {
+		args = []string{"wait", "--for=condition=" + check.Condition, resource}
+	} else if util.Contains(kubernetesRolloutKinds, strings.ToLower(check.Kind)) {
+		args = []string{"rollout", "status", resource}
+	} else {
+		return nil, fmt.Errorf("lifecycle.readyCondition.Kubernetes must specify condition for `%s`", check.Kind)
+	}
+	if check.Namespace != "" {
+		args = append(args, "--namespace", check.Namespace)
+	}
+	if check.Context != "" {
+		args = append(args, "--context", check.Context)
+	}
+	seconds := int(timeout.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	return append(args, fmt.Sprintf("--timeout=%ds", seconds)), nil
+}
+
+func waitForKubernetes(ctx context.Context, check *manifest.KubernetesReadyCondition, waitSeconds int) error {
+	if _, err := kubernetesWaitArgs(check, 0); err != nil {
+		return err
+	}
+	what := fmt.Sprintf("%s/%s", check.Kind, check.Name)
+	if config.Verbose {
+		log.Printf("Waiting for Kubernetes `%s` to become ready", what)
+	}
+	deadline := time.Now().Add(time.Duration(waitSeconds) * time.Second)
+	return pollReadyCondition(ctx, fmt.Sprintf("Kubernetes `%s` to become ready", what), waitSeconds,
+		func() error {
+			args, _ := kubernetesWaitArgs(check, time.Until(deadline))
+			if config.Debug {
+				log.Printf("Running kubectl %s", strings.Join(args, " "))
+			}
+			out, err := exec.CommandContext(ctx, "kubectl", args...).CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("kubectl %s failed: %v%s", args[0], err, lastOutputLine(out))
+			}
+			return nil
+		})
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build !windows
+
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/epam/hubctl/cmd/hub/parameters"
+)
+
+func TestJsonPathValue(t *testing.T) {
+	var document interface{}
+	json.Unmarshal([]byte(`{"status": {"phase": "Running", "replicas": 3, "conditions": [{"type": "Ready"}]}, "odd key": true}`), &document)
+
+	value, err := jsonPathValue(document, "$.status.phase")
+	assert.Nil(t, err)
+	assert.Equal(t, "Running", jsonValueString(value))
+	value, err = jsonPathValue(document, "status.replicas")
+	assert.Nil(t, err)
+	assert.Equal(t, "3", jsonValueString(value))
+	value, err = jsonPathValue(document, "$.status.conditions[0].type")
+	assert.Nil(t, err)
+	assert.Equal(t, "Ready", jsonValueString(value))
+	value, err = jsonPathValue(document, "$['odd key']")
+	assert.Nil(t, err)
+	assert.Equal(t, "true", jsonValueString(value))
+
+	_, err = jsonPathValue(document, "$.status.conditions[1]")
+	assert.NotNil(t, err)
+	_, err = jsonPathValue(document, "$.spec")
+	assert.NotNil(t, err)
+}
+
+func TestWaitForHttp(t *testing.T) {
+	readyConditionInterval = 10 * time.Millisecond
+	defer func() { readyConditionInterval = 10 * time.Second }()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status": "UP"}`))
+	}))
+	defer server.Close()
+
+	check := &manifest.HttpReadyCondition{
+		URL:       server.URL,
+		Headers:   map[string]string{"Authorization": "Bearer token"},
+		Status:    []int{200},
+		Body:      `"UP"`,
+		JSONPath:  "$.status",
+		JSONValue: "UP",
+	}
+	assert.Nil(t, waitForHttp(context.Background(), check, 5))
+	assert.Equal(t, 3, requests)
+
+	check.JSONValue = "DOWN"
+	assert.NotNil(t, waitForHttp(context.Background(), check, 0))
+}
+
+func TestWaitForTcpAndCommand(t *testing.T) {
+	readyConditionInterval = 10 * time.Millisecond
+	defer func() { readyConditionInterval = 10 * time.Second }()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	assert.NotNil(t, waitForTcp(context.Background(), address, 0))
+	assert.NotNil(t, waitForTcp(context.Background(), "no-port", 1))
+
+	listener, err = net.Listen("tcp", address)
+	if assert.Nil(t, err) {
+		defer listener.Close()
+		assert.Nil(t, waitForTcp(context.Background(), address, 1))
+	}
+
+	marker := filepath.Join(t.TempDir(), "ready")
+	assert.Nil(t, waitForCommand(context.Background(), "test -f "+marker+" || { touch "+marker+"; exit 1; }", 5))
+	assert.NotNil(t, waitForCommand(context.Background(), "exit 1", 0))
+}
+
+func TestKubernetesWaitArgs(t *testing.T) {
+	args, err := kubernetesWaitArgs(&manifest.KubernetesReadyCondition{Kind: "Deployment", Name: "app", Namespace: "ns"}, 90*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"rollout", "status", "Deployment/app", "--namespace", "ns", "--timeout=90s"}, args)
+
+	args, err = kubernetesWaitArgs(&manifest.KubernetesReadyCondition{Kind: "Certificate", Name: "tls", Condition: "Ready", Context: "cluster"}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"wait", "--for=condition=Ready", "Certificate/tls", "--context", "cluster", "--timeout=1s"}, args)
+
+	_, err = kubernetesWaitArgs(&manifest.KubernetesReadyCondition{Kind: "Certificate", Name: "tls"}, 0)
+	assert.NotNil(t, err)
+}
+
+func TestKubernetesReadyConditionReusesKubeconfig(t *testing.T) {
+	// fake kubectl knows `dev.example.com` context only and records invocations
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	kubectl := `#!/bin/sh
+echo "$@" >> ` + calls + `
+case "$*" in
+"config get-contexts dev.example.com") exit 0;;
+"config get-contexts "*) echo "error: context $3 not found"; exit 1;;
+"config "*) exit 3;;
+esac
+exit 0
+`
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte(kubectl), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	outputs := parameters.CapturedOutputs{
+		"k8s:dns.domain": {Component: "k8s", Name: "dns.domain", Value: "dev.example.com"},
+	}
+	condition := manifest.ReadyCondition{WaitSeconds: 1,
+		Kubernetes: &manifest.KubernetesReadyCondition{Kind: "Deployment", Name: "app"}}
+	err := waitForReadyCondition(context.Background(), "app", condition, parameters.LockedParameters{}, outputs, nil, "k8s")
+	assert.Nil(t, err)
+	invoked, _ := os.ReadFile(calls)
+	assert.Equal(t, []string{
+		"config get-contexts dev.example.com",
+		"rollout status Deployment/app --context dev.example.com --timeout=1s",
+	}, strings.Split(strings.TrimSpace(string(invoked)), "\n"), "Kubeconfig must not be setup again")
+
+	// missing context is an error, not an exit
+	condition.Kubernetes.Context = "prod.example.com"
+	err = waitForReadyCondition(context.Background(), "app", condition, parameters.LockedParameters{}, outputs, nil, "k8s")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "prod.example.com")
+	}
+}
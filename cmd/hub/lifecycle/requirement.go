@@ -132,6 +132,14 @@ func guessEnabledClouds() []string {
 	return clouds
 }
 
+// requirementProvider returns the component that provides the requirement, the last one if many
+func requirementProvider(provided map[string][]string, requirement string) string {
+	if by := provided[requirement]; len(by) > 0 {
+		return by[len(by)-1]
+	}
+	return ""
+}
+
 func setupRequirement(requirement string, provider string,
 	parameters parameters.LockedParameters, outputs parameters.CapturedOutputs) {
 
@@ -145,7 +153,7 @@ func setupRequirement(requirement string, provider string,
 		wellKnown, err := checkRequire(requirement)
 		if wellKnown {
 			if err != nil {
-				log.Fatalf("`%s` requirement cannot be satisfied: %v", requirement, err)
+				util.Fatalf("`%s` requirement cannot be satisfied: %v", requirement, err)
 			}
 		} else {
 			if config.Verbose {
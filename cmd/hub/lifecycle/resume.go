@@ -0,0 +1,151 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/state"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+var resumableOperationStatuses = []string{"error", "in-progress", "interrupted"}
+
+// operationOptions returns request options to record in the operation log, so that
+// the operation could be resumed with the same component set and options
+func operationOptions(request *Request, resumes string) map[string]interface{} {
+	options := map[string]interface{}{"args": os.Args}
+	if len(request.Components) > 0 {
+		options["components"] = request.Components
+	}
+	if request.OffsetComponent != "" {
+		options["offset"] = request.OffsetComponent
+	}
+	if request.LimitComponent != "" {
+		options["limit"] = request.LimitComponent
+	}
+	if request.Parallelism > 1 {
+		options["parallelism"] = request.Parallelism
+	}
+	if request.TimeoutSeconds > 0 {
+		options["timeout"] = request.TimeoutSeconds
+	}
+	if request.ChangedOnly {
+		options["changedOnly"] = true
+	}
+	if request.RollbackOnFailure {
+		options["rollbackOnFailure"] = true
+	}
+	if resumes != "" {
+		options["resumes"] = resumes
+	}
+	return options
+}
+
+func restoreOperationOptions(request *Request, options map[string]interface{}) {
+	request.Components = optionStrings(options["components"])
+	request.OffsetComponent = util.String(options["offset"])
+	request.LimitComponent = util.String(options["limit"])
+	if parallelism := optionInt(options["parallelism"]); parallelism > 1 {
+		request.Parallelism = parallelism
+	}
+	if timeout := optionInt(options["timeout"]); timeout > 0 {
+		request.TimeoutSeconds = timeout
+	}
+	request.ChangedOnly = request.ChangedOnly || util.String(options["changedOnly"]) == "true"
+	request.RollbackOnFailure = request.RollbackOnFailure || util.String(options["rollbackOnFailure"]) == "true"
+}
+
+func optionStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		strs := make([]string, 0, len(v))
+		for _, s := range v {
+			strs = append(strs, util.String(s))
+		}
+		return strs
+	}
+	return nil
+}
+
+func optionInt(value interface{}) int {
+	if i, ok := value.(int); ok {
+		return i
+	}
+	i, _ := strconv.Atoi(util.String(value))
+	return i
+}
+
+// findResumableOperation returns the last `verb` operation if it did not succeed
+func findResumableOperation(stateManifest *state.StateManifest, verb string) (*state.LifecycleOperation, error) {
+	ops := stateManifest.Operations
+	for i := len(ops) - 1; i >= 0; i-- {
+		op := &ops[i]
+		if op.Operation != verb {
+			continue
+		}
+		if !util.Contains(resumableOperationStatuses, op.Status) {
+			return nil, fmt.Errorf("last %s operation %s status is `%s` - nothing to resume", verb, op.Id, op.Status)
+		}
+		return op, nil
+	}
+	return nil, fmt.Errorf("no %s operation found in state", verb)
+}
+
+// resumeComponents returns components selected by the operation options that are yet to
+// complete - failed, interrupted, or not started, in `order`
+func resumeComponents(request *Request, op *state.LifecycleOperation, order []string) []string {
+	succeeded := make([]string, 0, len(op.Phases))
+	for _, phase := range op.Phases {
+		if phase.Status == "success" {
+			succeeded = append(succeeded, phase.Phase)
+		}
+	}
+	offset := util.Index(order, request.OffsetComponent)
+	limit := util.Index(order, request.LimitComponent)
+	components := make([]string, 0, len(order))
+	for i, name := range order {
+		if (len(request.Components) > 0 && !util.Contains(request.Components, name)) ||
+			(offset >= 0 && i < offset) ||
+			(limit >= 0 && i > limit) ||
+			util.Contains(succeeded, name) {
+			continue
+		}
+		components = append(components, name)
+	}
+	return components
+}
+
+// resumeOperation sets request to continue the last failed operation and returns the
+// operation id
+func resumeOperation(request *Request, stateManifest *state.StateManifest, order []string) string {
+	op, err := findResumableOperation(stateManifest, request.Verb)
+	if err != nil {
+		util.Fatalf("Unable to resume %s: %v", request.Verb, err)
+	}
+	restoreOperationOptions(request, op.Options)
+	if request.Verb == "undeploy" {
+		order = util.Reverse(order)
+	}
+	components := resumeComponents(request, op, order)
+	if len(components) == 0 {
+		util.Fatalf("Unable to resume %s: all components of operation %s succeeded", request.Verb, op.Id)
+	}
+	if config.Verbose {
+		log.Printf("Resuming %s operation %s (%s) with %v", request.Verb, op.Id, op.Status, components)
+	}
+	request.Components = components
+	request.OffsetComponent = ""
+	request.LimitComponent = ""
+	return op.Id
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"testing"
+
+	"github.com/epam/hubctl/cmd/hub/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindResumableOperation(t *testing.T) {
+	stateManifest := &state.StateManifest{
+		Operations: []state.LifecycleOperation{
+			{Id: "1", Operation: "deploy", Status: "error"},
+			{Id: "2", Operation: "deploy", Status: "interrupted"},
+			{Id: "3", Operation: "undeploy", Status: "success"},
+		},
+	}
+	op, err := findResumableOperation(stateManifest, "deploy")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", op.Id)
+
+	_, err = findResumableOperation(stateManifest, "undeploy")
+	assert.Error(t, err, "Succeeded operation must not be resumed")
+
+	_, err = findResumableOperation(stateManifest, "backup")
+	assert.Error(t, err)
+}
+
+func TestResumeComponents(t *testing.T) {
+	order := []string{"a", "b", "c", "d", "e"}
+	op := &state.LifecycleOperation{
+		Phases: []state.LifecyclePhase{
+			{Phase: "b", Status: "success"},
+			{Phase: "c", Status: "error"},
+			{Phase: "d", Status: "success"},
+		},
+	}
+
+	request := &Request{}
+	restoreOperationOptions(request, map[string]interface{}{"offset": "b", "limit": "d", "parallelism": 3})
+	assert.Equal(t, []string{"c"}, resumeComponents(request, op, order))
+	assert.Equal(t, 3, request.Parallelism)
+
+	request = &Request{}
+	restoreOperationOptions(request, map[string]interface{}{"components": []interface{}{"a", "b", "e"}})
+	assert.Equal(t, []string{"a", "e"}, resumeComponents(request, op, order))
+
+	request = &Request{}
+	restoreOperationOptions(request, operationOptions(&Request{LimitComponent: "c", ChangedOnly: true}, ""))
+	assert.Equal(t, []string{"a", "c"}, resumeComponents(request, op, order))
+	assert.True(t, request.ChangedOnly)
+}
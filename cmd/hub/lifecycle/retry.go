@@ -0,0 +1,86 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/manifest"
+)
+
+const (
+	defaultRetryInitialBackoffSeconds = 10
+	defaultRetryMaxBackoffSeconds     = 300
+)
+
+// retryAfter is replaced in tests to skip the backoff
+var retryAfter = time.After
+
+func retryAttempts(policy *manifest.RetryPolicy) int {
+	if policy == nil || policy.Attempts < 1 {
+		return 1
+	}
+	return policy.Attempts
+}
+
+// retryBackoff returns delay before attempt `attempt` (starting from 2), doubling initial
+// backoff on each attempt up to maximum
+func retryBackoff(policy *manifest.RetryPolicy, attempt int) time.Duration {
+	initial := policy.InitialBackoffSeconds
+	if initial <= 0 {
+		initial = defaultRetryInitialBackoffSeconds
+	}
+	max := policy.MaxBackoffSeconds
+	if max <= 0 {
+		max = defaultRetryMaxBackoffSeconds
+	}
+	if max < initial {
+		max = initial
+	}
+	backoff := initial
+	for i := 2; i < attempt && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+	return time.Duration(backoff) * time.Second
+}
+
+// withRetry executes routine under component retry policy until it succeeds, the error
+// is not retryable, the routine timed out, or attempts are exhausted. The errors of each
+// attempt are returned in `attempts` - nil for a successful attempt.
+func withRetry(ctx context.Context, policy *manifest.RetryPolicy, componentName, verb string,
+	routine func() ([]byte, []byte, error)) (stdout []byte, stderr []byte, attempts []error, err error) {
+
+	maxAttempts := retryAttempts(policy)
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			backoff := retryBackoff(policy, attempt)
+			log.Printf("Component `%s` failed to %s: %v; retrying in %v (attempt %d/%d)",
+				componentName, verb, err, backoff, attempt, maxAttempts)
+			select {
+			case <-ctx.Done():
+				return stdout, stderr, attempts, err
+			case <-retryAfter(backoff):
+			}
+		}
+		stdout, stderr, err = routine()
+		attempts = append(attempts, err)
+		if err == nil || attempt == maxAttempts || ctx.Err() != nil || isTimeout(err) || !policy.Retryable(stderr) {
+			break
+		}
+	}
+	return
+}
+
+func attemptPhase(componentName string, attempt int) string {
+	return fmt.Sprintf("attempt-%d-%s", attempt, componentName)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	policy := &manifest.RetryPolicy{Attempts: 5, InitialBackoffSeconds: 2, MaxBackoffSeconds: 5}
+	assert.Equal(t, 2*time.Second, retryBackoff(policy, 2))
+	assert.Equal(t, 4*time.Second, retryBackoff(policy, 3))
+	assert.Equal(t, 5*time.Second, retryBackoff(policy, 4))
+	assert.Equal(t, 5*time.Second, retryBackoff(policy, 5))
+
+	assert.Equal(t, defaultRetryInitialBackoffSeconds*time.Second, retryBackoff(&manifest.RetryPolicy{}, 2))
+}
+
+func TestWithRetry(t *testing.T) {
+	var backoffs []time.Duration
+	retryAfter = func(backoff time.Duration) <-chan time.Time {
+		backoffs = append(backoffs, backoff)
+		fired := make(chan time.Time, 1)
+		fired <- time.Now()
+		return fired
+	}
+	defer func() { retryAfter = time.After }()
+
+	failures := 0
+	routine := func(fail int, stderr string) func() ([]byte, []byte, error) {
+		failures = 0
+		return func() ([]byte, []byte, error) {
+			if failures < fail {
+				failures++
+				return nil, []byte(stderr), errors.New("exit status 1")
+			}
+			return []byte("ok"), nil, nil
+		}
+	}
+	ctx := context.Background()
+
+	_, _, attempts, err := withRetry(ctx, nil, "a", "deploy", routine(1, ""))
+	assert.Error(t, err, "No retry without policy")
+	assert.Len(t, attempts, 1)
+
+	policy := &manifest.RetryPolicy{Attempts: 3}
+	stdout, _, attempts, err := withRetry(ctx, policy, "a", "deploy", routine(0, ""))
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(stdout))
+	assert.Equal(t, []error{nil}, attempts)
+
+	policy = &manifest.RetryPolicy{Attempts: 2, InitialBackoffSeconds: 1, RetryableErrors: []string{"timeout|throttl"}}
+	_, _, attempts, err = withRetry(ctx, policy, "a", "deploy", routine(1, "Error: permission denied"))
+	assert.Error(t, err, "Non-retryable error must not be retried")
+	assert.Len(t, attempts, 1)
+
+	_, _, attempts, err = withRetry(ctx, policy, "a", "deploy", routine(1, "Error: request throttled"))
+	assert.NoError(t, err)
+	assert.Len(t, attempts, 2)
+	assert.Error(t, attempts[0])
+
+	_, _, attempts, err = withRetry(ctx, policy, "a", "deploy", routine(2, "Error: timeout"))
+	assert.Error(t, err, "Attempts must be exhausted")
+	assert.Len(t, attempts, 2)
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, backoffs)
+
+	_, _, attempts, err = withRetry(ctx, policy, "a", "deploy", func() ([]byte, []byte, error) {
+		return nil, []byte("Error: timeout"), &timeoutError{context.DeadlineExceeded}
+	})
+	assert.True(t, isTimeout(err))
+	assert.Len(t, attempts, 1, "Component timeout must not be retried")
+
+	expired, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _, attempts, _ = withRetry(expired, policy, "a", "deploy", routine(2, "Error: timeout"))
+	assert.Len(t, attempts, 1, "No retry after context is done")
+}
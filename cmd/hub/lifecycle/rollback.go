@@ -0,0 +1,169 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/epam/hubctl/cmd/hub/parameters"
+	"github.com/epam/hubctl/cmd/hub/state"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+const rollbackPhasePrefix = "rollback-"
+
+type rollbackRequest struct {
+	// ctx is the stack context, rollback stops on interrupt or stack timeout
+	ctx                 context.Context
+	dryRun              bool
+	stackManifest       *manifest.Manifest
+	componentsManifests []manifest.Manifest
+	stackBaseDir        string
+	componentsBaseDir   string
+	osEnv               []string
+	operationLogId      string
+	provides            map[string][]string
+	// components state as it was before the operation
+	prevComponents map[string]state.StateStep
+	logs           *operationLogs
+}
+
+func snapshotComponents(stateManifest *state.StateManifest) map[string]state.StateStep {
+	components := make(map[string]state.StateStep)
+	if stateManifest != nil {
+		for name, step := range stateManifest.Components {
+			if step != nil {
+				components[name] = *step
+			}
+		}
+	}
+	return components
+}
+
+// rollbackComponents returns components touched by the operation in reverse order of execution
+func rollbackComponents(stateManifest *state.StateManifest, operationLogId string, order []string) []string {
+	for _, op := range stateManifest.Operations {
+		if op.Id != operationLogId {
+			continue
+		}
+		components := make([]string, 0, len(op.Phases))
+		for _, phase := range op.Phases {
+			if util.Contains(order, phase.Phase) && phase.Status != "" {
+				components = append(components, phase.Phase)
+			}
+		}
+		return util.Reverse(components)
+	}
+	return nil
+}
+
+func existedBefore(step state.StateStep, exist bool) bool {
+	return exist && step.Status != "" && step.Status != "undeployed"
+}
+
+// rollbackDeploy undeploys components newly deployed by the current operation and
+// redeploys components that existed before with their previous parameters.
+// Each component rollback is recorded as `rollback-<component>` phase. Rollback of a dry run
+// executes -test verbs.
+func rollbackDeploy(r *rollbackRequest, stateManifest *state.StateManifest, stateUpdater func(interface{}),
+	failure string) *state.StateManifest {
+
+	components := rollbackComponents(stateManifest, r.operationLogId, r.stackManifest.Lifecycle.Order)
+	if len(components) == 0 {
+		log.Print("Nothing to rollback")
+		return stateManifest
+	}
+	log.Printf(util.HighlightColor("Rolling back %s"), strings.Join(components, ", "))
+
+	ctx := withOperationLogs(r.ctx, r.logs)
+	failed := make([]string, 0)
+	for i, componentName := range components {
+		if err := ctx.Err(); err != nil {
+			util.Warn("Rollback stopped: %v", err)
+			failed = append(failed, components[i:]...)
+			break
+		}
+		component := manifest.ComponentRefByName(r.stackManifest.Components, componentName)
+		componentManifest := manifest.ComponentManifestByRef(r.componentsManifests, component)
+		componentDir := manifest.ComponentSourceDirFromRef(component, r.stackBaseDir, r.componentsBaseDir)
+		phase := rollbackPhasePrefix + componentName
+
+		prev, exist := r.prevComponents[componentName]
+		restore := existedBefore(prev, exist)
+		verb := "undeploy"
+		var params []parameters.LockedParameter
+		if step, exist := stateManifest.Components[componentName]; exist {
+			params = step.Parameters
+		}
+		if restore {
+			verb = "deploy"
+			params = prev.Parameters
+		}
+		verb = maybeTestVerb(verb, r.dryRun)
+		componentParameters := parameters.MergeParameters(make(parameters.LockedParameters), params)
+
+		if config.Verbose {
+			log.Printf(util.HighlightColor("rollback %s ***%s***"), verb, componentName)
+		}
+		stateManifest = state.UpdatePhase(stateManifest, r.operationLogId, phase, "in-progress")
+		stateUpdater(stateManifest)
+
+		stdout, stderr, err := fireHooks(ctx, "pre-"+verb, r.stackBaseDir, component, componentParameters, r.osEnv, nil)
+		if err == nil {
+			stdout, stderr, _, err = withRetry(ctx, componentManifest.Lifecycle.Retry, componentName, verb,
+				func() ([]byte, []byte, error) {
+					stdout, stderr, _, err := delegate(ctx, verb, component, componentManifest, componentParameters,
+						componentDir, r.osEnv, "", r.stackBaseDir, nil)
+					return stdout, stderr, err
+				})
+		}
+		if err == nil {
+			stdout, stderr, err = fireHooks(ctx, "post-"+verb, r.stackBaseDir, component, componentParameters, r.osEnv, nil)
+		}
+		if err != nil {
+			msg := fmt.Sprintf("Component `%s` failed to rollback (%s): %v", componentName, verb, err)
+			util.Warn("%s", msg)
+			if config.Debug {
+				log.Printf("Rollback output:%s", formatStdoutStderr(stdout, stderr))
+			}
+			stateManifest = state.UpdateComponentStatus(stateManifest, componentName, &componentManifest.Meta, "error", msg)
+			stateManifest = state.UpdatePhase(stateManifest, r.operationLogId, phase, "error")
+			stateUpdater(stateManifest)
+			failed = append(failed, componentName)
+			continue
+		}
+
+		if restore {
+			// parameters are the same, so should be the outputs
+			step := prev
+			stateManifest.Components[componentName] = &step
+		} else {
+			eraseProvides(r.provides, componentName)
+			stateManifest.Provides = noEnvironmentProvides(r.provides)
+			stateManifest = state.UpdateComponentStatus(stateManifest, componentName, &componentManifest.Meta, "undeployed", "")
+		}
+		stateManifest = state.UpdatePhase(stateManifest, r.operationLogId, phase, "success")
+		stateUpdater(stateManifest)
+	}
+
+	if len(failed) > 0 {
+		msg := fmt.Sprintf("Rollback failed for %s after: %s", strings.Join(failed, ", "), failure)
+		util.Warn("%s", msg)
+		return state.UpdateStackStatus(stateManifest, "incomplete", msg)
+	}
+	status, message := calculateStackStatus(r.stackManifest, stateManifest, "deploy")
+	if message == "" {
+		message = fmt.Sprintf("Rolled back after: %s", failure)
+	}
+	log.Printf("Rollback completed, stack status: %s", status)
+	return state.UpdateStackStatus(stateManifest, status, message)
+}
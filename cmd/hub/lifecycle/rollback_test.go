@@ -0,0 +1,83 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/epam/hubctl/cmd/hub/manifest"
+	"github.com/epam/hubctl/cmd/hub/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollbackComponents(t *testing.T) {
+	stateManifest := &state.StateManifest{
+		Operations: []state.LifecycleOperation{
+			{Id: "1", Phases: []state.LifecyclePhase{{Phase: "a", Status: "success"}}},
+			{Id: "2", Phases: []state.LifecyclePhase{
+				{Phase: "b", Status: "success"},
+				{Phase: "c", Status: "error"},
+				{Phase: "rollback-c", Status: "success"},
+				{Phase: "unknown", Status: "success"},
+			}},
+		},
+	}
+	order := []string{"a", "b", "c", "d"}
+	assert.Equal(t, []string{"c", "b"}, rollbackComponents(stateManifest, "2", order))
+	assert.Empty(t, rollbackComponents(stateManifest, "3", order))
+}
+
+func TestExistedBefore(t *testing.T) {
+	assert.False(t, existedBefore(state.StateStep{}, false))
+	assert.False(t, existedBefore(state.StateStep{Status: "undeployed"}, true))
+	assert.True(t, existedBefore(state.StateStep{Status: "deployed"}, true))
+	assert.True(t, existedBefore(state.StateStep{Status: "error"}, true))
+}
+
+func TestRollbackDryRun(t *testing.T) {
+	dir := t.TempDir()
+	for _, verb := range []string{"undeploy", "undeploy-test"} {
+		script := "#!/bin/sh\ntouch " + filepath.Join(dir, verb+".done") + "\n"
+		if err := os.WriteFile(filepath.Join(dir, verb), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stateManifest := &state.StateManifest{
+		Components: map[string]*state.StateStep{"a": {Status: "deployed"}},
+		Operations: []state.LifecycleOperation{
+			{Id: "op", Phases: []state.LifecyclePhase{{Phase: "a", Status: "error"}}},
+		},
+	}
+	r := &rollbackRequest{
+		ctx:    context.Background(),
+		dryRun: true,
+		stackManifest: &manifest.Manifest{
+			Components: []manifest.ComponentRef{{Name: "a", Source: manifest.SourceLocation{Dir: dir}}},
+			Lifecycle:  manifest.Lifecycle{Order: []string{"a"}},
+		},
+		componentsManifests: []manifest.Manifest{{Meta: manifest.Metadata{Name: "a"}}},
+		stackBaseDir:        dir,
+		operationLogId:      "op",
+		provides:            make(map[string][]string),
+		prevComponents:      map[string]state.StateStep{},
+	}
+	rollbackDeploy(r, stateManifest, func(interface{}) {}, "failed")
+	assert.FileExists(t, filepath.Join(dir, "undeploy-test.done"))
+	assert.NoFileExists(t, filepath.Join(dir, "undeploy.done"))
+
+	// rollback stops when the stack context is done
+	os.Remove(filepath.Join(dir, "undeploy-test.done"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.ctx = ctx
+	stateManifest = rollbackDeploy(r, stateManifest, func(interface{}) {}, "failed")
+	assert.NoFileExists(t, filepath.Join(dir, "undeploy-test.done"))
+	assert.Equal(t, "incomplete", stateManifest.Status)
+}
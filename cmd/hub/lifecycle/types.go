@@ -29,4 +29,14 @@ type Request struct {
 	SyncStackInstance          bool
 	SyncSkipParametersAndOplog bool
 	WriteOplogToStateOnError   bool
+	Parallelism                int    // deploy & undeploy
+	ChangedOnly                bool   // deploy
+	RollbackOnFailure          bool   // deploy
+	TimeoutSeconds             int    // deploy & undeploy
+	Resume                     bool   // deploy & undeploy
+	LogsDir                    string // deploy & undeploy
+	LogsGzip                   bool   // deploy & undeploy
+	LogsRetention              int    // deploy & undeploy
+	LockWaitSeconds            int
+	LockLeaseSeconds           int
 }
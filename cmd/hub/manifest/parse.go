@@ -44,6 +44,12 @@ func ParseManifest(manifestFilenames []string) (*Manifest, []Manifest, string, e
 			return nil, nil, manifestFilename, fmt.Errorf("Unable to parse %s (doc %d/%d): %v",
 				manifestFilename, i+1, len(yamlDocuments), err)
 		}
+		if retry := manifest.Lifecycle.Retry; retry != nil {
+			if err := retry.Compile(); err != nil {
+				return nil, nil, manifestFilename, fmt.Errorf("Unable to parse %s (doc %d/%d) lifecycle.retry: %v",
+					manifestFilename, i+1, len(yamlDocuments), err)
+			}
+		}
 		manifest.Document = string(yamlDocument)
 		manifests = append(manifests, manifest)
 	}
@@ -0,0 +1,45 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Compile validates and compiles retryable errors regexps
+func (policy *RetryPolicy) Compile() error {
+	retryable := make([]*regexp.Regexp, 0, len(policy.RetryableErrors))
+	for _, expr := range policy.RetryableErrors {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("Bad retryable error regexp `%s`: %v", expr, err)
+		}
+		retryable = append(retryable, re)
+	}
+	policy.retryable = retryable
+	return nil
+}
+
+// Retryable returns true if stderr matches one of retryable errors regexps, or there are none
+func (policy *RetryPolicy) Retryable(stderr []byte) bool {
+	if len(policy.RetryableErrors) == 0 {
+		return true
+	}
+	// policy not obtained via ParseManifest
+	if len(policy.retryable) != len(policy.RetryableErrors) {
+		if err := policy.Compile(); err != nil {
+			return false
+		}
+	}
+	for _, re := range policy.retryable {
+		if re.Match(stderr) {
+			return true
+		}
+	}
+	return false
+}
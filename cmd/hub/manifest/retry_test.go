@@ -0,0 +1,44 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := &RetryPolicy{RetryableErrors: []string{"timeout|throttl"}}
+	assert.NoError(t, policy.Compile())
+	assert.True(t, policy.Retryable([]byte("Error: request throttled")))
+	assert.False(t, policy.Retryable([]byte("Error: permission denied")))
+	assert.True(t, (&RetryPolicy{}).Retryable([]byte("anything")))
+
+	policy = &RetryPolicy{RetryableErrors: []string{"timeout("}}
+	assert.Error(t, policy.Compile())
+	assert.False(t, policy.Retryable([]byte("timeout(")))
+}
+
+func TestManifestParseBadRetryRegexp(t *testing.T) {
+	manifestFile := filepath.Join(t.TempDir(), "hub.yaml")
+	os.WriteFile(manifestFile, []byte(`kind: stack
+meta:
+  name: retry
+lifecycle:
+  retry:
+    attempts: 3
+    retryableErrors:
+    - "timeout("
+`), 0644)
+	_, _, _, err := ParseManifest([]string{manifestFile})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "lifecycle.retry")
+	}
+}
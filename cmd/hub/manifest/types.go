@@ -6,7 +6,10 @@
 
 package manifest
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+)
 
 type Git struct {
 	Remote   string
@@ -61,10 +64,32 @@ type RequiresTuning struct {
 }
 
 type ReadyCondition struct {
-	DNS          string `yaml:"dns,omitempty"`
-	URL          string `yaml:"url,omitempty"`
-	WaitSeconds  int    `yaml:"waitSeconds,omitempty"`
-	PauseSeconds int    `yaml:"pauseSeconds,omitempty"`
+	DNS          string                    `yaml:"dns,omitempty"`
+	URL          string                    `yaml:"url,omitempty"`
+	TCP          string                    `yaml:"tcp,omitempty"`
+	HTTP         *HttpReadyCondition       `yaml:"http,omitempty"`
+	Command      string                    `yaml:"command,omitempty"`
+	Kubernetes   *KubernetesReadyCondition `yaml:"kubernetes,omitempty"`
+	WaitSeconds  int                       `yaml:"waitSeconds,omitempty"`
+	PauseSeconds int                       `yaml:"pauseSeconds,omitempty"`
+}
+
+type HttpReadyCondition struct {
+	URL       string            `yaml:"url"`
+	Method    string            `yaml:"method,omitempty"`
+	Headers   map[string]string `yaml:"headers,omitempty"`
+	Status    []int             `yaml:"status,omitempty"`    // expected status codes, default to 2xx
+	Body      string            `yaml:"body,omitempty"`      // regexp the body must match
+	JSONPath  string            `yaml:"jsonPath,omitempty"`  // $.status.phase
+	JSONValue string            `yaml:"jsonValue,omitempty"` // expected value at jsonPath, any if not set
+}
+
+type KubernetesReadyCondition struct {
+	Kind      string `yaml:"kind"` // Deployment, StatefulSet, DaemonSet, or custom resource kind
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+	Condition string `yaml:"condition,omitempty"` // status condition to wait for, ie. Ready; required for custom resources
+	Context   string `yaml:"context,omitempty"`
 }
 
 type LifecycleOptions struct {
@@ -73,6 +98,23 @@ type LifecycleOptions struct {
 	} `yaml:",omitempty"`
 }
 
+type RetryPolicy struct {
+	Attempts              int      `yaml:",omitempty"`
+	InitialBackoffSeconds int      `yaml:"initialBackoffSeconds,omitempty"`
+	MaxBackoffSeconds     int      `yaml:"maxBackoffSeconds,omitempty"`
+	RetryableErrors       []string `yaml:"retryableErrors,omitempty"` // regexps matched against stderr, any error if empty
+
+	retryable []*regexp.Regexp
+}
+
+// ContainerRunner runs component implementation in a container via Docker-compatible or Podman CLI
+type ContainerRunner struct {
+	Image      string   `yaml:"image"`
+	Entrypoint string   `yaml:"entrypoint,omitempty"`
+	Mounts     []string `yaml:"mounts,omitempty"`  // host:container[:options], host path is relative to component dir
+	Runtime    string   `yaml:"runtime,omitempty"` // docker, podman, or compatible CLI; auto-detected if empty
+}
+
 type Lifecycle struct {
 	Bare            string            `yaml:",omitempty"`
 	Verbs           []string          `yaml:",omitempty"`
@@ -81,6 +123,9 @@ type Lifecycle struct {
 	Optional        []string          `yaml:",omitempty"`
 	Requires        RequiresTuning    `yaml:",omitempty"` // TODO use pointer?
 	ReadyConditions []ReadyCondition  `yaml:"readyConditions,omitempty"`
+	Retry           *RetryPolicy      `yaml:",omitempty"`
+	Container       *ContainerRunner  `yaml:",omitempty"`
+	TimeoutSeconds  int               `yaml:"timeoutSeconds,omitempty"`
 	Options         *LifecycleOptions `yaml:",omitempty"`
 }
 
@@ -138,6 +183,7 @@ type Manifest struct {
 	Platform PlatformMetadata `yaml:",omitempty"`
 
 	Lifecycle  Lifecycle     `yaml:",omitempty"`
+	Hooks      []Hook        `yaml:",omitempty"` // stack hooks
 	Outputs    []Output      `yaml:",omitempty"`
 	Parameters []Parameter   `yaml:",omitempty"`
 	Templates  TemplateSetup `yaml:",omitempty"`
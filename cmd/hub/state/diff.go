@@ -0,0 +1,323 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/epam/hubctl/cmd/hub/parameters"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+const MaskedValue = "(masked)"
+
+type ValueChange struct {
+	Name   string `yaml:"name" json:"name"`
+	Change string `yaml:"change" json:"change"` // added, removed, changed
+	Value  string `yaml:",omitempty" json:"value,omitempty"`
+	Was    string `yaml:",omitempty" json:"was,omitempty"`
+}
+
+func MaybeMaskValue(name string, value interface{}, showSecrets bool) string {
+	str := util.String(value)
+	if !showSecrets && util.LooksLikeSecret(name) && str != "" {
+		return MaskedValue
+	}
+	return str
+}
+
+func DiffParameters(curr, prev []parameters.LockedParameter, showSecrets bool) []ValueChange {
+	currValues := make(map[string]string)
+	for _, p := range curr {
+		currValues[p.QName()] = util.String(p.Value)
+	}
+	prevValues := make(map[string]string)
+	for _, p := range prev {
+		prevValues[p.QName()] = util.String(p.Value)
+	}
+	return diffValues(currValues, prevValues, showSecrets)
+}
+
+func diffValues(curr, prev map[string]string, showSecrets bool) []ValueChange {
+	return diffValues2(curr, prev, nil, showSecrets)
+}
+
+// diffValues2 masks values of names in secrets in addition to names that look like a secret
+func diffValues2(curr, prev map[string]string, secrets map[string]bool, showSecrets bool) []ValueChange {
+	mask := func(name, value string) string {
+		if !showSecrets && secrets[name] && value != "" {
+			return MaskedValue
+		}
+		return MaybeMaskValue(name, value, showSecrets)
+	}
+	changes := make([]ValueChange, 0)
+	for _, name := range util.SortedKeys(curr) {
+		value := curr[name]
+		was, exist := prev[name]
+		if !exist {
+			changes = append(changes, ValueChange{Name: name, Change: "added", Value: mask(name, value)})
+		} else if was != value {
+			changes = append(changes, ValueChange{Name: name, Change: "changed",
+				Value: mask(name, value), Was: mask(name, was)})
+		}
+	}
+	removed := make([]string, 0)
+	for name := range prev {
+		if _, exist := curr[name]; !exist {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		changes = append(changes, ValueChange{Name: name, Change: "removed", Was: mask(name, prev[name])})
+	}
+	return changes
+}
+
+func FormatValueChange(change ValueChange) string {
+	switch change.Change {
+	case "added":
+		return fmt.Sprintf("+ %s => `%s`", change.Name, util.Wrap(change.Value))
+	case "removed":
+		return fmt.Sprintf("- %s (was: `%s`)", change.Name, util.Wrap(change.Was))
+	default:
+		return fmt.Sprintf("~ %s => `%s` (was: `%s`)", change.Name, util.Wrap(change.Value), util.Wrap(change.Was))
+	}
+}
+
+type ComponentDiff struct {
+	Component  string        `json:"component"`
+	Change     string        `json:"change"` // added, removed, changed
+	Status     string        `json:"status,omitempty"`
+	StatusWas  string        `json:"statusWas,omitempty"`
+	Parameters []ValueChange `json:"parameters,omitempty"`
+	Outputs    []ValueChange `json:"outputs,omitempty"`
+	RawOutputs []ValueChange `json:"rawOutputs,omitempty"`
+}
+
+type StateDiff struct {
+	Status       string          `json:"status,omitempty"`
+	StatusWas    string          `json:"statusWas,omitempty"`
+	Components   []ComponentDiff `json:"components,omitempty"`
+	Provides     []ValueChange   `json:"provides,omitempty"`
+	StackOutputs []ValueChange   `json:"stackOutputs,omitempty"`
+}
+
+func (diff *StateDiff) Empty() bool {
+	return diff.Status == diff.StatusWas && len(diff.Components) == 0 &&
+		len(diff.Provides) == 0 && len(diff.StackOutputs) == 0
+}
+
+// DiffState compares component parameters, outputs, and statuses, provides, and stack outputs
+// of two states; values of secrets are masked unless showSecrets is set
+func DiffState(curr, prev *StateManifest, showSecrets bool) *StateDiff {
+	diff := &StateDiff{}
+	if curr.Status != prev.Status {
+		diff.Status = curr.Status
+		diff.StatusWas = prev.Status
+	}
+
+	empty := &StateStep{}
+	for _, name := range sortedComponentNames(curr.Components, prev.Components) {
+		currStep, currExist := curr.Components[name]
+		prevStep, prevExist := prev.Components[name]
+		componentDiff := ComponentDiff{Component: name, Change: "changed"}
+		if !currExist {
+			componentDiff.Change = "removed"
+			currStep = empty
+		} else if !prevExist {
+			componentDiff.Change = "added"
+			prevStep = empty
+		}
+		if currStep.Status != prevStep.Status {
+			componentDiff.Status = currStep.Status
+			componentDiff.StatusWas = prevStep.Status
+		}
+		componentDiff.Parameters = DiffParameters(currStep.Parameters, prevStep.Parameters, showSecrets)
+		currOutputs, currSecrets := capturedOutputsValues(currStep.CapturedOutputs)
+		prevOutputs, prevSecrets := capturedOutputsValues(prevStep.CapturedOutputs)
+		componentDiff.Outputs = diffValues2(currOutputs, prevOutputs, mergeSecrets(currSecrets, prevSecrets), showSecrets)
+		componentDiff.RawOutputs = diffValues(rawOutputsValues(currStep.RawOutputs), rawOutputsValues(prevStep.RawOutputs),
+			showSecrets)
+		if componentDiff.Change != "changed" || componentDiff.Status != componentDiff.StatusWas ||
+			len(componentDiff.Parameters) > 0 || len(componentDiff.Outputs) > 0 || len(componentDiff.RawOutputs) > 0 {
+			diff.Components = append(diff.Components, componentDiff)
+		}
+	}
+
+	diff.Provides = diffValues(providesValues(curr.Provides), providesValues(prev.Provides), true)
+	currOutputs, currSecrets := expandedOutputsValues(curr.StackOutputs)
+	prevOutputs, prevSecrets := expandedOutputsValues(prev.StackOutputs)
+	diff.StackOutputs = diffValues2(currOutputs, prevOutputs, mergeSecrets(currSecrets, prevSecrets), showSecrets)
+	return diff
+}
+
+func sortedComponentNames(components ...map[string]*StateStep) []string {
+	names := make([]string, 0)
+	for _, m := range components {
+		for name := range m {
+			if !util.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func capturedOutputsValues(outputs []parameters.CapturedOutput) (map[string]string, map[string]bool) {
+	values := make(map[string]string)
+	secrets := make(map[string]bool)
+	for _, output := range outputs {
+		if strings.HasPrefix(output.Name, "hub.components.") {
+			continue
+		}
+		values[output.Name] = util.String(output.Value)
+		if strings.HasPrefix(output.Kind, "secret") {
+			secrets[output.Name] = true
+		}
+	}
+	return values, secrets
+}
+
+func expandedOutputsValues(outputs []parameters.ExpandedOutput) (map[string]string, map[string]bool) {
+	values := make(map[string]string)
+	secrets := make(map[string]bool)
+	for _, output := range outputs {
+		values[output.Name] = util.String(output.Value)
+		if strings.HasPrefix(output.Kind, "secret") {
+			secrets[output.Name] = true
+		}
+	}
+	return values, secrets
+}
+
+func rawOutputsValues(outputs []parameters.RawOutput) map[string]string {
+	values := make(map[string]string)
+	for _, output := range outputs {
+		values[output.Name] = output.Value
+	}
+	return values
+}
+
+func providesValues(provides map[string][]string) map[string]string {
+	values := make(map[string]string)
+	for provide, by := range provides {
+		sorted := append([]string(nil), by...)
+		sort.Strings(sorted)
+		values[provide] = strings.Join(sorted, ", ")
+	}
+	return values
+}
+
+func mergeSecrets(a, b map[string]bool) map[string]bool {
+	for name := range b {
+		a[name] = true
+	}
+	return a
+}
+
+func formatStatusChange(status, was string) string {
+	return fmt.Sprintf("status: %s (was: %s)", orNone(status), orNone(was))
+}
+
+func orNone(str string) string {
+	if str == "" {
+		return "(none)"
+	}
+	return str
+}
+
+func PrintStateDiff(diff *StateDiff) {
+	if diff.Empty() {
+		fmt.Print("No changes\n")
+		return
+	}
+	if diff.Status != diff.StatusWas {
+		fmt.Printf("Stack %s\n", formatStatusChange(diff.Status, diff.StatusWas))
+	}
+	for _, component := range diff.Components {
+		fmt.Printf("Component %s: %s\n", component.Component, component.Change)
+		if component.Status != component.StatusWas {
+			fmt.Printf("\t%s\n", formatStatusChange(component.Status, component.StatusWas))
+		}
+		for _, section := range []struct {
+			title   string
+			changes []ValueChange
+		}{
+			{"Parameters", component.Parameters},
+			{"Outputs", component.Outputs},
+			{"Raw outputs", component.RawOutputs},
+		} {
+			if len(section.changes) > 0 {
+				fmt.Printf("\t%s:\n", section.title)
+				for _, change := range section.changes {
+					fmt.Printf("\t\t%s\n", FormatValueChange(change))
+				}
+			}
+		}
+	}
+	if len(diff.Provides) > 0 {
+		fmt.Print("Provides:\n")
+		for _, change := range diff.Provides {
+			fmt.Printf("\t%s\n", FormatValueChange(change))
+		}
+	}
+	if len(diff.StackOutputs) > 0 {
+		fmt.Print("Stack outputs:\n")
+		for _, change := range diff.StackOutputs {
+			fmt.Printf("\t%s\n", FormatValueChange(change))
+		}
+	}
+}
+
+// UnifiedStateDiff renders the parts of the states compared by DiffState as text and returns unified diff
+func UnifiedStateDiff(curr, prev *StateManifest, currLabel, prevLabel string, showSecrets bool) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(renderStateForDiff(prev, showSecrets)),
+		B:        difflib.SplitLines(renderStateForDiff(curr, showSecrets)),
+		FromFile: prevLabel,
+		ToFile:   currLabel,
+		Context:  3,
+	})
+}
+
+func renderStateForDiff(manifest *StateManifest, showSecrets bool) string {
+	var out strings.Builder
+	values := func(title string, values map[string]string, secrets map[string]bool, ident string) {
+		if len(values) == 0 {
+			return
+		}
+		fmt.Fprintf(&out, "%s%s:\n", ident, title)
+		for _, change := range diffValues2(values, nil, secrets, showSecrets) {
+			fmt.Fprintf(&out, "%s  %s: %s\n", ident, change.Name, change.Value)
+		}
+	}
+	fmt.Fprintf(&out, "status: %s\n", manifest.Status)
+	fmt.Fprint(&out, "components:\n")
+	for _, name := range sortedComponentNames(manifest.Components) {
+		step := manifest.Components[name]
+		fmt.Fprintf(&out, "  %s:\n    status: %s\n", name, step.Status)
+		params := make(map[string]string)
+		for _, p := range step.Parameters {
+			params[p.QName()] = util.String(p.Value)
+		}
+		values("parameters", params, nil, "    ")
+		outputs, secrets := capturedOutputsValues(step.CapturedOutputs)
+		values("outputs", outputs, secrets, "    ")
+		values("rawOutputs", rawOutputsValues(step.RawOutputs), nil, "    ")
+	}
+	provides := providesValues(manifest.Provides)
+	values("provides", provides, nil, "")
+	outputs, secrets := expandedOutputsValues(manifest.StackOutputs)
+	values("stackOutputs", outputs, secrets, "")
+	return out.String()
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/parameters"
+)
+
+func TestDiffState(t *testing.T) {
+	prev := testStateManifest()
+	curr := testStateManifest()
+	assert.True(t, DiffState(curr, prev, false).Empty())
+
+	curr.Status = "incomplete"
+	curr.Components["vpc"].CapturedOutputs = []parameters.CapturedOutput{
+		{Component: "vpc", Name: "vpc.id", Value: "vpc-2"},
+		{Component: "vpc", Name: "vpc.token", Value: "abc", Kind: "secret"},
+		{Component: "vpc", Name: "hub.components.vpc.status", Value: "deployed"},
+	}
+	curr.Components["eks"].Status = "error"
+	curr.Components["rds"] = &StateStep{Status: "deployed", RawOutputs: []parameters.RawOutput{{Name: "host", Value: "db"}}}
+	delete(curr.Provides, "kubernetes")
+	curr.StackOutputs = []parameters.ExpandedOutput{{Name: "dns.password", Value: "s3cret"}}
+
+	diff := DiffState(curr, prev, false)
+	assert.Equal(t, "incomplete", diff.Status)
+	assert.Equal(t, "deployed", diff.StatusWas)
+	if assert.Len(t, diff.Components, 3) {
+		eks := diff.Components[0]
+		assert.Equal(t, []string{"eks", "changed", "error", "deployed"}, []string{eks.Component, eks.Change, eks.Status, eks.StatusWas})
+		assert.Empty(t, eks.Parameters)
+		rds := diff.Components[1]
+		assert.Equal(t, []string{"rds", "added", "deployed"}, []string{rds.Component, rds.Change, rds.Status})
+		assert.Equal(t, []ValueChange{{Name: "host", Change: "added", Value: "db"}}, rds.RawOutputs)
+		assert.Equal(t, []ValueChange{
+			{Name: "vpc.id", Change: "changed", Value: "vpc-2", Was: "vpc-1"},
+			{Name: "vpc.token", Change: "added", Value: MaskedValue},
+		}, diff.Components[2].Outputs)
+	}
+	assert.Equal(t, []ValueChange{{Name: "kubernetes", Change: "removed", Was: "eks"}}, diff.Provides)
+	assert.Equal(t, []ValueChange{{Name: "dns.password", Change: "added", Value: MaskedValue}}, diff.StackOutputs)
+
+	diff = DiffState(prev, curr, true)
+	assert.Equal(t, "removed", diff.Components[1].Change)
+	assert.Equal(t, "s3cret", diff.StackOutputs[0].Was)
+
+	unified, err := UnifiedStateDiff(curr, prev, "b", "a", false)
+	if assert.Nil(t, err) {
+		assert.Contains(t, unified, "--- a\n+++ b\n")
+		assert.Contains(t, unified, "-      vpc.id: vpc-1\n+      vpc.id: vpc-2\n+      vpc.token: (masked)\n")
+		assert.Contains(t, unified, "-  kubernetes: eks\n")
+		assert.NotContains(t, unified, "s3cret")
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package state
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v2"
+
+	"github.com/epam/hubctl/cmd/hub/parameters"
+	"github.com/epam/hubctl/cmd/hub/storage"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+const backupTimestampFormat = "20060102T150405.000Z"
+
+// EditState applies edit to the state while holding the state lock and records the operation
+// in operations log. The state is copied to <file>.backup-<timestamp> before it is overwritten.
+func EditState(stateFiles *storage.Files, operation string, lease, wait time.Duration,
+	edit func(*StateManifest) error) error {
+
+	operationId, err := uuid.NewRandom()
+	if err != nil {
+		return fmt.Errorf("Unable to generate operation Id random v4 UUID: %v", err)
+	}
+	lock, err := storage.AcquireLock(stateFiles, operation, operationId.String(), lease, wait)
+	if err != nil {
+		return fmt.Errorf("Unable to lock state: %v", err)
+	}
+	defer lock.Release()
+
+	manifest, err := ParseState(stateFiles)
+	if err != nil {
+		return err
+	}
+	original, err := yaml.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("Unable to marshal state into YAML: %v", err)
+	}
+	if err := edit(manifest); err != nil {
+		return err
+	}
+	UpdateOperation(manifest, operationId.String(), operation, "success", nil)
+
+	backupFiles := backupStateFiles(stateFiles, time.Now())
+	if written, errs := storage.Write(original, backupFiles); !written {
+		return fmt.Errorf("Unable to backup state: %s", util.Errors2(errs...))
+	} else if len(errs) > 0 {
+		util.Warn("Unable to backup state: %s", util.Errors2(errs...))
+	}
+	manifest.Timestamp = time.Now()
+	return WriteState(manifest, stateFiles)
+}
+
+func backupStateFiles(stateFiles *storage.Files, timestamp time.Time) *storage.Files {
+	suffix := timestamp.UTC().Format(backupTimestampFormat)
+	files := make([]storage.File, 0, len(stateFiles.Files))
+	for _, file := range stateFiles.Files {
+		files = append(files, storage.File{Kind: file.Kind, Path: storage.PathWithSuffix(file.Path, ".backup-"+suffix)})
+	}
+	return &storage.Files{Kind: "state backup", Files: files}
+}
+
+func RemoveComponent(manifest *StateManifest, name string) error {
+	if _, exist := manifest.Components[name]; !exist {
+		return fmt.Errorf("Component `%s` not found in state", name)
+	}
+	delete(manifest.Components, name)
+	manifest.Lifecycle.Order = util.Omit(manifest.Lifecycle.Order, name)
+	for provide, providers := range manifest.Provides {
+		providers = util.Omit(providers, name)
+		if len(providers) > 0 {
+			manifest.Provides[provide] = providers
+		} else {
+			delete(manifest.Provides, provide)
+		}
+	}
+
+	outputs := make([]parameters.CapturedOutput, 0, len(manifest.CapturedOutputs))
+	for _, output := range manifest.CapturedOutputs {
+		if output.Component != name {
+			outputs = append(outputs, output)
+		}
+	}
+	manifest.CapturedOutputs = outputs
+	params := make([]parameters.LockedParameter, 0, len(manifest.StackParameters))
+	for _, param := range manifest.StackParameters {
+		if param.Component != name {
+			params = append(params, param)
+		}
+	}
+	manifest.StackParameters = params
+	return nil
+}
+
+func RenameComponent(manifest *StateManifest, from, to string) error {
+	step, exist := manifest.Components[from]
+	if !exist {
+		return fmt.Errorf("Component `%s` not found in state", from)
+	}
+	if _, exist := manifest.Components[to]; exist {
+		return fmt.Errorf("Component `%s` already exist in state", to)
+	}
+	delete(manifest.Components, from)
+	manifest.Components[to] = step
+
+	rename := func(names []string) {
+		for i, name := range names {
+			if name == from {
+				names[i] = to
+			}
+		}
+	}
+	rename(manifest.Lifecycle.Order)
+	for _, providers := range manifest.Provides {
+		rename(providers)
+	}
+
+	renameOutputs := func(outputs []parameters.CapturedOutput) {
+		for i := range outputs {
+			if outputs[i].Component == from {
+				outputs[i].Component = to
+			}
+		}
+	}
+	renameOutputs(manifest.CapturedOutputs)
+	renameOutputs(step.CapturedOutputs)
+	renameParameters := func(params []parameters.LockedParameter) {
+		for i := range params {
+			if params[i].Component == from {
+				params[i].Component = to
+			}
+		}
+	}
+	renameParameters(manifest.StackParameters)
+	renameParameters(step.Parameters)
+	return nil
+}
+
+// SetComponentOutput sets component captured output; the output is also updated in stack
+// captured outputs if present
+func SetComponentOutput(manifest *StateManifest, component, name string, value interface{}) error {
+	step, exist := manifest.Components[component]
+	if !exist {
+		return fmt.Errorf("Component `%s` not found in state", component)
+	}
+	set := func(outputs []parameters.CapturedOutput) bool {
+		found := false
+		for i := range outputs {
+			if outputs[i].Name == name && (outputs[i].Component == "" || outputs[i].Component == component) {
+				outputs[i].Value = value
+				found = true
+			}
+		}
+		return found
+	}
+	if !set(step.CapturedOutputs) {
+		step.CapturedOutputs = append(step.CapturedOutputs,
+			parameters.CapturedOutput{Component: component, Name: name, Value: value})
+	}
+	set(manifest.CapturedOutputs)
+	step.Timestamp = time.Now()
+	return nil
+}
+
+// SetStatus sets component status, or stack status if component is empty
+func SetStatus(manifest *StateManifest, component, status, message string) error {
+	if status == "" {
+		return errors.New("Status must not be empty")
+	}
+	if component == "" {
+		UpdateStackStatus(manifest, status, message)
+		return nil
+	}
+	step, exist := manifest.Components[component]
+	if !exist {
+		return fmt.Errorf("Component `%s` not found in state", component)
+	}
+	step.Timestamp = time.Now()
+	step.Status = status
+	step.Message = message
+	return nil
+}
+
+// PruneOperations keeps last N operations in the operations log and returns number of removed operations
+func PruneOperations(manifest *StateManifest, keep int) int {
+	if keep < 0 {
+		keep = 0
+	}
+	pruned := len(manifest.Operations) - keep
+	if pruned <= 0 {
+		return 0
+	}
+	manifest.Operations = manifest.Operations[pruned:]
+	return pruned
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package state
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/parameters"
+	"github.com/epam/hubctl/cmd/hub/storage"
+)
+
+func testStateManifest() *StateManifest {
+	return &StateManifest{
+		Version:   1,
+		Kind:      "state",
+		Status:    "deployed",
+		Lifecycle: Lifecycle{Order: []string{"vpc", "eks"}},
+		Provides:  map[string][]string{"vpc": {"vpc"}, "kubernetes": {"eks"}},
+		StackParameters: []parameters.LockedParameter{
+			{Name: "dns.domain", Value: "dev.example.com"},
+			{Component: "eks", Name: "eks.version", Value: "1.30"},
+		},
+		CapturedOutputs: []parameters.CapturedOutput{
+			{Component: "vpc", Name: "vpc.id", Value: "vpc-1"},
+			{Component: "eks", Name: "kubernetes.api.endpoint", Value: "https://eks"},
+		},
+		Components: map[string]*StateStep{
+			"vpc": {Status: "deployed", CapturedOutputs: []parameters.CapturedOutput{
+				{Component: "vpc", Name: "vpc.id", Value: "vpc-1"}}},
+			"eks": {Status: "deployed", Parameters: []parameters.LockedParameter{
+				{Component: "eks", Name: "eks.version", Value: "1.30"}}},
+		},
+		Operations: []LifecycleOperation{{Id: "1"}, {Id: "2"}, {Id: "3"}},
+	}
+}
+
+func TestRemoveComponent(t *testing.T) {
+	st := testStateManifest()
+	assert.NotNil(t, RemoveComponent(st, "rds"))
+	assert.Nil(t, RemoveComponent(st, "eks"))
+	assert.Equal(t, []string{"vpc"}, st.Lifecycle.Order)
+	assert.Equal(t, map[string][]string{"vpc": {"vpc"}}, st.Provides)
+	assert.Len(t, st.CapturedOutputs, 1)
+	assert.Equal(t, []parameters.LockedParameter{{Name: "dns.domain", Value: "dev.example.com"}}, st.StackParameters)
+	assert.NotContains(t, st.Components, "eks")
+}
+
+func TestRenameComponent(t *testing.T) {
+	st := testStateManifest()
+	assert.NotNil(t, RenameComponent(st, "eks", "vpc"))
+	assert.Nil(t, RenameComponent(st, "eks", "kubernetes"))
+	assert.Equal(t, []string{"vpc", "kubernetes"}, st.Lifecycle.Order)
+	assert.Equal(t, []string{"kubernetes"}, st.Provides["kubernetes"])
+	assert.Equal(t, "kubernetes", st.CapturedOutputs[1].Component)
+	assert.Equal(t, "kubernetes", st.StackParameters[1].Component)
+	assert.Equal(t, "kubernetes", st.Components["kubernetes"].Parameters[0].Component)
+}
+
+func TestSetComponentOutput(t *testing.T) {
+	st := testStateManifest()
+	assert.Nil(t, SetComponentOutput(st, "vpc", "vpc.id", "vpc-2"))
+	assert.Equal(t, "vpc-2", st.Components["vpc"].CapturedOutputs[0].Value)
+	assert.Equal(t, "vpc-2", st.CapturedOutputs[0].Value)
+	assert.Nil(t, SetComponentOutput(st, "vpc", "vpc.cidr", "10.0.0.0/16"))
+	assert.Len(t, st.Components["vpc"].CapturedOutputs, 2)
+	assert.Len(t, st.CapturedOutputs, 2)
+	assert.NotNil(t, SetComponentOutput(st, "rds", "rds.host", "localhost"))
+}
+
+func TestSetStatusAndPruneOperations(t *testing.T) {
+	st := testStateManifest()
+	assert.Nil(t, SetStatus(st, "", "incomplete", "manual"))
+	assert.Equal(t, "incomplete", st.Status)
+	assert.Nil(t, SetStatus(st, "eks", "undeployed", ""))
+	assert.Equal(t, "undeployed", st.Components["eks"].Status)
+	assert.NotNil(t, SetStatus(st, "eks", "", ""))
+
+	assert.Equal(t, 1, PruneOperations(st, 2))
+	assert.Equal(t, []LifecycleOperation{{Id: "2"}, {Id: "3"}}, st.Operations)
+	assert.Equal(t, 0, PruneOperations(st, 5))
+}
+
+func TestEditState(t *testing.T) {
+	t.Setenv(storage.LockEnvVar, "")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hub.yaml.state")
+	files, _ := storage.Check([]string{path}, "state")
+	assert.Nil(t, WriteState(testStateManifest(), files))
+	files, _ = storage.Check([]string{path}, "state")
+
+	err := EditState(files, "state rm-component", time.Minute, 0, func(st *StateManifest) error {
+		return RemoveComponent(st, "eks")
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	edited, err := ParseState(files)
+	if assert.Nil(t, err) {
+		assert.NotContains(t, edited.Components, "eks")
+	}
+	backups, _ := filepath.Glob(path + ".backup-*")
+	if assert.Len(t, backups, 1) {
+		backup, _ := storage.Check(backups, "state")
+		original, err := ParseState(backup)
+		if assert.Nil(t, err) {
+			assert.Contains(t, original.Components, "eks")
+		}
+	}
+	assert.NoFileExists(t, path+".lock")
+
+	err = EditState(files, "state set-status", time.Minute, 0, func(st *StateManifest) error {
+		return errors.New("failed")
+	})
+	assert.NotNil(t, err)
+	assert.NoFileExists(t, path+".lock")
+	backups, _ = filepath.Glob(path + ".backup-*")
+	assert.Len(t, backups, 1, "failed edit must not write backup")
+}
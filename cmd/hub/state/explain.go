@@ -45,7 +45,8 @@ type ExplainedState struct {
 	Components      map[string]ExplainedComponent `yaml:",omitempty" json:"components,omitempty"`
 }
 
-func Explain(elaborateManifests, stateFilenames []string, opLog, global bool, componentName string, rawOutputs bool,
+func Explain(elaborateManifests, stateFilenames []string, opLog bool, opLogLines int, /*0 - no, -1 - all, N - tail*/
+	global bool, componentName string, rawOutputs bool,
 	format string /*text, kv, sh, json, yaml*/, color bool) {
 
 	if (color || config.Tty) && format == "text" {
@@ -66,7 +67,7 @@ func Explain(elaborateManifests, stateFilenames []string, opLog, global bool, co
 	components := state.Lifecycle.Order
 
 	if opLog {
-		printOpLog(state)
+		printOpLog(state, opLogLines)
 		return
 	}
 
@@ -349,7 +350,7 @@ func printProvides(deps map[string][]string) {
 	}
 }
 
-func printOpLog(st *StateManifest) {
+func printOpLog(st *StateManifest, logLines int) {
 	ops := st.Operations
 	if len(ops) == 0 {
 		fmt.Print("No operations log")
@@ -357,9 +358,40 @@ func printOpLog(st *StateManifest) {
 	fmt.Print("Operations:\n")
 	for _, op := range ops {
 		fmt.Print(formatOperation(op, true))
+		if logLines != 0 {
+			for _, filename := range op.LogFiles {
+				printLogFile(filename, logLines)
+			}
+		}
 	}
 }
 
+// printLogFile prints log file written by `deploy --logs-dir`, or last `lines` of it
+func printLogFile(filename string, lines int) {
+	fmt.Printf("\t--- %s\n", filename)
+	data, err := os.ReadFile(filename)
+	if err == nil && util.IsGzipData(data) {
+		data, err = util.Gunzip(data)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Print("\t(removed)\n")
+		} else {
+			util.Warn("Unable to read log file: %v", err)
+		}
+		return
+	}
+	text := strings.TrimRight(string(data), "\n")
+	if text == "" {
+		return
+	}
+	content := strings.Split(text, "\n")
+	if lines > 0 && len(content) > lines {
+		content = content[len(content)-lines:]
+	}
+	fmt.Printf("\t%s\n", strings.Join(content, "\n\t"))
+}
+
 func formatOperation(op LifecycleOperation, showLogs bool) string {
 	ident := "\t"
 	logs := ""
@@ -383,9 +415,13 @@ func formatOperation(op LifecycleOperation, showLogs bool) string {
 	if len(op.Phases) > 0 {
 		phases = fmt.Sprintf("%sPhases:\n%s\t%s\n", ident, ident, formatLifecyclePhases(op.Phases, ident))
 	}
-	return fmt.Sprintf("%s%s %s - %s %v%s%s %s\n%s%s%s",
+	logFiles := ""
+	if showLogs && len(op.LogFiles) > 0 {
+		logFiles = fmt.Sprintf("%sLog files:\n%s\t%s\n", ident, ident, strings.Join(op.LogFiles, "\n"+ident+"\t"))
+	}
+	return fmt.Sprintf("%s%s %s - %s %v%s%s %s\n%s%s%s%s",
 		ident, headColor("Operation:"), op.Operation, op.Status, op.Timestamp.Truncate(time.Second), initiator, description, op.Id,
-		options, phases, logs)
+		options, phases, logs, logFiles)
 }
 
 func formatLifecyclePhases(phases []LifecyclePhase, ident string) string {
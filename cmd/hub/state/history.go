@@ -0,0 +1,136 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package state
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/storage"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+// StateVersion is a state snapshot in history with operation that wrote it
+type StateVersion struct {
+	storage.Version
+	Operation       string
+	OperationStatus string
+	Status          string
+}
+
+var (
+	// operation id -> version name; the state may be written more than once after the operation
+	// is finished but only the last write of the operation is kept in history
+	stateVersions      = make(map[string]string)
+	lastVersionTime    time.Time
+	stateVersionsMutex sync.Mutex
+)
+
+// writeStateVersion writes state snapshot at operation boundary, periodic writes of
+// in-progress operation are skipped
+func writeStateVersion(manifest *StateManifest, yamlBytes []byte, stateFiles *storage.Files) {
+	operationId := ""
+	if len(manifest.Operations) > 0 {
+		op := manifest.Operations[len(manifest.Operations)-1]
+		if op.Status == "in-progress" {
+			return
+		}
+		operationId = op.Id
+	}
+	stateVersionsMutex.Lock()
+	name, exist := stateVersions[operationId]
+	if !exist {
+		// versions of the process are strictly ordered even if the clock is coarse
+		now := time.Now()
+		if !now.After(lastVersionTime) {
+			now = lastVersionTime.Add(time.Nanosecond)
+		}
+		lastVersionTime = now
+		name = storage.VersionName(now, operationId)
+		stateVersions[operationId] = name
+	}
+	stateVersionsMutex.Unlock()
+
+	written, errs := storage.WriteVersion(yamlBytes, stateFiles, name)
+	if len(errs) > 0 {
+		util.Warn("Unable to write state version: %s", util.Errors2(errs...))
+	}
+	if written && !exist {
+		if errs := storage.PruneVersions(stateFiles, config.StateHistory); len(errs) > 0 {
+			util.Warn("Unable to prune state history: %s", util.Errors2(errs...))
+		}
+	}
+}
+
+// ListStateVersions returns state history from oldest to newest
+func ListStateVersions(stateFiles *storage.Files) ([]StateVersion, error) {
+	versions, errs := storage.ListVersions(stateFiles)
+	if len(errs) > 0 {
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("Unable to list state history: %s", util.Errors2(errs...))
+		}
+		util.Warn("Unable to list state history: %s", util.Errors2(errs...))
+	}
+	stateVersions := make([]StateVersion, 0, len(versions))
+	for _, version := range versions {
+		stateVersion := StateVersion{Version: version}
+		manifest, err := parseStateVersion(&version)
+		if err != nil {
+			util.Warn("%v", err)
+		} else {
+			stateVersion.Status = manifest.Status
+			for _, op := range manifest.Operations {
+				if op.Id == version.OperationId {
+					stateVersion.Operation = op.Operation
+					stateVersion.OperationStatus = op.Status
+				}
+			}
+		}
+		stateVersions = append(stateVersions, stateVersion)
+	}
+	return stateVersions, nil
+}
+
+// ParseStateVersion finds state version by name, name prefix, or operation id and parses it
+func ParseStateVersion(stateFiles *storage.Files, name string) (*StateManifest, *storage.Version, error) {
+	versions, errs := storage.ListVersions(stateFiles)
+	if len(errs) > 0 && len(versions) == 0 {
+		return nil, nil, fmt.Errorf("Unable to list state history: %s", util.Errors2(errs...))
+	}
+	version, err := storage.FindVersion(versions, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest, err := parseStateVersion(version)
+	return manifest, version, err
+}
+
+func parseStateVersion(version *storage.Version) (*StateManifest, error) {
+	files, errs := storage.Check(version.Paths, "state version")
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("Unable to check state version files: %s", util.Errors2(errs...))
+	}
+	return ParseState(files)
+}
+
+// RollbackState restores state version under the state lock
+func RollbackState(stateFiles *storage.Files, name string, lease, wait time.Duration) error {
+	restore, version, err := ParseStateVersion(stateFiles, name)
+	if err != nil {
+		return err
+	}
+	if config.Verbose {
+		log.Printf("Restoring state version %s", version.Name)
+	}
+	return EditState(stateFiles, "state rollback", lease, wait, func(manifest *StateManifest) error {
+		*manifest = *restore
+		return nil
+	})
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/storage"
+)
+
+func TestStateHistoryAndRollback(t *testing.T) {
+	t.Setenv(storage.LockEnvVar, "")
+	history := config.StateHistory
+	config.StateHistory = 2
+	defer func() { config.StateHistory = history }()
+
+	path := filepath.Join(t.TempDir(), "hub.yaml.state")
+	files, _ := storage.Check([]string{path}, "state")
+	manifest := testStateManifest()
+	manifest = UpdateOperation(manifest, "deploy-1", "deploy", "in-progress", nil)
+	assert.Nil(t, WriteState(manifest, files))
+	versions, _ := ListStateVersions(files)
+	assert.Empty(t, versions, "periodic writes of operation in progress are not versioned")
+	manifest = UpdateOperation(manifest, "deploy-1", "deploy", "success", nil)
+	assert.Nil(t, WriteState(manifest, files))
+
+	versions, err := ListStateVersions(files)
+	if assert.Nil(t, err) && assert.Len(t, versions, 1, "one version per operation") {
+		assert.Equal(t, "deploy", versions[0].Operation)
+		assert.Equal(t, "success", versions[0].OperationStatus)
+		assert.Equal(t, "deployed", versions[0].Status)
+	}
+
+	files, _ = storage.Check([]string{path}, "state")
+	assert.Nil(t, EditState(files, "state set-status", time.Minute, 0, func(manifest *StateManifest) error {
+		return SetStatus(manifest, "", "incomplete", "")
+	}))
+	assert.Nil(t, RollbackState(files, "deploy-1", time.Minute, 0))
+
+	restored, err := ParseState(files)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "deployed", restored.Status)
+		assert.Equal(t, "state rollback", restored.Operations[len(restored.Operations)-1].Operation)
+	}
+	versions, _ = ListStateVersions(files)
+	if assert.Len(t, versions, 2) {
+		assert.Equal(t, "state set-status", versions[0].Operation)
+		assert.Equal(t, "state rollback", versions[1].Operation)
+	}
+	assert.NotNil(t, RollbackState(files, "deploy-2", time.Minute, 0))
+}
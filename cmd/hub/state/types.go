@@ -47,6 +47,14 @@ type StateStep struct {
 	Parameters      []parameters.LockedParameter `yaml:",omitempty"`
 	RawOutputs      []parameters.RawOutput       `yaml:"rawOutputs,omitempty"`
 	CapturedOutputs []parameters.CapturedOutput  `yaml:"capturedOutputs,omitempty"`
+	Drift           *ComponentDrift              `yaml:",omitempty"`
+}
+
+type ComponentDrift struct {
+	Component string    `yaml:"-" json:"component"`       // drift report only, the state is keyed by component
+	Status    string    `yaml:",omitempty" json:"status"` // in-sync, drifted, unknown, error
+	Message   string    `yaml:",omitempty" json:"message,omitempty"`
+	Timestamp time.Time `yaml:",omitempty" json:"timestamp"`
 }
 
 type LifecyclePhase struct {
@@ -63,6 +71,7 @@ type LifecycleOperation struct {
 	Description string                 `yaml:",omitempty"`
 	Initiator   string                 `yaml:",omitempty"`
 	Logs        string                 `yaml:",omitempty"`
+	LogFiles    []string               `yaml:"logFiles,omitempty"`
 	Phases      []LifecyclePhase       `yaml:",omitempty"`
 }
 
@@ -17,6 +17,7 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/events"
 	"github.com/epam/hubctl/cmd/hub/manifest"
 	"github.com/epam/hubctl/cmd/hub/parameters"
 	"github.com/epam/hubctl/cmd/hub/storage"
@@ -33,6 +34,10 @@ func InitWriter(stateFiles *storage.Files, atWrite func(*StateManifest)) func(in
 	ticker := time.NewTicker(1 * time.Second)
 	go writer(ch, done, ticker.C, stateFiles, atWrite)
 	update := func(v interface{}) {
+		if m, ok := v.(*StateManifest); ok {
+			// the caller continues to update the manifest while the writer marshals it
+			v = snapshot(m)
+		}
 		ch <- v
 		if cmd, ok := v.(string); ok && cmd == "done" {
 			ticker.Stop()
@@ -58,6 +63,7 @@ func writer(ch <-chan interface{}, done chan<- struct{}, ticker <-chan time.Time
 			if err != nil {
 				log.Printf("%v", err)
 			}
+			emitStateWritten(files, err)
 			if atWrite != nil {
 				atWrite(state)
 			}
@@ -105,6 +111,27 @@ func writer(ch <-chan interface{}, done chan<- struct{}, ticker <-chan time.Time
 	}
 }
 
+// snapshot copies the parts of state manifest that are updated in-place
+func snapshot(manifest *StateManifest) *StateManifest {
+	copied := *manifest
+	if manifest.Components != nil {
+		copied.Components = make(map[string]*StateStep, len(manifest.Components))
+		for name, step := range manifest.Components {
+			stepCopy := *step
+			copied.Components[name] = &stepCopy
+		}
+	}
+	if manifest.Operations != nil {
+		copied.Operations = make([]LifecycleOperation, len(manifest.Operations))
+		for i, op := range manifest.Operations {
+			op.Phases = append([]LifecyclePhase(nil), op.Phases...)
+			op.LogFiles = append([]string(nil), op.LogFiles...)
+			copied.Operations[i] = op
+		}
+	}
+	return &copied
+}
+
 func UpdateState(manifest *StateManifest, componentName string,
 	stackParameters parameters.LockedParameters, componentParameters []parameters.LockedParameter,
 	rawOutputs parameters.RawOutputs, outputs parameters.CapturedOutputs,
@@ -191,6 +218,13 @@ func UpdateComponentStatus(manifest *StateManifest, name string, meta *manifest.
 	return manifest
 }
 
+func UpdateComponentDrift(manifest *StateManifest, name, status, message string, timestamp time.Time) *StateManifest {
+	manifest = maybeInitState(manifest)
+	componentState := maybeInitComponentState(manifest, name)
+	componentState.Drift = &ComponentDrift{Status: status, Message: message, Timestamp: timestamp}
+	return manifest
+}
+
 func EraseComponentEmptyState(manifest *StateManifest, name string) *StateManifest {
 	manifest = maybeInitState(manifest)
 	componentState := manifest.Components[name]
@@ -222,6 +256,7 @@ func UpdateOperation(manifest *StateManifest, id, operation, status string, opti
 			op.Options = ops[found].Options
 		}
 		op.Logs = ops[found].Logs
+		op.LogFiles = ops[found].LogFiles
 		op.Phases = ops[found].Phases
 		ops[found] = op
 	} else {
@@ -267,6 +302,15 @@ func AppendOperationLog(manifest *StateManifest, id, logAdd string) *StateManife
 	return manifest
 }
 
+func UpdateOperationLogFiles(manifest *StateManifest, id string, files []string) *StateManifest {
+	foundOp := findOperation(manifest, id)
+	if foundOp == -1 {
+		return manifest
+	}
+	manifest.Operations[foundOp].LogFiles = files
+	return manifest
+}
+
 func UpdatePhase(manifest *StateManifest, opId, name, status string) *StateManifest {
 	foundOp := findOperation(manifest, opId)
 	if foundOp == -1 {
@@ -294,6 +338,19 @@ func UpdatePhase(manifest *StateManifest, opId, name, status string) *StateManif
 	return manifest
 }
 
+func emitStateWritten(files *storage.Files, err error) {
+	paths := make([]string, 0, len(files.Files))
+	for _, file := range files.Files {
+		paths = append(paths, file.Path)
+	}
+	event := events.Event{Type: events.StateWritten, Files: paths, Status: "success"}
+	if err != nil {
+		event.Status = "error"
+		event.Message = fmt.Sprintf("%v", err)
+	}
+	events.Emit(event)
+}
+
 func WriteState(manifest *StateManifest, stateFiles *storage.Files) error {
 	manifest.Version = 1
 	manifest.Kind = "state"
@@ -312,6 +369,9 @@ func WriteState(manifest *StateManifest, stateFiles *storage.Files) error {
 			util.Warn("%s", msg)
 		}
 	}
+	if config.StateHistory > 0 {
+		writeStateVersion(manifest, yamlBytes, stateFiles)
+	}
 	return nil
 }
 
@@ -0,0 +1,130 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/aws"
+	"github.com/epam/hubctl/cmd/hub/azure"
+	"github.com/epam/hubctl/cmd/hub/gcp"
+)
+
+// AnyVersion makes write unconditional
+const AnyVersion = "*"
+
+// Backend implements file operations for a storage kind. Version is an opaque ETag, generation,
+// or similar token that changes on every write.
+type Backend interface {
+	// Stat returns file size, modification time, and version, or os.ErrNotExist
+	Stat(path string) (int64, time.Time, string, error)
+	// Read returns file content and version, or os.ErrNotExist
+	Read(path string) ([]byte, string, error)
+	// Write creates the file if version is empty, replaces the file of the version, or writes
+	// unconditionally if version is AnyVersion; returns new version, or os.ErrExist if the file
+	// exists or was modified
+	Write(path string, data []byte, version string) (string, error)
+	// Delete deletes the file of the version, if set
+	Delete(path, version string) error
+	// List returns paths of files under the prefix
+	List(prefix string) ([]string, error)
+}
+
+// backends are keyed by URL scheme, local files are `fs`
+var backends = make(map[string]Backend)
+
+func RegisterBackend(scheme string, backend Backend) {
+	backends[scheme] = backend
+}
+
+func lookupBackend(kind string) (Backend, error) {
+	if backend, exist := backends[kind]; exist {
+		return backend, nil
+	}
+	return nil, fmt.Errorf("No storage backend for `%s` files", kind)
+}
+
+func remoteStorageSchemes() []string {
+	schemes := make([]string, 0, len(backends))
+	for scheme := range backends {
+		if scheme != "fs" {
+			schemes = append(schemes, scheme)
+		}
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// PathWithSuffix appends suffix to the path keeping URL query, if any, at the end
+func PathWithSuffix(path, suffix string) string {
+	if strings.Contains(path, "://") {
+		if i := strings.Index(path, "?"); i >= 0 {
+			return path[:i] + suffix + path[i:]
+		}
+	}
+	return path + suffix
+}
+
+// funcBackend adapts cloud storage functions to Backend
+type funcBackend struct {
+	stat   func(path string) (int64, time.Time, string, error)
+	read   func(path string) ([]byte, string, error)
+	write  func(path string, data []byte, version string) (string, error)
+	delete func(path, version string) error
+	list   func(prefix string) ([]string, error)
+}
+
+func (b *funcBackend) Stat(path string) (int64, time.Time, string, error) {
+	return b.stat(path)
+}
+
+func (b *funcBackend) Read(path string) ([]byte, string, error) {
+	return b.read(path)
+}
+
+func (b *funcBackend) Write(path string, data []byte, version string) (string, error) {
+	return b.write(path, data, version)
+}
+
+func (b *funcBackend) Delete(path, version string) error {
+	return b.delete(path, version)
+}
+
+func (b *funcBackend) List(prefix string) ([]string, error) {
+	return b.list(prefix)
+}
+
+func init() {
+	RegisterBackend("fs", &fsBackend{})
+	httpStorage := &httpBackend{}
+	RegisterBackend("http", httpStorage)
+	RegisterBackend("https", httpStorage)
+	RegisterBackend("s3", &funcBackend{
+		stat:   aws.StatS3,
+		read:   aws.ReadS3,
+		write:  aws.WriteS3,
+		delete: aws.DeleteS3,
+		list:   aws.ListS3,
+	})
+	RegisterBackend("gs", &funcBackend{
+		stat:   gcp.StatGCS,
+		read:   gcp.ReadGCS,
+		write:  gcp.WriteGCS,
+		delete: gcp.DeleteGCS,
+		list:   gcp.ListGCS,
+	})
+	RegisterBackend("az", &funcBackend{
+		stat:   azure.StatStorageBlob,
+		read:   azure.ReadStorageBlob,
+		write:  azure.WriteStorageBlob,
+		delete: azure.DeleteStorageBlob,
+		list:   azure.ListStorageBlobs,
+	})
+}
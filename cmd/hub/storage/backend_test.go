@@ -0,0 +1,105 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+)
+
+func TestPathWithSuffix(t *testing.T) {
+	assert.Equal(t, "hub.yaml.state.lock", PathWithSuffix("hub.yaml.state", ".lock"))
+	assert.Equal(t, "s3://bucket/hub.yaml.state.lock", PathWithSuffix("s3://bucket/hub.yaml.state", ".lock"))
+	assert.Equal(t, "s3://bucket/hub.yaml.state.history/v1?endpoint=http://minio:9000&pathStyle=true",
+		PathWithSuffix("s3://bucket/hub.yaml.state?endpoint=http://minio:9000&pathStyle=true", ".history/v1"))
+}
+
+func TestFsBackend(t *testing.T) {
+	fs := backends["fs"]
+	path := filepath.Join(t.TempDir(), "hub.yaml.state")
+
+	v1, err := fs.Write(path, []byte("v1"), "")
+	assert.Nil(t, err)
+	_, err = fs.Write(path, []byte("v1"), "")
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	v2, err := fs.Write(path, []byte("v2.."), v1)
+	assert.Nil(t, err)
+	_, err = fs.Write(path, []byte("v3"), v1)
+	assert.ErrorIs(t, err, os.ErrExist)
+	data, version, err := fs.Read(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "v2..", string(data))
+	assert.Equal(t, v2, version)
+
+	_, err = fs.Write(path, []byte("v3"), AnyVersion)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, fs.Delete(path, v2), os.ErrExist)
+	assert.Nil(t, fs.Delete(path, ""))
+	_, _, _, err = fs.Stat(path)
+	assert.Equal(t, os.ErrNotExist, err)
+}
+
+func TestFsBackendSymlink(t *testing.T) {
+	fs := backends["fs"]
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.state")
+	link := filepath.Join(dir, "hub.yaml.state")
+	assert.Nil(t, os.WriteFile(target, []byte("v1"), 0600))
+	assert.Nil(t, os.Symlink(target, link))
+
+	_, _, version, err := fs.Stat(link)
+	assert.Nil(t, err)
+	_, err = fs.Write(link, []byte("v2"), version)
+	assert.Nil(t, err)
+
+	info, err := os.Lstat(link)
+	if assert.Nil(t, err) {
+		assert.True(t, info.Mode()&os.ModeSymlink != 0, "symlink must not be replaced")
+	}
+	data, _ := os.ReadFile(target)
+	assert.Equal(t, "v2", string(data))
+	info, err = os.Stat(target)
+	if assert.Nil(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+}
+
+func TestWriteDetectsConcurrentWrite(t *testing.T) {
+	config.Encrypted = false
+	config.Compressed = false
+	defer func() { config.Force = false }()
+	path := filepath.Join(t.TempDir(), "hub.yaml.state")
+	assert.Nil(t, os.WriteFile(path, []byte("original"), 0644))
+
+	files, _ := Check([]string{path}, "state")
+	_, _, err := Read(files)
+	assert.Nil(t, err)
+	written, errs := Write([]byte("first"), files)
+	assert.True(t, written)
+	assert.Empty(t, errs)
+	written, errs = Write([]byte("second"), files)
+	assert.True(t, written, "version must be updated after write")
+	assert.Empty(t, errs)
+
+	assert.Nil(t, os.WriteFile(path, []byte("concurrent write"), 0644))
+	written, errs = Write([]byte("third"), files)
+	assert.False(t, written)
+	assert.Len(t, errs, 1)
+
+	config.Force = true
+	written, errs = Write([]byte("forced"), files)
+	assert.True(t, written)
+	assert.Empty(t, errs)
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "forced", string(data))
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+// fsBackend is local files backend; version is modification time and size, which is enough
+// to detect concurrent writes
+type fsBackend struct{}
+
+func fsVersion(info os.FileInfo) string {
+	return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+}
+
+func (*fsBackend) Stat(path string) (int64, time.Time, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if util.NoSuchFile(err) {
+			return 0, time.Time{}, "", os.ErrNotExist
+		}
+		return 0, time.Time{}, "", err
+	}
+	return info.Size(), info.ModTime(), fsVersion(info), nil
+}
+
+func (*fsBackend) Read(path string) ([]byte, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if util.NoSuchFile(err) {
+			return nil, "", os.ErrNotExist
+		}
+		return nil, "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, fsVersion(info), nil
+}
+
+func (fs *fsBackend) Write(path string, data []byte, version string) (string, error) {
+	switch version {
+	case AnyVersion:
+		if err := writeFsFile(path, data, os.O_CREATE|os.O_TRUNC|os.O_WRONLY); err != nil {
+			return "", err
+		}
+
+	case "":
+		if err := writeFsFile(path, data, os.O_CREATE|os.O_EXCL|os.O_WRONLY); err != nil {
+			if os.IsExist(err) {
+				return "", os.ErrExist
+			}
+			return "", err
+		}
+
+	default:
+		var current string
+		err := withFsGuard(path, func() error {
+			var err error
+			current, err = fs.replace(path, data, version)
+			return err
+		})
+		return current, err
+	}
+	_, _, current, err := fs.Stat(path)
+	return current, err
+}
+
+// replace writes the file if the version matches; the file is replaced via rename so that
+// readers never see partial content
+func (fs *fsBackend) replace(path string, data []byte, version string) (string, error) {
+	_, _, current, err := fs.Stat(path)
+	if err != nil {
+		if err == os.ErrNotExist {
+			return "", os.ErrExist
+		}
+		return "", err
+	}
+	if current != version {
+		return "", os.ErrExist
+	}
+	fsBeforeReplace()
+	// replace the target of symlink, not the symlink itself
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return "", err
+	}
+	// non-regular file, ie. /dev/stdout, cannot be replaced
+	if !info.Mode().IsRegular() {
+		if err := writeFsFile(target, data, os.O_TRUNC|os.O_WRONLY); err != nil {
+			return "", err
+		}
+	} else {
+		temp := fmt.Sprintf("%s.%d", target, os.Getpid())
+		if err := os.WriteFile(temp, data, info.Mode().Perm()); err != nil {
+			return "", err
+		}
+		if err := os.Rename(temp, target); err != nil {
+			os.Remove(temp)
+			return "", err
+		}
+	}
+	_, _, current, err = fs.Stat(path)
+	return current, err
+}
+
+// fsBeforeReplace is replaced in tests to widen the window between compare and replace
+var fsBeforeReplace = func() {}
+
+const (
+	fsGuardSuffix  = ".cas"
+	fsGuardWait    = 10 * time.Second
+	fsGuardExpires = time.Minute
+)
+
+// withFsGuard serializes check-and-set of the file between operations via <file>.cas created
+// exclusively, as stat-compare-then-rename is not atomic; guard left by a crashed process
+// is removed after fsGuardExpires
+func withFsGuard(path string, routine func() error) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	guard := path + fsGuardSuffix
+	deadline := time.Now().Add(fsGuardWait)
+	for {
+		file, err := os.OpenFile(guard, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			break
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		if info, err := os.Stat(guard); err == nil && time.Since(info.ModTime()) > fsGuardExpires {
+			util.Warn("Removing stale `%s`", guard)
+			os.Remove(guard)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("`%s` is held by another operation", guard)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer os.Remove(guard)
+	return routine()
+}
+
+func writeFsFile(path string, data []byte, flag int) error {
+	file, err := os.OpenFile(path, flag, 0666)
+	if err != nil {
+		return err
+	}
+	wrote, err := file.Write(data)
+	err2 := file.Close()
+	if err != nil || wrote != len(data) || err2 != nil {
+		if flag&os.O_EXCL != 0 {
+			os.Remove(path)
+		}
+		return fmt.Errorf("wrote %d out of %d bytes: %s", wrote, len(data), util.Errors2(err, err2))
+	}
+	return nil
+}
+
+func (fs *fsBackend) Delete(path, version string) error {
+	if version == "" {
+		if _, _, _, err := fs.Stat(path); err != nil {
+			return err
+		}
+		return os.Remove(path)
+	}
+	return withFsGuard(path, func() error {
+		_, _, current, err := fs.Stat(path)
+		if err != nil {
+			return err
+		}
+		if current != version {
+			return os.ErrExist
+		}
+		return os.Remove(path)
+	})
+}
+
+func (*fsBackend) List(prefix string) ([]string, error) {
+	dir, base := ".", prefix
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir, base = prefix[:i+1], prefix[i+1:]
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if util.NoSuchFile(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasPrefix(entry.Name(), base) && !strings.HasSuffix(entry.Name(), fsGuardSuffix) {
+			if dir == "." {
+				paths = append(paths, entry.Name())
+			} else {
+				paths = append(paths, dir+entry.Name())
+			}
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package storage
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+)
+
+// fixed width, so that names sort in time order
+const versionTimestampFormat = "20060102T150405.000000000Z"
+
+// Version is a snapshot of the file kept in <file>.history/<timestamp>-<operation id>
+type Version struct {
+	Name        string
+	Timestamp   time.Time
+	OperationId string
+	Paths       []string
+}
+
+func historyPrefix(path string) string {
+	return PathWithSuffix(path, ".history/")
+}
+
+func pathWithoutQuery(path string) string {
+	if strings.Contains(path, "://") {
+		if i := strings.Index(path, "?"); i >= 0 {
+			return path[:i]
+		}
+	}
+	return path
+}
+
+func VersionName(timestamp time.Time, operationId string) string {
+	return fmt.Sprintf("%s-%s", timestamp.UTC().Format(versionTimestampFormat), operationId)
+}
+
+func parseVersionName(name string) (time.Time, string, bool) {
+	parts := strings.SplitN(name, "-", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", false
+	}
+	timestamp, err := time.Parse(versionTimestampFormat, parts[0])
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return timestamp, parts[1], true
+}
+
+// VersionPaths returns paths of the version snapshot of every file
+func VersionPaths(files *Files, name string) []string {
+	paths := make([]string, 0, len(files.Files))
+	for _, file := range files.Files {
+		paths = append(paths, PathWithSuffix(file.Path, ".history/"+name))
+	}
+	return paths
+}
+
+// WriteVersion writes the data as version snapshot of the files
+func WriteVersion(data []byte, files *Files, name string) (bool, []error) {
+	versionFiles := &Files{Kind: files.Kind + " version"}
+	for i, path := range VersionPaths(files, name) {
+		kind := files.Files[i].Kind
+		if kind == "fs" {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return false, []error{fmt.Errorf("Unable to create `%s`: %v", filepath.Dir(path), err)}
+			}
+		}
+		// version is rewritten by every state write of the operation
+		versionFiles.Files = append(versionFiles.Files, File{Kind: kind, Path: path, Version: AnyVersion})
+	}
+	return Write(data, versionFiles)
+}
+
+// ListVersions returns versions of the files sorted from oldest to newest
+func ListVersions(files *Files) ([]Version, []error) {
+	var errs []error
+	byName := make(map[string]*Version)
+	for _, file := range files.Files {
+		prefix := historyPrefix(file.Path)
+		paths, err := listFiles(file.Kind, prefix)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Unable to list `%s`: %v", prefix, err))
+			continue
+		}
+		for _, path := range paths {
+			name := strings.TrimPrefix(pathWithoutQuery(path), pathWithoutQuery(prefix))
+			timestamp, operationId, ok := parseVersionName(name)
+			if !ok {
+				if config.Debug {
+					log.Printf("Skipping `%s` - not a %s version", path, files.Kind)
+				}
+				continue
+			}
+			version, exist := byName[name]
+			if !exist {
+				version = &Version{Name: name, Timestamp: timestamp, OperationId: operationId}
+				byName[name] = version
+			}
+			version.Paths = append(version.Paths, path)
+		}
+	}
+
+	versions := make([]Version, 0, len(byName))
+	for _, version := range byName {
+		versions = append(versions, *version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if !versions[i].Timestamp.Equal(versions[j].Timestamp) {
+			return versions[i].Timestamp.Before(versions[j].Timestamp)
+		}
+		return versions[i].Name < versions[j].Name
+	})
+	return versions, errs
+}
+
+// FindVersion finds version by name or by unique name prefix or operation id
+func FindVersion(versions []Version, name string) (*Version, error) {
+	var found []Version
+	for _, version := range versions {
+		if version.Name == name {
+			return &version, nil
+		}
+		if strings.HasPrefix(version.Name, name) || version.OperationId == name {
+			found = append(found, version)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("Version `%s` not found", name)
+	case 1:
+		return &found[0], nil
+	default:
+		names := make([]string, 0, len(found))
+		for _, version := range found {
+			names = append(names, version.Name)
+		}
+		return nil, fmt.Errorf("Version `%s` is ambiguous: %s", name, strings.Join(names, ", "))
+	}
+}
+
+// PruneVersions deletes oldest versions to keep last N
+func PruneVersions(files *Files, keep int) []error {
+	versions, errs := ListVersions(files)
+	if len(versions) <= keep {
+		return errs
+	}
+	for _, version := range versions[:len(versions)-keep] {
+		for _, path := range version.Paths {
+			file, err := checkPath(path, files.Kind)
+			if err == nil {
+				err = deleteFile(file)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("Unable to delete `%s`: %v", path, err))
+				continue
+			}
+			if config.Debug {
+				log.Printf("Deleted %s version `%s`", files.Kind, path)
+			}
+		}
+	}
+	return errs
+}
+
+func listFiles(kind, prefix string) ([]string, error) {
+	backend, err := lookupBackend(kind)
+	if err != nil {
+		return nil, err
+	}
+	return backend.List(prefix)
+}
+
+func deleteFile(file *File) error {
+	backend, err := lookupBackend(file.Kind)
+	if err != nil {
+		return err
+	}
+	return backend.Delete(file.Path, "")
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersions(t *testing.T) {
+	dir := t.TempDir()
+	state := filepath.Join(dir, "hub.yaml.state")
+	files, _ := Check([]string{state}, "state")
+
+	versions, errs := ListVersions(files)
+	assert.Empty(t, errs)
+	assert.Empty(t, versions)
+
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i, op := range []string{"op-1", "op-2", "op-3"} {
+		written, errs := WriteVersion([]byte(op), files, VersionName(start.Add(time.Duration(i)*time.Minute), op))
+		assert.True(t, written)
+		assert.Empty(t, errs)
+	}
+	assert.Nil(t, os.WriteFile(filepath.Join(state+".history", "README"), []byte{}, 0644))
+
+	versions, errs = ListVersions(files)
+	assert.Empty(t, errs)
+	if assert.Len(t, versions, 3) {
+		assert.Equal(t, "20240501T100000.000000000Z-op-1", versions[0].Name)
+		assert.Equal(t, "op-1", versions[0].OperationId)
+		assert.Equal(t, start, versions[0].Timestamp)
+		assert.Equal(t, []string{state + ".history/20240501T100000.000000000Z-op-1"}, versions[0].Paths)
+	}
+
+	version, err := FindVersion(versions, "op-2")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "20240501T100100.000000000Z-op-2", version.Name)
+	}
+	version, err = FindVersion(versions, "20240501T1002")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "op-3", version.OperationId)
+	}
+	_, err = FindVersion(versions, "20240501")
+	assert.NotNil(t, err)
+	_, err = FindVersion(versions, "op-4")
+	assert.NotNil(t, err)
+
+	assert.Empty(t, PruneVersions(files, 2))
+	versions, _ = ListVersions(files)
+	if assert.Len(t, versions, 2) {
+		assert.Equal(t, "op-2", versions[0].OperationId)
+	}
+	assert.FileExists(t, filepath.Join(state+".history", "README"))
+
+	// ordered by time, not by operation id
+	later := start.Add(time.Hour + time.Nanosecond)
+	WriteVersion([]byte("op-0"), files, VersionName(later, "op-0"))
+	WriteVersion([]byte("op-4"), files, VersionName(start.Add(time.Hour), "op-4"))
+	versions, _ = ListVersions(files)
+	if assert.Len(t, versions, 4) {
+		assert.Equal(t, "op-4", versions[2].OperationId)
+		assert.Equal(t, "op-0", versions[3].OperationId)
+		assert.Equal(t, later, versions[3].Timestamp)
+	}
+}
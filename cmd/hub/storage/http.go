@@ -0,0 +1,221 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package storage
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+// httpBackend keeps files on HTTP(S) server: HEAD to stat, GET to read, PUT with If-Match or
+// If-None-Match to write, DELETE to remove. The server must return ETag and honor the conditional
+// headers; locks are <file>.lock resources created with If-None-Match: *.
+// Listing expects JSON array of {"name": ..., "type": "file"} on GET of the directory,
+// ie. nginx `autoindex_format json`.
+type httpBackend struct {
+	once   sync.Once
+	client *http.Client
+	err    error
+}
+
+var httpStorageTimeout = time.Duration(30 * time.Second)
+
+func (b *httpBackend) init() (*http.Client, error) {
+	b.once.Do(func() {
+		settings := config.HttpStorage
+		b.client = util.RobustHttpClient(httpStorageTimeout, settings.Insecure)
+		tlsConfig := b.client.Transport.(*http.Transport).TLSClientConfig
+		if settings.CaBundle != "" {
+			pem, err := os.ReadFile(settings.CaBundle)
+			if err != nil {
+				b.err = fmt.Errorf("Unable to read HTTP storage CA bundle: %v", err)
+				return
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				b.err = fmt.Errorf("No certificates found in HTTP storage CA bundle `%s`", settings.CaBundle)
+				return
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if settings.ClientCert != "" {
+			cert, err := tls.LoadX509KeyPair(settings.ClientCert, settings.ClientKey)
+			if err != nil {
+				b.err = fmt.Errorf("Unable to load HTTP storage client certificate: %v", err)
+				return
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+	})
+	return b.client, b.err
+}
+
+func (b *httpBackend) do(method, path string, body []byte, headers map[string]string) (*http.Response, error) {
+	client, err := b.init()
+	if err != nil {
+		return nil, err
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, path, reader)
+	if err != nil {
+		return nil, err
+	}
+	settings := config.HttpStorage
+	if settings.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+settings.Token)
+	} else if settings.Username != "" {
+		req.SetBasicAuth(settings.Username, settings.Password)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if config.Trace {
+		log.Printf(">>> %s %s", method, path)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to %s `%s`: %v", method, path, err)
+	}
+	if config.Trace {
+		log.Printf("<<< %s %s: %s", method, path, resp.Status)
+	}
+	return resp, nil
+}
+
+func httpStatusError(method, path string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("Failed to %s `%s`: %s %s", method, path, resp.Status, strings.TrimSpace(string(body)))
+}
+
+func (b *httpBackend) Stat(path string) (int64, time.Time, string, error) {
+	resp, err := b.do("HEAD", path, nil, nil)
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return 0, time.Time{}, "", os.ErrNotExist
+	case resp.StatusCode >= 300:
+		return 0, time.Time{}, "", httpStatusError("HEAD", path, resp)
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return resp.ContentLength, modTime, resp.Header.Get("ETag"), nil
+}
+
+func (b *httpBackend) Read(path string) ([]byte, string, error) {
+	resp, err := b.do("GET", path, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, "", os.ErrNotExist
+	case resp.StatusCode >= 300:
+		return nil, "", httpStatusError("GET", path, resp)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to read `%s`: %v", path, err)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+func (b *httpBackend) Write(path string, data []byte, version string) (string, error) {
+	headers := map[string]string{"Content-Type": "application/octet-stream"}
+	switch version {
+	case AnyVersion:
+	case "":
+		headers["If-None-Match"] = "*"
+	default:
+		headers["If-Match"] = version
+	}
+	resp, err := b.do("PUT", path, data, headers)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return "", os.ErrExist
+	case resp.StatusCode >= 300:
+		return "", httpStatusError("PUT", path, resp)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag, nil
+	}
+	_, _, etag, err := b.Stat(path)
+	return etag, err
+}
+
+func (b *httpBackend) Delete(path, version string) error {
+	var headers map[string]string
+	if version != "" {
+		headers = map[string]string{"If-Match": version}
+	}
+	resp, err := b.do("DELETE", path, nil, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return os.ErrNotExist
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return os.ErrExist
+	case resp.StatusCode >= 300:
+		return httpStatusError("DELETE", path, resp)
+	}
+	return nil
+}
+
+func (b *httpBackend) List(prefix string) ([]string, error) {
+	i := strings.LastIndex(prefix, "/")
+	dir, base := prefix[:i+1], prefix[i+1:]
+	resp, err := b.do("GET", dir, nil, map[string]string{"Accept": "application/json"})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode >= 300:
+		return nil, httpStatusError("GET", dir, resp)
+	}
+	var entries []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("Unable to parse `%s` listing: %v", dir, err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.Type == "file" && strings.HasPrefix(entry.Name, base) {
+			paths = append(paths, dir+entry.Name)
+		}
+	}
+	return paths, nil
+}
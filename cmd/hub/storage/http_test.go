@@ -0,0 +1,141 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+)
+
+// testHttpStorage is in-memory storage honoring conditional requests
+type testHttpStorage struct {
+	mutex    sync.Mutex
+	files    map[string][]byte
+	versions map[string]int
+	version  int
+}
+
+func (s *testHttpStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	path := r.URL.Path
+	data, exist := s.files[path]
+	etag := fmt.Sprintf(`"%d"`, s.versions[path])
+	if match := r.Header.Get("If-Match"); match != "" && (!exist || match != etag) ||
+		r.Header.Get("If-None-Match") == "*" && exist {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	switch r.Method {
+	case "HEAD", "GET":
+		if strings.HasSuffix(path, "/") {
+			var entries []map[string]string
+			for name := range s.files {
+				if strings.HasPrefix(name, path) {
+					entries = append(entries, map[string]string{"name": strings.TrimPrefix(name, path), "type": "file"})
+				}
+			}
+			json.NewEncoder(w).Encode(entries)
+			return
+		}
+		if !exist {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Write(data)
+	case "PUT":
+		s.files[path], _ = io.ReadAll(r.Body)
+		s.version++
+		s.versions[path] = s.version
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, s.version))
+		w.WriteHeader(http.StatusCreated)
+	case "DELETE":
+		if !exist {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.files, path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestHttpBackend(t *testing.T) {
+	t.Setenv(LockEnvVar, "")
+	config.HttpStorage = config.HttpStorageSettings{Token: "secret"}
+	defer func() { config.HttpStorage = config.HttpStorageSettings{} }()
+	config.Encrypted = false
+	config.Compressed = false
+	server := httptest.NewServer(&testHttpStorage{files: make(map[string][]byte), versions: make(map[string]int)})
+	defer server.Close()
+	path := server.URL + "/stacks/dev/hub.yaml.state"
+
+	files, errs := Check([]string{path}, "state")
+	if !assert.Empty(t, errs) {
+		return
+	}
+	assert.Equal(t, "http", files.Files[0].Kind)
+	assert.False(t, files.Files[0].Exist)
+
+	lock, err := AcquireLock(files, "deploy", "op-1", time.Minute, 0)
+	if !assert.Nil(t, err) {
+		return
+	}
+	written, errs := Write([]byte("kind: state"), files)
+	assert.True(t, written)
+	assert.Empty(t, errs)
+	written, errs = Write([]byte("kind: state\nstatus: deployed"), files)
+	assert.True(t, written)
+	assert.Empty(t, errs)
+
+	other, _ := Check([]string{path}, "state")
+	assert.True(t, other.Files[0].Locked)
+	_, err = AcquireLock(other, "undeploy", "op-2", time.Minute, 0)
+	assert.NotNil(t, err)
+	data, _, err := Read(other)
+	assert.Nil(t, err)
+	assert.Equal(t, "kind: state\nstatus: deployed", string(data))
+	lock.Release()
+
+	// concurrent write is detected
+	_, errs = Write([]byte("kind: state\nstatus: undeployed"), other)
+	assert.Empty(t, errs)
+	written, errs = Write([]byte("kind: state\nstatus: failed"), files)
+	assert.False(t, written)
+	assert.Len(t, errs, 1)
+
+	versions, errs := ListVersions(files)
+	assert.Empty(t, errs)
+	assert.Empty(t, versions)
+	_, errs = WriteVersion(data, files, VersionName(time.Now(), "op-1"))
+	assert.Empty(t, errs)
+	versions, errs = ListVersions(files)
+	assert.Empty(t, errs)
+	if assert.Len(t, versions, 1) {
+		assert.Equal(t, "op-1", versions[0].OperationId)
+	}
+
+	config.HttpStorage.Token = "wrong"
+	_, errs = Check([]string{path}, "state")
+	assert.NotEmpty(t, errs)
+}
@@ -0,0 +1,397 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+const (
+	// LockEnvVar is set for nested Hub CTL invocations, ie. `hub invoke` from component
+	// implementation, to reuse the lock of the parent operation
+	LockEnvVar = "HUB_LOCK_ID"
+	// LockHolderEnvVar overrides lock holder, ie. CI pipeline name
+	LockHolderEnvVar = "HUB_LOCK_HOLDER"
+
+	DefaultLockLease  = 5 * time.Minute
+	lockRetryInterval = 5 * time.Second
+)
+
+// LockInfo is the content of <file>.lock
+type LockInfo struct {
+	Holder      string    `json:"holder"`
+	Host        string    `json:"host"`
+	Pid         int       `json:"pid"`
+	Operation   string    `json:"operation"`
+	OperationId string    `json:"operationId"`
+	Acquired    time.Time `json:"acquired"`
+	Expires     time.Time `json:"expires"`
+}
+
+// Lock is held on a set of files; the lease is renewed by heartbeat until released
+type Lock struct {
+	info     LockInfo
+	lease    time.Duration
+	files    []lockFile
+	mutex    sync.Mutex
+	stop     chan struct{}
+	stopped  chan struct{}
+	released bool
+}
+
+type lockFile struct {
+	path    string
+	kind    string
+	version string
+	lost    bool
+}
+
+// AcquireLock creates <file>.lock for every file; if the lock is held by another operation, then
+// the lock is retried until wait is elapsed; expired locks are taken over.
+// Nil lock is returned if the lock is already held by the parent operation.
+func AcquireLock(files *Files, operation, operationId string, lease, wait time.Duration) (*Lock, error) {
+	if lease <= 0 {
+		lease = DefaultLockLease
+	}
+	holder := os.Getenv(LockHolderEnvVar)
+	if holder == "" {
+		if current, err := user.Current(); err == nil {
+			holder = current.Username
+		}
+	}
+	host, _ := os.Hostname()
+	now := time.Now().UTC()
+	lock := &Lock{
+		info: LockInfo{
+			Holder:      holder,
+			Host:        host,
+			Pid:         os.Getpid(),
+			Operation:   operation,
+			OperationId: operationId,
+			Acquired:    now,
+			Expires:     now.Add(lease),
+		},
+		lease: lease,
+	}
+
+	setCurrentOperation(&lock.info)
+
+	parent := os.Getenv(LockEnvVar)
+	deadline := time.Now().Add(wait)
+	for _, file := range files.Files {
+		path := lockPath(file.Path)
+		for {
+			version, held, err := lock.acquire(path, file.Kind, parent)
+			if err == nil {
+				if held != nil {
+					if config.Debug {
+						log.Printf("Lock `%s` is held by parent operation %s", path, held.OperationId)
+					}
+					break
+				}
+				lock.files = append(lock.files, lockFile{path: path, kind: file.Kind, version: version})
+				if config.Debug {
+					log.Printf("Acquired lock `%s`", path)
+				}
+				break
+			}
+			if held != nil && time.Now().Before(deadline) {
+				if config.Verbose {
+					log.Printf("Waiting for %s", err)
+				}
+				time.Sleep(lockRetryInterval)
+				continue
+			}
+			lock.Release()
+			return nil, err
+		}
+	}
+	if len(lock.files) == 0 {
+		return nil, nil
+	}
+	lock.stop = make(chan struct{})
+	lock.stopped = make(chan struct{})
+	go lock.heartbeat()
+	return lock, nil
+}
+
+// acquire returns lock file version, or lock info if the lock is held by the parent operation;
+// lock info is also returned with an error if the lock is held by another operation
+func (lock *Lock) acquire(path, kind, parent string) (string, *LockInfo, error) {
+	backend, err := lookupBackend(kind)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := json.Marshal(&lock.info)
+	if err != nil {
+		return "", nil, err
+	}
+	var existing []byte
+	var staleVersion string
+	for {
+		version, err := backend.Write(path, data, "")
+		if err == nil {
+			return version, nil, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", nil, fmt.Errorf("Unable to create lock `%s`: %v", path, err)
+		}
+		existing, staleVersion, err = backend.Read(path)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", nil, fmt.Errorf("Unable to read lock `%s`: %v", path, err)
+		}
+		// released meanwhile
+	}
+	var held LockInfo
+	if err := json.Unmarshal(existing, &held); err != nil || held.OperationId == "" {
+		return "", nil, fmt.Errorf("Lock file `%s` present - delete to proceed", path)
+	}
+	if parent != "" && held.OperationId == parent {
+		return "", &held, nil
+	}
+	if held.Expires.After(time.Now()) {
+		return "", &held, fmt.Errorf("lock `%s` held by %s", path, held.String())
+	}
+
+	util.Warn("Taking over expired lock `%s` held by %s", path, held.String())
+	version, err := backend.Write(path, data, staleVersion)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) { // somebody else was faster
+			return "", &held, fmt.Errorf("lock `%s` taken over by another operation", path)
+		}
+		return "", nil, fmt.Errorf("Unable to take over lock `%s`: %v", path, err)
+	}
+	return version, nil, nil
+}
+
+func (info *LockInfo) String() string {
+	return fmt.Sprintf("%s@%s (pid %d) %s operation %s until %v",
+		info.Holder, info.Host, info.Pid, info.Operation, info.OperationId, info.Expires.Local().Format(time.RFC3339))
+}
+
+// Env returns environment for nested Hub CTL invocations
+func (lock *Lock) Env() []string {
+	if lock == nil {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s=%s", LockEnvVar, lock.info.OperationId)}
+}
+
+func (lock *Lock) heartbeat() {
+	defer close(lock.stopped)
+	ticker := time.NewTicker(lock.lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lock.stop:
+			return
+		case <-ticker.C:
+			lock.renew()
+		}
+	}
+}
+
+func (lock *Lock) renew() {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	expired := lock.info.Expires
+	lock.info.Expires = time.Now().UTC().Add(lock.lease)
+	data, err := json.Marshal(&lock.info)
+	if err != nil {
+		util.Warn("Unable to renew lock: %v", err)
+		return
+	}
+	for i := range lock.files {
+		file := &lock.files[i]
+		if file.lost {
+			continue
+		}
+		version, err := backends[file.kind].Write(file.path, data, file.version)
+		if err != nil {
+			if errors.Is(err, os.ErrExist) {
+				util.Warn("Lock `%s` was taken over by another operation - state won't be written", file.path)
+				file.lost = true
+			} else if time.Now().After(expired) {
+				util.Warn("Unable to renew lock `%s` before the lease expired - state won't be written: %v", file.path, err)
+				file.lost = true
+			} else {
+				util.Warn("Unable to renew lock `%s`: %v", file.path, err)
+			}
+			if file.lost {
+				setLockLost(file.path, true)
+			}
+			continue
+		}
+		file.version = version
+		if config.Trace {
+			log.Printf("Renewed lock `%s` until %v", file.path, lock.info.Expires)
+		}
+	}
+}
+
+// Release stops heartbeat and deletes lock files still held by the operation
+func (lock *Lock) Release() {
+	if lock == nil {
+		return
+	}
+	defer clearCurrentOperation(lock.info.OperationId)
+	if lock.stop != nil {
+		close(lock.stop)
+		<-lock.stopped
+		lock.stop = nil
+	}
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	if lock.released {
+		return
+	}
+	lock.released = true
+	for _, file := range lock.files {
+		if file.lost {
+			setLockLost(file.path, false)
+		}
+		backend := backends[file.kind]
+		data, version, err := backend.Read(file.path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				util.Warn("Unable to read lock `%s`: %v", file.path, err)
+			}
+			continue
+		}
+		var held LockInfo
+		if err := json.Unmarshal(data, &held); err != nil || held.OperationId != lock.info.OperationId {
+			util.Warn("Lock `%s` is not held by operation %s anymore", file.path, lock.info.OperationId)
+			continue
+		}
+		if err := backend.Delete(file.path, version); err != nil {
+			util.Warn("Unable to release lock `%s`: %v", file.path, err)
+			continue
+		}
+		if config.Debug {
+			log.Printf("Released lock `%s`", file.path)
+		}
+	}
+}
+
+// BreakLock deletes lock files of an operation that is gone; lock that is not expired yet
+// is deleted only if force is set. Returns deleted lock files.
+func BreakLock(files *Files, force bool) ([]string, error) {
+	var broken []string
+	var errs []error
+	for _, file := range files.Files {
+		path := lockPath(file.Path)
+		backend, err := lookupBackend(file.Kind)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		data, version, err := backend.Read(path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("Unable to read lock `%s`: %v", path, err))
+			}
+			continue
+		}
+		var held LockInfo
+		if err := json.Unmarshal(data, &held); err == nil && held.OperationId != "" {
+			if held.Expires.After(time.Now()) && !force {
+				errs = append(errs, fmt.Errorf("lock `%s` held by %s; use --force to delete", path, held.String()))
+				continue
+			}
+			util.Warn("Deleting lock `%s` held by %s", path, held.String())
+		} else {
+			util.Warn("Deleting lock `%s`", path)
+		}
+		if err := backend.Delete(path, version); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				err = errors.New("lock was modified meanwhile")
+			}
+			errs = append(errs, fmt.Errorf("Unable to delete lock `%s`: %v", path, err))
+			continue
+		}
+		broken = append(broken, path)
+	}
+	if len(errs) > 0 {
+		return broken, errors.New(util.Errors2(errs...))
+	}
+	return broken, nil
+}
+
+var (
+	currentOperation      *LockInfo
+	currentOperationMutex sync.Mutex
+
+	lostLocks      = make(map[string]bool)
+	lostLocksMutex sync.Mutex
+)
+
+func setLockLost(path string, lost bool) {
+	lostLocksMutex.Lock()
+	defer lostLocksMutex.Unlock()
+	if lost {
+		lostLocks[path] = true
+	} else {
+		delete(lostLocks, path)
+	}
+}
+
+// checkLockLost returns an error if the lock of the file was lost by the operation
+// holding it, so that the file of another operation is not overwritten
+func checkLockLost(path string) error {
+	lostLocksMutex.Lock()
+	defer lostLocksMutex.Unlock()
+	if lostLocks[lockPath(path)] {
+		return fmt.Errorf("lock `%s` was lost to another operation", lockPath(path))
+	}
+	return nil
+}
+
+func setCurrentOperation(info *LockInfo) {
+	currentOperationMutex.Lock()
+	defer currentOperationMutex.Unlock()
+	operation := *info
+	currentOperation = &operation
+}
+
+func clearCurrentOperation(operationId string) {
+	currentOperationMutex.Lock()
+	defer currentOperationMutex.Unlock()
+	if currentOperation != nil && currentOperation.OperationId == operationId {
+		currentOperation = nil
+	}
+}
+
+// CurrentOperation returns the operation that acquired the lock, or nil;
+// backends that keep history record it along with the data
+func CurrentOperation() *LockInfo {
+	currentOperationMutex.Lock()
+	defer currentOperationMutex.Unlock()
+	if currentOperation == nil {
+		return nil
+	}
+	operation := *currentOperation
+	return &operation
+}
+
+func lockPath(path string) string {
+	return PathWithSuffix(path, ".lock")
+}
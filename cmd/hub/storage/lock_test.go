@@ -0,0 +1,192 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+)
+
+func readLockInfo(t *testing.T, path string) LockInfo {
+	var info LockInfo
+	data, err := os.ReadFile(path)
+	if assert.Nil(t, err) {
+		assert.Nil(t, json.Unmarshal(data, &info))
+	}
+	return info
+}
+
+func TestAcquireLock(t *testing.T) {
+	t.Setenv(LockEnvVar, "")
+	t.Setenv(LockHolderEnvVar, "ci-pipeline")
+	state := filepath.Join(t.TempDir(), "hub.yaml.state")
+	files, _ := Check([]string{state}, "state")
+	lockFile := state + ".lock"
+
+	lock, err := AcquireLock(files, "deploy", "op-1", time.Minute, 0)
+	if !assert.Nil(t, err) || !assert.NotNil(t, lock) {
+		return
+	}
+	info := readLockInfo(t, lockFile)
+	assert.Equal(t, "ci-pipeline", info.Holder)
+	assert.Equal(t, "op-1", info.OperationId)
+	assert.Equal(t, "deploy", info.Operation)
+	assert.Equal(t, os.Getpid(), info.Pid)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), info.Expires, 5*time.Second)
+	assert.Equal(t, []string{LockEnvVar + "=op-1"}, lock.Env())
+
+	checked, _ := Check([]string{state}, "state")
+	assert.True(t, checked.Files[0].Locked)
+
+	_, err = AcquireLock(files, "undeploy", "op-2", time.Minute, 0)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "held by ci-pipeline@")
+		assert.Contains(t, err.Error(), "deploy operation op-1")
+	}
+
+	// nested invocation
+	t.Setenv(LockEnvVar, "op-1")
+	nested, err := AcquireLock(files, "backup", "op-3", time.Minute, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, nested)
+	nested.Release()
+	assert.FileExists(t, lockFile)
+
+	lock.Release()
+	assert.NoFileExists(t, lockFile)
+	lock.Release()
+}
+
+func TestAcquireExpiredLock(t *testing.T) {
+	t.Setenv(LockEnvVar, "")
+	state := filepath.Join(t.TempDir(), "hub.yaml.state")
+	files, _ := Check([]string{state}, "state")
+	lockFile := state + ".lock"
+
+	stale, _ := json.Marshal(&LockInfo{Holder: "someone", OperationId: "op-1", Expires: time.Now().Add(-time.Second)})
+	assert.Nil(t, os.WriteFile(lockFile, stale, 0644))
+	lock, err := AcquireLock(files, "deploy", "op-2", time.Minute, 0)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "op-2", readLockInfo(t, lockFile).OperationId)
+		lock.Release()
+	}
+
+	assert.Nil(t, os.WriteFile(lockFile, []byte{}, 0644))
+	_, err = AcquireLock(files, "deploy", "op-3", time.Minute, 0)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "present - delete to proceed")
+	}
+}
+
+func TestLockHeartbeat(t *testing.T) {
+	t.Setenv(LockEnvVar, "")
+	state := filepath.Join(t.TempDir(), "hub.yaml.state")
+	files, _ := Check([]string{state}, "state")
+	lockFile := state + ".lock"
+
+	lock, err := AcquireLock(files, "deploy", "op-1", 300*time.Millisecond, 0)
+	if !assert.Nil(t, err) {
+		return
+	}
+	expires := readLockInfo(t, lockFile).Expires
+	time.Sleep(250 * time.Millisecond)
+	renewed := readLockInfo(t, lockFile).Expires
+	assert.True(t, renewed.After(expires), "lease must be renewed")
+
+	// another operation took over the lock
+	other, _ := json.Marshal(&LockInfo{OperationId: "op-2", Expires: time.Now().Add(time.Minute)})
+	assert.Nil(t, os.WriteFile(lockFile, other, 0644))
+	time.Sleep(250 * time.Millisecond)
+	config.Encrypted = false
+	config.Compressed = false
+	written, errs := Write([]byte("state"), files)
+	assert.False(t, written, "state must not be written after the lock is lost")
+	if assert.Len(t, errs, 1) {
+		assert.Contains(t, errs[0].Error(), "was lost to another operation")
+	}
+	lock.Release()
+	assert.Equal(t, "op-2", readLockInfo(t, lockFile).OperationId, "lock of another operation must not be released")
+	assert.Nil(t, checkLockLost(state))
+}
+
+func TestConcurrentLockTakeover(t *testing.T) {
+	t.Setenv(LockEnvVar, "")
+	state := filepath.Join(t.TempDir(), "hub.yaml.state")
+	lockFile := state + ".lock"
+	fsBeforeReplace = func() { time.Sleep(5 * time.Millisecond) }
+	defer func() { fsBeforeReplace = func() {} }()
+
+	for round := 0; round < 5; round++ {
+		stale, _ := json.Marshal(&LockInfo{Holder: "someone", OperationId: "op-stale", Expires: time.Now().Add(-time.Second)})
+		assert.Nil(t, os.WriteFile(lockFile, stale, 0644))
+
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		var winners []*Lock
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				files, _ := Check([]string{state}, "state")
+				lock, err := AcquireLock(files, "deploy", fmt.Sprintf("op-%d", i), time.Minute, 0)
+				if err == nil {
+					mutex.Lock()
+					winners = append(winners, lock)
+					mutex.Unlock()
+				}
+			}(i)
+		}
+		wg.Wait()
+		if assert.Len(t, winners, 1, "expired lock must be taken over by exactly one operation") {
+			assert.Equal(t, winners[0].info.OperationId, readLockInfo(t, lockFile).OperationId)
+		}
+		for _, lock := range winners {
+			lock.Release()
+		}
+		assert.NoFileExists(t, lockFile+fsGuardSuffix)
+	}
+}
+
+func TestBreakLock(t *testing.T) {
+	t.Setenv(LockEnvVar, "")
+	state := filepath.Join(t.TempDir(), "hub.yaml.state")
+	files, _ := Check([]string{state}, "state")
+	lockFile := state + ".lock"
+
+	broken, err := BreakLock(files, false)
+	assert.Nil(t, err)
+	assert.Empty(t, broken)
+
+	lock, err := AcquireLock(files, "deploy", "op-1", time.Minute, 0)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer lock.Release()
+	_, err = BreakLock(files, false)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "deploy operation op-1")
+	}
+	assert.FileExists(t, lockFile)
+	broken, err = BreakLock(files, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{lockFile}, broken)
+	assert.NoFileExists(t, lockFile)
+
+	assert.Nil(t, os.WriteFile(lockFile, []byte{}, 0644))
+	broken, err = BreakLock(files, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{lockFile}, broken)
+}
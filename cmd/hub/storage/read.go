@@ -8,23 +8,17 @@ package storage
 
 import (
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/url"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/epam/hubctl/cmd/hub/aws"
-	"github.com/epam/hubctl/cmd/hub/azure"
 	"github.com/epam/hubctl/cmd/hub/config"
 	"github.com/epam/hubctl/cmd/hub/crypto"
-	"github.com/epam/hubctl/cmd/hub/gcp"
 	"github.com/epam/hubctl/cmd/hub/util"
 )
 
-var remoteStorageSchemes = []string{"s3", "gs", "az"}
-
 func RemoteStoragePaths(paths []string) []string {
 	var remote []string
 	for _, path := range paths {
@@ -43,9 +37,13 @@ func checkPath(path, kind string) (*File, error) {
 		remote, err := url.Parse(path)
 		if err != nil {
 			err = fmt.Errorf("Unable to parse `%s` %s file path as URL: %v", path, kind, err)
-		} else if !util.Contains(remoteStorageSchemes, remote.Scheme) {
+		} else if schemes := remoteStorageSchemes(); !util.Contains(schemes, remote.Scheme) {
 			err = fmt.Errorf("%s file `%s` scheme `%s` not supported. Supported schemes: %v",
-				strings.Title(kind), path, remote.Scheme, remoteStorageSchemes)
+				strings.Title(kind), path, remote.Scheme, schemes)
+		} else if strings.HasPrefix(remote.Scheme, "git+") && !config.Encrypted {
+			// commits are kept forever and are replicated with every clone
+			err = fmt.Errorf("%s file `%s` is committed to Git repository and must be encrypted: set HUB_CRYPTO_PASSWORD, HUB_CRYPTO_AWS_KMS_KEY_ARN, HUB_CRYPTO_AZURE_KEYVAULT_KEY_ID, HUB_CRYPTO_GCP_KMS_KEY_NAME, or HUB_CRYPTO_AGE_RECIPIENTS",
+				strings.Title(kind), path)
 		}
 		if err != nil {
 			return nil, err
@@ -71,92 +69,31 @@ func Check(paths []string, kind string) (*Files, []error) {
 
 	filesChecked := make([]File, 0, len(files))
 	for _, file := range files {
-		lockPath := fmt.Sprintf("%s.lock", file.Path)
-		switch file.Kind {
-		case "fs":
-			info, err := os.Stat(file.Path)
-			_, errLock := os.Stat(lockPath)
-			if err != nil {
-				if util.NoSuchFile(err) {
-					file.Exist = false
-					file.Locked = errLock == nil
-					filesChecked = append(filesChecked, file)
-				} else {
-					util.Warn("Unable to stat `%s` %s file: %v", file.Path, kind, err)
-				}
-			} else {
-				file.Exist = true
-				file.ModTime = info.ModTime()
-				file.Size = info.Size()
-				file.Locked = errLock == nil
-				filesChecked = append(filesChecked, file)
-			}
-
-		case "s3":
-			if config.Debug {
-				log.Printf("Checking `%s` %s file...", file.Path, kind)
-			}
-			size, modTime, err := aws.StatS3(file.Path)
-			_, _, errLock := aws.StatS3(lockPath)
-			if err != nil {
-				if err == os.ErrNotExist {
-					file.Exist = false
-					file.Locked = errLock == nil
-					filesChecked = append(filesChecked, file)
-				} else {
-					util.Warn("Unable to check `%s` %s file: %v", file.Path, kind, err)
-				}
-			} else {
-				file.Exist = true
-				file.ModTime = modTime
-				file.Size = size
-				file.Locked = errLock == nil
-				filesChecked = append(filesChecked, file)
-			}
-
-		case "gs":
-			if config.Debug {
-				log.Printf("Checking `%s` %s file...", file.Path, kind)
-			}
-			size, modTime, err := gcp.StatGCS(file.Path)
-			_, _, errLock := gcp.StatGCS(lockPath)
-			if err != nil {
-				if err == os.ErrNotExist {
-					file.Exist = false
-					file.Locked = errLock == nil
-					filesChecked = append(filesChecked, file)
-				} else {
-					util.Warn("Unable to check `%s` %s file: %v", file.Path, kind, err)
-				}
-			} else {
-				file.Exist = true
-				file.ModTime = modTime
-				file.Size = size
+		backend, err := lookupBackend(file.Kind)
+		if err != nil {
+			util.Warn("Unable to check `%s` %s file: %v", file.Path, kind, err)
+			continue
+		}
+		if config.Debug && file.Kind != "fs" {
+			log.Printf("Checking `%s` %s file...", file.Path, kind)
+		}
+		size, modTime, version, err := backend.Stat(file.Path)
+		_, _, _, errLock := backend.Stat(lockPath(file.Path))
+		if err != nil {
+			if err == os.ErrNotExist {
+				file.Exist = false
 				file.Locked = errLock == nil
 				filesChecked = append(filesChecked, file)
-			}
-
-		case "az":
-			if config.Debug {
-				log.Printf("Checking `%s` %s file...", file.Path, kind)
-			}
-			size, modTime, err := azure.StatStorageBlob(file.Path)
-			_, _, errLock := azure.StatStorageBlob(lockPath)
-			if err != nil {
-				if err == os.ErrNotExist {
-					file.Exist = false
-					file.Locked = errLock == nil
-					filesChecked = append(filesChecked, file)
-				} else {
-					util.Warn("Unable to check `%s` %s file: %v", file.Path, kind, err)
-				}
 			} else {
-				file.Exist = true
-				file.ModTime = modTime
-				file.Size = size
-				file.Locked = errLock == nil
-				filesChecked = append(filesChecked, file)
+				util.Warn("Unable to check `%s` %s file: %v", file.Path, kind, err)
 			}
+		} else {
+			file.Exist = true
+			file.ModTime = modTime
+			file.Size = size
+			file.Version = version
+			file.Locked = errLock == nil
+			filesChecked = append(filesChecked, file)
 		}
 	}
 
@@ -179,7 +116,7 @@ func EnsureNoLockFiles(files *Files) {
 	locked := make([]string, 0)
 	for _, file := range files.Files {
 		if file.Locked {
-			locked = append(locked, fmt.Sprintf("%s.lock", file.Path))
+			locked = append(locked, lockPath(file.Path))
 		}
 	}
 	if len(locked) > 0 {
@@ -191,10 +128,11 @@ func EnsureNoLockFiles(files *Files) {
 func chooseFile(files *Files) (*File, error) {
 	delta := time.Duration(-10) * time.Second
 
-	filesExist := make([]File, 0, len(files.Files))
-	for _, file := range files.Files {
-		if file.Exist {
-			filesExist = append(filesExist, file)
+	// pointers into files so that read version is kept
+	filesExist := make([]*File, 0, len(files.Files))
+	for i := range files.Files {
+		if files.Files[i].Exist {
+			filesExist = append(filesExist, &files.Files[i])
 		}
 	}
 
@@ -202,7 +140,7 @@ func chooseFile(files *Files) (*File, error) {
 		return nil, os.ErrNotExist
 	}
 	if len(filesExist) == 1 {
-		return &filesExist[0], nil
+		return filesExist[0], nil
 	}
 
 	modTime := filesExist[0].ModTime
@@ -212,7 +150,7 @@ func chooseFile(files *Files) (*File, error) {
 		}
 	}
 	modTime = modTime.Add(delta)
-	candidates := make([]File, 0, len(filesExist))
+	candidates := make([]*File, 0, len(filesExist))
 	for _, file := range filesExist {
 		if file.ModTime.After(modTime) {
 			candidates = append(candidates, file)
@@ -220,7 +158,7 @@ func chooseFile(files *Files) (*File, error) {
 	}
 
 	if len(candidates) == 1 {
-		return &candidates[0], nil
+		return candidates[0], nil
 	}
 
 	largest := candidates[0]
@@ -230,7 +168,7 @@ func chooseFile(files *Files) (*File, error) {
 		}
 	}
 	if largest.Kind == "fs" {
-		return &largest, nil
+		return largest, nil
 	}
 	for _, file := range candidates {
 		if file.Kind == "fs" &&
@@ -238,34 +176,26 @@ func chooseFile(files *Files) (*File, error) {
 				file.Size+crypto.EncryptionV1Overhead == largest.Size ||
 				file.Size+crypto.EncryptionV2Overhead == largest.Size ||
 				file.Size+crypto.EncryptionV3Overhead == largest.Size ||
-				file.Size+crypto.EncryptionV4Overhead == largest.Size) {
-			return &file, nil
+				file.Size+crypto.EncryptionV4Overhead == largest.Size ||
+				file.Size+crypto.EncryptionV5Overhead == largest.Size) {
+			return file, nil
 		}
 	}
 
-	return &largest, nil
+	return largest, nil
 }
 
+// readFile reads the file and sets file version to the version read
 func readFile(file *File) ([]byte, error) {
-	var data []byte
-	var err error
-
-	switch file.Kind {
-	case "fs":
-		data, err = ioutil.ReadFile(file.Path)
-
-	case "s3":
-		data, err = aws.ReadS3(file.Path)
-
-	case "gs":
-		data, err = gcp.ReadGCS(file.Path)
-
-	case "az":
-		data, err = azure.ReadStorageBlob(file.Path)
+	backend, err := lookupBackend(file.Kind)
+	if err != nil {
+		return nil, err
 	}
+	data, version, err := backend.Read(file.Path)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to read `%s`: %v", file.Path, err)
 	}
+	file.Version = version
 	return data, nil
 }
 
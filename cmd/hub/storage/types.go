@@ -16,6 +16,8 @@ type File struct {
 	Exist   bool
 	Size    int64
 	ModTime time.Time
+	// Version is ETag, generation, etc. of the file as last seen by Check, Read, or Write
+	Version string
 	Locked  bool
 }
 
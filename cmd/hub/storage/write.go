@@ -13,10 +13,8 @@ import (
 	"os"
 
 	"github.com/epam/hubctl/cmd/hub/aws"
-	"github.com/epam/hubctl/cmd/hub/azure"
 	"github.com/epam/hubctl/cmd/hub/config"
 	"github.com/epam/hubctl/cmd/hub/crypto"
-	"github.com/epam/hubctl/cmd/hub/gcp"
 	"github.com/epam/hubctl/cmd/hub/util"
 )
 
@@ -25,7 +23,7 @@ func Write(data []byte, files *Files) (bool, []error) {
 	encrypt := false
 	if config.Encrypted {
 		for _, file := range files.Files {
-			if util.Contains(remoteStorageSchemes, file.Kind) {
+			if file.Kind != "fs" {
 				encrypt = true
 				break
 			}
@@ -55,60 +53,50 @@ func Write(data []byte, files *Files) (bool, []error) {
 
 	var errs []error
 	written := false
-	for _, file := range files.Files {
-		nErrs := len(errs)
-		switch file.Kind {
-		case "s3":
-			err := aws.WriteS3(file.Path, encryptedData)
-			if err != nil {
-				msg := fmt.Sprintf("Unable to write `%s` %s file: %v", file.Path, files.Kind, err)
-				if aws.IsSlowDown(err) && (len(files.Files) > 1 || config.Force) {
-					util.Warn("%s", msg)
-				} else {
-					errs = append(errs, errors.New(msg))
-				}
-			}
-
-		case "gs":
-			err := gcp.WriteGCS(file.Path, encryptedData)
-			if err != nil {
-				msg := fmt.Sprintf("Unable to write `%s` %s file: %v", file.Path, files.Kind, err)
-				errs = append(errs, errors.New(msg))
-			}
-
-		case "az":
-			err := azure.WriteStorageBlob(file.Path, encryptedData)
-			if err != nil {
-				msg := fmt.Sprintf("Unable to write `%s` %s file: %v", file.Path, files.Kind, err)
+	for i := range files.Files {
+		file := &files.Files[i]
+		body := encryptedData
+		if file.Kind == "fs" {
+			body = data
+		}
+		version, err := writeFile(file, body)
+		if err != nil {
+			msg := fmt.Sprintf("Unable to write `%s` %s file: %v", file.Path, files.Kind, err)
+			if aws.IsSlowDown(err) && (len(files.Files) > 1 || config.Force) {
+				util.Warn("%s", msg)
+			} else {
 				errs = append(errs, errors.New(msg))
 			}
-
-		case "fs":
-			out, err := os.Create(file.Path)
-			if err != nil {
-				err = fmt.Errorf("Unable to open `%s` %s file for write: %v", file.Path, files.Kind, err)
-				errs = append(errs, err)
-				continue
-			}
-			wrote, err := out.Write(data)
-			err2 := out.Close()
-			if err != nil || wrote != len(data) || err2 != nil {
-				if err == nil && err2 != nil {
-					err = err2
-				}
-				err = fmt.Errorf("Unable to write `%s` %s file (wrote %d out of %d bytes): %s",
-					file.Path, files.Kind, wrote, len(data), util.Errors2(err))
-				errs = append(errs, err)
-			}
+			continue
 		}
-
-		if nErrs == 0 {
-			if config.Verbose {
-				log.Printf("Wrote %s `%s`", files.Kind, file.Path)
-			}
-			written = true
+		file.Exist = true
+		file.Version = version
+		if config.Verbose {
+			log.Printf("Wrote %s `%s`", files.Kind, file.Path)
 		}
+		written = true
 	}
 
 	return written, errs
 }
+
+// writeFile writes the file if it was not modified since it was checked or read,
+// and the lock is still held
+func writeFile(file *File, data []byte) (string, error) {
+	if err := checkLockLost(file.Path); err != nil {
+		return "", err
+	}
+	backend, err := lookupBackend(file.Kind)
+	if err != nil {
+		return "", err
+	}
+	version, err := backend.Write(file.Path, data, file.Version)
+	if errors.Is(err, os.ErrExist) {
+		if !config.Force {
+			return "", errors.New("file was modified by another operation; use --force to overwrite")
+		}
+		util.Warn("`%s` was modified by another operation - overwriting", file.Path)
+		version, err = backend.Write(file.Path, data, AnyVersion)
+	}
+	return version, err
+}
@@ -14,7 +14,10 @@ import (
 	"github.com/epam/hubctl/cmd/hub/config"
 )
 
-var atDone []func() <-chan struct{}
+var (
+	atDone     []func() <-chan struct{}
+	atDoneLast []func()
+)
 
 func MaybeFatalf(format string, v ...interface{}) {
 	if config.Force {
@@ -25,6 +28,12 @@ func MaybeFatalf(format string, v ...interface{}) {
 	}
 }
 
+// Fatalf is log.Fatalf that executes Done() cleanups first, ie. releases state lock
+func Fatalf(format string, v ...interface{}) {
+	Done()
+	log.Fatalf(format, v...)
+}
+
 func MaybeFatalf2(cleanup func(string, bool), format string, v ...interface{}) {
 	msg := fmt.Sprintf(format, v...)
 	if config.Force {
@@ -45,6 +54,11 @@ func AtDone(cleanup func() <-chan struct{}) {
 	atDone = append(atDone, cleanup)
 }
 
+// AtDoneLast registers cleanup to run after AtDone cleanups are complete, ie. state is written
+func AtDoneLast(cleanup func()) {
+	atDoneLast = append(atDoneLast, cleanup)
+}
+
 func Done() {
 	var chs []<-chan struct{}
 	for _, cleanup := range atDone {
@@ -57,4 +71,9 @@ func Done() {
 	for _, ch := range chs {
 		<-ch
 	}
+	last := atDoneLast
+	atDoneLast = nil
+	for _, cleanup := range last {
+		cleanup()
+	}
 }
@@ -0,0 +1,124 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package vault
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+	"github.com/epam/hubctl/cmd/hub/util"
+)
+
+const defaultKubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+var (
+	httpClient   *http.Client
+	clientAddr   string
+	clientErr    error
+	clientOnce   sync.Once
+	token        string
+	tokenMutex   sync.Mutex
+	vaultTimeout = time.Duration(30 * time.Second)
+)
+
+// vaultClient is configured by VAULT_ADDR, VAULT_CACERT, and VAULT_SKIP_VERIFY
+func vaultClient() (*http.Client, string, error) {
+	clientOnce.Do(func() {
+		clientAddr = os.Getenv("VAULT_ADDR")
+		if clientAddr == "" {
+			clientErr = errors.New("VAULT_ADDR is not set")
+			return
+		}
+		insecure := util.Contains([]string{"1", "true"}, strings.ToLower(os.Getenv("VAULT_SKIP_VERIFY")))
+		httpClient = util.RobustHttpClient(vaultTimeout, insecure)
+		if caCert := os.Getenv("VAULT_CACERT"); caCert != "" {
+			pem, err := os.ReadFile(caCert)
+			if err != nil {
+				clientErr = fmt.Errorf("Unable to read VAULT_CACERT: %v", err)
+				return
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				clientErr = fmt.Errorf("No certificates found in VAULT_CACERT `%s`", caCert)
+				return
+			}
+			httpClient.Transport.(*http.Transport).TLSClientConfig.RootCAs = pool
+		}
+	})
+	return httpClient, clientAddr, clientErr
+}
+
+// login returns VAULT_TOKEN or ~/.vault-token, or logs in with AppRole - VAULT_ROLE_ID and
+// VAULT_SECRET_ID, or Kubernetes auth - VAULT_K8S_ROLE; auth mount is set by VAULT_AUTH_MOUNT
+func login() (string, error) {
+	tokenMutex.Lock()
+	defer tokenMutex.Unlock()
+	if token != "" {
+		return token, nil
+	}
+
+	if env := os.Getenv("VAULT_TOKEN"); env != "" {
+		token = env
+		return token, nil
+	}
+
+	var mount string
+	var credentials map[string]string
+	if roleId := os.Getenv("VAULT_ROLE_ID"); roleId != "" {
+		mount = "approle"
+		credentials = map[string]string{"role_id": roleId, "secret_id": os.Getenv("VAULT_SECRET_ID")}
+	} else if role := os.Getenv("VAULT_K8S_ROLE"); role != "" {
+		mount = "kubernetes"
+		tokenFile := os.Getenv("VAULT_K8S_TOKEN_PATH")
+		if tokenFile == "" {
+			tokenFile = defaultKubernetesTokenFile
+		}
+		jwt, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("Unable to read Kubernetes service account token: %v", err)
+		}
+		credentials = map[string]string{"role": role, "jwt": strings.TrimSpace(string(jwt))}
+	} else {
+		if home, err := os.UserHomeDir(); err == nil {
+			if saved, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				token = strings.TrimSpace(string(saved))
+				return token, nil
+			}
+		}
+		return "", errors.New("Vault auth is not configured: set VAULT_TOKEN, VAULT_ROLE_ID and VAULT_SECRET_ID for AppRole, or VAULT_K8S_ROLE for Kubernetes auth")
+	}
+	if env := os.Getenv("VAULT_AUTH_MOUNT"); env != "" {
+		mount = env
+	}
+
+	var auth struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	status, errs, err := do("POST", fmt.Sprintf("auth/%s/login", mount), "", credentials, &auth)
+	if err != nil {
+		return "", err
+	}
+	if status >= 300 || auth.Auth.ClientToken == "" {
+		return "", fmt.Errorf("Vault %s login failed: %d %s", mount, status, strings.Join(errs, ", "))
+	}
+	if config.Debug {
+		log.Printf("Logged in to Vault with %s auth", mount)
+	}
+	token = auth.Auth.ClientToken
+	return token, nil
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/config"
+)
+
+// AnyVersion makes write unconditional
+const AnyVersion = "*"
+
+// Files are kept in KV v2 secrets engine: vault://mount/path. Writes use check-and-set on secret
+// version; the operation that wrote the file is recorded in secret custom metadata.
+
+// kvData is the secret data: file content and the operation that wrote it
+type kvData struct {
+	Content     []byte `json:"content"`
+	OperationId string `json:"operation_id,omitempty"`
+}
+
+type kvMetadata struct {
+	Version     int       `json:"version"`
+	CreatedTime time.Time `json:"created_time"`
+}
+
+type kvSecret struct {
+	Data struct {
+		Data     kvData     `json:"data"`
+		Metadata kvMetadata `json:"metadata"`
+	} `json:"data"`
+}
+
+// splitPath returns KV v2 mount and secret path of vault://mount/path
+func splitPath(path string) (string, string, error) {
+	location, err := url.Parse(path)
+	if err != nil {
+		return "", "", err
+	}
+	key := strings.TrimPrefix(location.Path, "/")
+	if location.Host == "" || key == "" {
+		return "", "", fmt.Errorf("Vault path `%s` must be vault://mount/path", path)
+	}
+	return location.Host, key, nil
+}
+
+func isCasMismatch(status int, errs []string) bool {
+	if status != http.StatusBadRequest {
+		return false
+	}
+	for _, err := range errs {
+		if strings.Contains(err, "check-and-set") {
+			return true
+		}
+	}
+	return false
+}
+
+// StatKV returns secret size, creation time of the current version, and the version, or os.ErrNotExist
+func StatKV(path string) (int64, time.Time, string, error) {
+	data, metadata, err := readKV(path)
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+	return int64(len(data.Content)), metadata.CreatedTime, strconv.Itoa(metadata.Version), nil
+}
+
+// ReadKV returns secret content and version, or os.ErrNotExist
+func ReadKV(path string) ([]byte, string, error) {
+	data, metadata, err := readKV(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return data.Content, strconv.Itoa(metadata.Version), nil
+}
+
+func readKV(path string) (*kvData, *kvMetadata, error) {
+	mount, key, err := splitPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var secret kvSecret
+	status, errs, err := request("GET", fmt.Sprintf("%s/data/%s", mount, key), nil, &secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return nil, nil, os.ErrNotExist
+	case status >= 300:
+		return nil, nil, fmt.Errorf("Failed to read Vault secret `%s`: %d %s", path, status, strings.Join(errs, ", "))
+	}
+	return &secret.Data.Data, &secret.Data.Metadata, nil
+}
+
+// WriteKV creates the secret if version is empty, writes new version if current version matches,
+// or writes unconditionally if version is AnyVersion; returns new version, or os.ErrExist if the
+// secret exists or was modified. Operation metadata is kept in secret custom metadata.
+func WriteKV(path string, body []byte, version string, operation map[string]string) (string, error) {
+	mount, key, err := splitPath(path)
+	if err != nil {
+		return "", err
+	}
+	payload := map[string]interface{}{
+		"data": kvData{Content: body, OperationId: operation["operation_id"]},
+	}
+	switch version {
+	case AnyVersion:
+	case "":
+		payload["options"] = map[string]int{"cas": 0}
+	default:
+		cas, err := strconv.Atoi(version)
+		if err != nil {
+			return "", fmt.Errorf("Bad Vault secret `%s` version `%s`: %v", path, version, err)
+		}
+		payload["options"] = map[string]int{"cas": cas}
+	}
+	var written struct {
+		Data kvMetadata `json:"data"`
+	}
+	status, errs, err := request("POST", fmt.Sprintf("%s/data/%s", mount, key), payload, &written)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case isCasMismatch(status, errs):
+		return "", os.ErrExist
+	case status >= 300:
+		return "", fmt.Errorf("Failed to write Vault secret `%s`: %d %s", path, status, strings.Join(errs, ", "))
+	}
+
+	if len(operation) > 0 {
+		status, errs, err := request("POST", fmt.Sprintf("%s/metadata/%s", mount, key),
+			map[string]interface{}{"custom_metadata": operation}, nil)
+		if err == nil && status >= 300 {
+			err = fmt.Errorf("%d %s", status, strings.Join(errs, ", "))
+		}
+		if err != nil && config.Verbose {
+			log.Printf("Unable to update Vault secret `%s` metadata: %v", path, err)
+		}
+	}
+	return strconv.Itoa(written.Data.Version), nil
+}
+
+// DeleteKV deletes all versions of the secret if current version matches version, if set
+func DeleteKV(path string, version string) error {
+	mount, key, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	if version != "" {
+		_, current, err := ReadKV(path)
+		if err != nil {
+			return err
+		}
+		// KV v2 has no conditional delete, there is a short window for a concurrent write
+		if current != version {
+			return os.ErrExist
+		}
+	}
+	status, errs, err := request("DELETE", fmt.Sprintf("%s/metadata/%s", mount, key), nil, nil)
+	if err != nil {
+		return err
+	}
+	if status >= 300 && status != http.StatusNotFound {
+		return fmt.Errorf("Failed to delete Vault secret `%s`: %d %s", path, status, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// ListKV returns paths of secrets under the prefix
+func ListKV(prefix string) ([]string, error) {
+	mount, key, err := splitPath(prefix)
+	if err != nil {
+		return nil, err
+	}
+	dir, base := "", key
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		dir, base = key[:i+1], key[i+1:]
+	}
+	var list struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+	status, errs, err := request("LIST", fmt.Sprintf("%s/metadata/%s", mount, dir), nil, &list)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return nil, nil
+	case status >= 300:
+		return nil, fmt.Errorf("Failed to list Vault secrets `%s`: %d %s", prefix, status, strings.Join(errs, ", "))
+	}
+	var paths []string
+	for _, name := range list.Data.Keys {
+		if !strings.HasSuffix(name, "/") && strings.HasPrefix(name, base) {
+			paths = append(paths, fmt.Sprintf("vault://%s/%s%s", mount, dir, name))
+		}
+	}
+	return paths, nil
+}
+
+// request calls Vault API under /v1/; returns HTTP status and Vault errors
+func request(method, path string, body interface{}, out interface{}) (int, []string, error) {
+	token, err := login()
+	if err != nil {
+		return 0, nil, err
+	}
+	return do(method, path, token, body, out)
+}
+
+func do(method, path, token string, body interface{}, out interface{}) (int, []string, error) {
+	client, addr, err := vaultClient()
+	if err != nil {
+		return 0, nil, err
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	addr = fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(addr, "/"), path)
+	req, err := http.NewRequest(method, addr, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if config.Trace {
+		log.Printf(">>> %s %s", method, addr)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Vault %s %s failed: %v", method, addr, err)
+	}
+	defer resp.Body.Close()
+	if config.Trace {
+		log.Printf("<<< %s %s: %s", method, addr, resp.Status)
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Vault %s %s failed: %v", method, addr, err)
+	}
+	if resp.StatusCode >= 300 {
+		var vaultErrors struct {
+			Errors []string `json:"errors"`
+		}
+		json.Unmarshal(respBody, &vaultErrors)
+		return resp.StatusCode, vaultErrors.Errors, nil
+	}
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return 0, nil, fmt.Errorf("Unable to parse Vault %s %s response: %v", method, addr, err)
+		}
+	}
+	return resp.StatusCode, nil, nil
+}
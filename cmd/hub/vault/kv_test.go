@@ -0,0 +1,219 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSecret struct {
+	data     json.RawMessage
+	version  int
+	metadata map[string]string
+}
+
+// fakeKV is a minimal KV v2 engine mounted at `secret` with AppRole login
+func fakeKV(t *testing.T) (*httptest.Server, map[string]*fakeSecret) {
+	var mutex sync.Mutex
+	secrets := make(map[string]*fakeSecret)
+	reply := func(w http.ResponseWriter, status int, body interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+	fail := func(w http.ResponseWriter, status int, msg string) {
+		reply(w, status, map[string][]string{"errors": {msg}})
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.URL.Path == "/v1/auth/approle/login" {
+			var credentials map[string]string
+			json.NewDecoder(r.Body).Decode(&credentials)
+			if credentials["role_id"] != "role" || credentials["secret_id"] != "secret" {
+				fail(w, http.StatusBadRequest, "invalid role or secret ID")
+				return
+			}
+			reply(w, http.StatusOK, map[string]interface{}{"auth": map[string]string{"client_token": "s.approle"}})
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != "s.approle" {
+			fail(w, http.StatusForbidden, "permission denied")
+			return
+		}
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+			key := strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")
+			secret := secrets[key]
+			switch r.Method {
+			case "GET":
+				if secret == nil {
+					fail(w, http.StatusNotFound, "")
+					return
+				}
+				reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+					"data":     secret.data,
+					"metadata": map[string]interface{}{"version": secret.version, "created_time": time.Now()},
+				}})
+			case "POST":
+				var payload struct {
+					Data    json.RawMessage `json:"data"`
+					Options map[string]int  `json:"options"`
+				}
+				json.NewDecoder(r.Body).Decode(&payload)
+				current := 0
+				if secret != nil {
+					current = secret.version
+				}
+				if cas, exist := payload.Options["cas"]; exist && cas != current {
+					fail(w, http.StatusBadRequest, "check-and-set parameter did not match the current version")
+					return
+				}
+				if secret == nil {
+					secret = &fakeSecret{}
+					secrets[key] = secret
+				}
+				secret.data = payload.Data
+				secret.version++
+				reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"version": secret.version}})
+			}
+		case strings.HasPrefix(r.URL.Path, "/v1/secret/metadata/"):
+			key := strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata/")
+			switch r.Method {
+			case "POST":
+				var payload struct {
+					CustomMetadata map[string]string `json:"custom_metadata"`
+				}
+				json.NewDecoder(r.Body).Decode(&payload)
+				if secret := secrets[key]; secret != nil {
+					secret.metadata = payload.CustomMetadata
+				}
+				w.WriteHeader(http.StatusNoContent)
+			case "DELETE":
+				delete(secrets, key)
+				w.WriteHeader(http.StatusNoContent)
+			case "LIST":
+				keys := []string{}
+				for name := range secrets {
+					if strings.HasPrefix(name, key) {
+						rest := strings.TrimPrefix(name, key)
+						if i := strings.Index(rest, "/"); i >= 0 {
+							rest = rest[:i+1]
+						}
+						keys = append(keys, rest)
+					}
+				}
+				if len(keys) == 0 {
+					fail(w, http.StatusNotFound, "")
+					return
+				}
+				sort.Strings(keys)
+				reply(w, http.StatusOK, map[string]interface{}{"data": map[string][]string{"keys": keys}})
+			}
+		default:
+			fail(w, http.StatusNotFound, "no handler for route")
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, secrets
+}
+
+func resetClient() {
+	clientOnce = sync.Once{}
+	httpClient, clientAddr, clientErr = nil, "", nil
+	token = ""
+}
+
+func TestKV(t *testing.T) {
+	server, secrets := fakeKV(t)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("VAULT_ROLE_ID", "role")
+	t.Setenv("VAULT_SECRET_ID", "secret")
+	resetClient()
+	t.Cleanup(resetClient)
+
+	path := "vault://secret/hub/dev.state"
+	_, _, err := ReadKV(path)
+	assert.Equal(t, os.ErrNotExist, err)
+
+	version, err := WriteKV(path, []byte("state"), "", map[string]string{"operation_id": "op-1", "operation": "deploy"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1", version)
+	}
+	assert.Equal(t, "deploy", secrets["hub/dev.state"].metadata["operation"])
+
+	_, err = WriteKV(path, []byte("again"), "", nil)
+	assert.Equal(t, os.ErrExist, err)
+
+	version, err = WriteKV(path, []byte("state 2"), version, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "2", version)
+	}
+	_, err = WriteKV(path, []byte("stale"), "1", nil)
+	assert.Equal(t, os.ErrExist, err)
+
+	data, current, err := ReadKV(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "state 2", string(data))
+		assert.Equal(t, "2", current)
+	}
+	size, _, current, err := StatKV(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(7), size)
+		assert.Equal(t, "2", current)
+	}
+
+	_, err = WriteKV("vault://secret/hub/dev.state.lock", []byte("lock"), AnyVersion, nil)
+	assert.NoError(t, err)
+	_, err = WriteKV("vault://secret/hub/other/file", []byte("other"), AnyVersion, nil)
+	assert.NoError(t, err)
+	paths, err := ListKV("vault://secret/hub/dev.state")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"vault://secret/hub/dev.state", "vault://secret/hub/dev.state.lock"}, paths)
+	}
+
+	assert.Equal(t, os.ErrExist, DeleteKV(path, "1"))
+	assert.NoError(t, DeleteKV(path, "2"))
+	_, _, err = ReadKV(path)
+	assert.Equal(t, os.ErrNotExist, err)
+}
+
+func TestKVLoginFailed(t *testing.T) {
+	server, _ := fakeKV(t)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("VAULT_ROLE_ID", "role")
+	t.Setenv("VAULT_SECRET_ID", "wrong")
+	resetClient()
+	t.Cleanup(resetClient)
+
+	_, _, err := ReadKV("vault://secret/hub/dev.state")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "approle login failed")
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	mount, key, err := splitPath("vault://kv/hub/dev.state")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "kv", mount)
+		assert.Equal(t, "hub/dev.state", key)
+	}
+	_, _, err = splitPath("vault://kv")
+	assert.Error(t, err)
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2022 EPAM Systems, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package vault
+
+import (
+	"time"
+
+	"github.com/epam/hubctl/cmd/hub/storage"
+)
+
+// kvBackend is vault:// storage backend
+type kvBackend struct{}
+
+func init() {
+	storage.RegisterBackend("vault", &kvBackend{})
+}
+
+func (*kvBackend) Stat(path string) (int64, time.Time, string, error) {
+	return StatKV(path)
+}
+
+func (*kvBackend) Read(path string) ([]byte, string, error) {
+	return ReadKV(path)
+}
+
+func (*kvBackend) Write(path string, data []byte, version string) (string, error) {
+	return WriteKV(path, data, version, operationMetadata())
+}
+
+func (*kvBackend) Delete(path, version string) error {
+	return DeleteKV(path, version)
+}
+
+func (*kvBackend) List(prefix string) ([]string, error) {
+	return ListKV(prefix)
+}
+
+// operationMetadata describes the operation holding the state lock
+func operationMetadata() map[string]string {
+	operation := storage.CurrentOperation()
+	if operation == nil {
+		return nil
+	}
+	return map[string]string{
+		"operation_id": operation.OperationId,
+		"operation":    operation.Operation,
+		"initiator":    operation.Holder,
+	}
+}